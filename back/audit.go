@@ -0,0 +1,303 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Actor is who performs a mutation. Mutations made through a store without an
+// actor (startup tasks, tests) are attributed to "system".
+type Actor struct {
+	UserID int64
+	Email  string
+}
+
+// label is the name stored with audit entries and review history.
+func (a Actor) label() string {
+	if a.Email == "" {
+		return "system"
+	}
+	return a.Email
+}
+
+// WithActor returns a store whose mutations are attributed to the actor in the
+// audit log. It shares the underlying database handle.
+func (s *Store) WithActor(actor Actor) *Store {
+	scoped := *s
+	scoped.actor = actor
+	return &scoped
+}
+
+const (
+	AuditEntityEmployee = "employee"
+	AuditEntityReview   = "review"
+	AuditEntityPayroll  = "payroll"
+	AuditEntityUser     = "user"
+	AuditEntityAPIToken = "api_token"
+)
+
+const (
+	AuditActionCreate     = "create"
+	AuditActionUpdate     = "update"
+	AuditActionTransition = "transition"
+	AuditActionOffboard   = "offboard"
+	AuditActionRestore    = "restore"
+	AuditActionPurge      = "purge"
+	AuditActionDelete     = "delete"
+	AuditActionVoid       = "void"
+	AuditActionCorrect    = "correct"
+	AuditActionRevoke     = "revoke"
+)
+
+// AuditEntry records one change. Before and After hold the full entity for
+// creates and deletes and only the changed fields for everything else.
+type AuditEntry struct {
+	ID         int64           `json:"id"`
+	OccurredAt string          `json:"occurredAt"`
+	ActorID    int64           `json:"actorId,omitempty"`
+	ActorEmail string          `json:"actorEmail"`
+	Entity     string          `json:"entity"`
+	EntityID   int64           `json:"entityId"`
+	Action     string          `json:"action"`
+	Before     json.RawMessage `json:"before"`
+	After      json.RawMessage `json:"after"`
+}
+
+type AuditFilter struct {
+	Entity   string
+	EntityID int64
+	ActorID  int64
+	Action   string
+	// Since and Until bound occurredAt and compare as RFC 3339 strings.
+	Since string
+	Until string
+	Limit int
+}
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 500
+)
+
+// audit writes an entry on the caller's transaction so the entry and the change
+// commit or roll back together. Updates that change nothing are not recorded.
+func (s *Store) audit(tx dbtx, entity string, entityID int64, action string, before, after any) error {
+	beforeJSON, afterJSON, changed, err := auditDiff(before, after)
+	if err != nil || !changed {
+		return err
+	}
+	_, err = tx.Exec(`INSERT INTO audit_log(occurred_at, actor_user_id, actor_email, entity, entity_id, action, before_json, after_json)
+		VALUES(?, ?, ?, ?, ?, ?, ?, ?)`,
+		time.Now().UTC().Format(time.RFC3339), nullIfZero(s.actor.UserID), s.actor.label(), entity, entityID, action, beforeJSON, afterJSON)
+	return err
+}
+
+// auditDiff serialises the snapshots. When both exist only the fields whose
+// values differ are kept.
+func auditDiff(before, after any) (beforeJSON, afterJSON any, changed bool, err error) {
+	if before == nil || after == nil {
+		b, err := marshalSnapshot(before)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		a, err := marshalSnapshot(after)
+		return b, a, true, err
+	}
+	var beforeFields, afterFields map[string]json.RawMessage
+	if err := remarshal(before, &beforeFields); err != nil {
+		return nil, nil, false, err
+	}
+	if err := remarshal(after, &afterFields); err != nil {
+		return nil, nil, false, err
+	}
+	// The row version changes on every write and says nothing about what changed.
+	delete(beforeFields, "version")
+	delete(afterFields, "version")
+	for key, value := range beforeFields {
+		if bytes.Equal(value, afterFields[key]) {
+			delete(beforeFields, key)
+			delete(afterFields, key)
+		}
+	}
+	if len(beforeFields) == 0 && len(afterFields) == 0 {
+		return nil, nil, false, nil
+	}
+	b, err := json.Marshal(beforeFields)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	a, err := json.Marshal(afterFields)
+	return string(b), string(a), true, err
+}
+
+func marshalSnapshot(v any) (any, error) {
+	if v == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+func remarshal(v any, out any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, out)
+}
+
+func buildAuditFilter(filter AuditFilter) where {
+	var w where
+	if filter.Entity != "" {
+		w.eq("entity", filter.Entity)
+	}
+	if filter.EntityID > 0 {
+		w.eq("entity_id", filter.EntityID)
+	}
+	if filter.ActorID > 0 {
+		w.eq("actor_user_id", filter.ActorID)
+	}
+	if filter.Action != "" {
+		w.eq("action", filter.Action)
+	}
+	if filter.Since != "" {
+		w.add("occurred_at >= ?", filter.Since)
+	}
+	if filter.Until != "" {
+		w.add("occurred_at <= ?", filter.Until)
+	}
+	return w
+}
+
+// ListAuditEntries returns the newest entries first.
+func (s *Store) ListAuditEntries(filter AuditFilter) ([]AuditEntry, error) {
+	builder := strings.Builder{}
+	builder.WriteString(`SELECT id, occurred_at, COALESCE(actor_user_id, 0), actor_email, entity, entity_id, action, before_json, after_json
+		FROM audit_log`)
+	w := buildAuditFilter(filter)
+	builder.WriteString(w.String())
+	args := w.args
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultAuditLimit
+	}
+	limit = min(limit, maxAuditLimit)
+	builder.WriteString(" ORDER BY id DESC LIMIT ?")
+	args = append(args, limit)
+
+	rows, err := s.db.Query(builder.String(), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]AuditEntry, 0)
+	for rows.Next() {
+		var (
+			e             AuditEntry
+			before, after sql.NullString
+		)
+		if err := rows.Scan(&e.ID, &e.OccurredAt, &e.ActorID, &e.ActorEmail, &e.Entity, &e.EntityID, &e.Action, &before, &after); err != nil {
+			return nil, err
+		}
+		if before.Valid {
+			e.Before = json.RawMessage(before.String)
+		}
+		if after.Valid {
+			e.After = json.RawMessage(after.String)
+		}
+		result = append(result, e)
+	}
+	return result, rows.Err()
+}
+
+// Audit handlers
+
+func (a *API) handleListAudit(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter := AuditFilter{
+		Entity: q.Get("entity"),
+		Action: q.Get("action"),
+	}
+	for name, target := range map[string]*int64{"entityId": &filter.EntityID, "actorId": &filter.ActorID} {
+		if v := q.Get(name); v != "" {
+			id, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				writeError(w, http.StatusUnprocessableEntity, "invalid "+name)
+				return
+			}
+			*target = id
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 {
+			writeError(w, http.StatusUnprocessableEntity, "invalid limit")
+			return
+		}
+		filter.Limit = limit
+	}
+	var ok bool
+	if filter.Since, ok = parseAuditTime(q.Get("since"), "T00:00:00Z"); !ok {
+		writeError(w, http.StatusUnprocessableEntity, "invalid since")
+		return
+	}
+	if filter.Until, ok = parseAuditTime(q.Get("until"), "T23:59:59Z"); !ok {
+		writeError(w, http.StatusUnprocessableEntity, "invalid until")
+		return
+	}
+
+	entries, err := a.store.ListAuditEntries(filter)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, internalErrorMsg)
+		return
+	}
+	principal, _ := principalFrom(r.Context())
+	if !principal.can(permViewBaseSalary) {
+		for i := range entries {
+			if entries[i].Entity == AuditEntityPayroll {
+				entries[i].Before = withoutFields(entries[i].Before, payComponentFields)
+				entries[i].After = withoutFields(entries[i].After, payComponentFields)
+			}
+		}
+	}
+	_ = json.NewEncoder(w).Encode(entries)
+}
+
+// parseAuditTime accepts RFC 3339 timestamps or plain dates, which are widened
+// with dayBound to cover the whole day.
+func parseAuditTime(v, dayBound string) (string, bool) {
+	if v == "" {
+		return "", true
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t.UTC().Format(time.RFC3339), true
+	}
+	if _, err := time.Parse(hireDateLayout, v); err == nil {
+		return v + dayBound, true
+	}
+	return "", false
+}
+
+func withoutFields(raw json.RawMessage, names []string) json.RawMessage {
+	if raw == nil {
+		return nil
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return raw
+	}
+	for _, name := range names {
+		delete(fields, name)
+	}
+	out, err := json.Marshal(fields)
+	if err != nil {
+		return raw
+	}
+	return out
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func mustListAudit(t *testing.T, store *Store, filter AuditFilter) []AuditEntry {
+	t.Helper()
+	entries, err := store.ListAuditEntries(filter)
+	if err != nil {
+		t.Fatalf("list audit: %v", err)
+	}
+	return entries
+}
+
+func TestAudit_recordsActorAndDiff(t *testing.T) {
+	store := newMemoryStore(t)
+	defer store.Close()
+	hr := store.WithActor(Actor{UserID: 7, Email: "hr@example.com"})
+
+	emp, err := hr.CreateEmployee(EmployeeInput{Name: "Alice"})
+	if err != nil {
+		t.Fatalf("create employee: %v", err)
+	}
+	review := mustCreateReview(t, store, emp.ID)
+	if _, err := hr.UpdatePerformanceReview(review.ID, PerformanceReviewUpdate{Rating: intPtr(5), Reviewer: strPtr("Manager")}); err != nil {
+		t.Fatalf("update review: %v", err)
+	}
+
+	created := mustListAudit(t, store, AuditFilter{Entity: AuditEntityEmployee, EntityID: emp.ID})
+	if len(created) != 1 || created[0].Action != AuditActionCreate || created[0].ActorID != 7 || created[0].ActorEmail != "hr@example.com" {
+		t.Fatalf("unexpected employee entries %+v", created)
+	}
+	if created[0].Before != nil || !strings.Contains(string(created[0].After), `"name":"Alice"`) {
+		t.Fatalf("expected full snapshot on create, got before=%s after=%s", created[0].Before, created[0].After)
+	}
+
+	updates := mustListAudit(t, store, AuditFilter{Entity: AuditEntityReview, Action: AuditActionUpdate})
+	if len(updates) != 1 {
+		t.Fatalf("expected one review update, got %+v", updates)
+	}
+	// Only the rating changed; the reviewer was set to its current value.
+	if string(updates[0].Before) != `{"rating":4}` || string(updates[0].After) != `{"rating":5}` {
+		t.Fatalf("unexpected diff before=%s after=%s", updates[0].Before, updates[0].After)
+	}
+
+	reviewCreate := mustListAudit(t, store, AuditFilter{Entity: AuditEntityReview, Action: AuditActionCreate})
+	if len(reviewCreate) != 1 || reviewCreate[0].ActorEmail != "system" || reviewCreate[0].ActorID != 0 {
+		t.Fatalf("expected store without actor to log as system, got %+v", reviewCreate)
+	}
+
+	if _, err := hr.UpdatePerformanceReview(review.ID, PerformanceReviewUpdate{Rating: intPtr(5)}); err != nil {
+		t.Fatalf("no-op update: %v", err)
+	}
+	if got := mustListAudit(t, store, AuditFilter{Entity: AuditEntityReview, Action: AuditActionUpdate}); len(got) != 1 {
+		t.Fatalf("expected no entry for an update that changes nothing, got %d", len(got))
+	}
+}
+
+func TestAudit_writtenInSameTransaction(t *testing.T) {
+	store := newMemoryStore(t)
+	defer store.Close()
+
+	if _, err := store.CreateEmployee(EmployeeInput{Name: "A", Email: "a@example.com"}); err != nil {
+		t.Fatalf("create employee: %v", err)
+	}
+	if _, err := store.CreateEmployee(EmployeeInput{Name: "B", Email: "a@example.com"}); err == nil {
+		t.Fatalf("expected duplicate email to fail")
+	}
+	if got := mustListAudit(t, store, AuditFilter{}); len(got) != 1 {
+		t.Fatalf("failed mutations must not be logged, got %d entries", len(got))
+	}
+
+	if _, err := store.db.Exec("DROP TABLE audit_log"); err != nil {
+		t.Fatalf("drop audit log: %v", err)
+	}
+	if _, err := store.CreateEmployee(EmployeeInput{Name: "C"}); err == nil {
+		t.Fatalf("expected create to fail when the audit entry cannot be written")
+	}
+	list, err := store.ListEmployees(EmployeeFilter{})
+	if err != nil {
+		t.Fatalf("list employees: %v", err)
+	}
+	if len(list) != 1 {
+		t.Fatalf("expected the employee insert to roll back, got %+v", list)
+	}
+}
+
+func TestAudit_lifecycleActions(t *testing.T) {
+	store := newMemoryStore(t)
+	defer store.Close()
+
+	emp := mustCreateEmployee(t, store, "Bob")
+	review := mustCreateReview(t, store, emp.ID)
+	if _, err := store.TransitionPerformanceReview(review.ID, ReviewStateSubmitted, "", 0); err != nil {
+		t.Fatalf("transition: %v", err)
+	}
+	record, err := store.CreatePayrollRecord(PayrollRecordInput{EmployeeID: emp.ID, Period: "2024-01", BaseSalary: 1000})
+	if err != nil {
+		t.Fatalf("create payroll: %v", err)
+	}
+	corrected, err := store.CorrectPayrollRecord(record.ID, PayrollRecordInput{EmployeeID: emp.ID, Period: "2024-01", BaseSalary: 1200}, "typo")
+	if err != nil {
+		t.Fatalf("correct payroll: %v", err)
+	}
+	if _, err := store.OffboardEmployee(emp.ID, ""); err != nil {
+		t.Fatalf("offboard: %v", err)
+	}
+	if err := store.PurgeEmployee(emp.ID); err != nil {
+		t.Fatalf("purge: %v", err)
+	}
+
+	var actions []string
+	for _, e := range mustListAudit(t, store, AuditFilter{}) {
+		actions = append(actions, e.Entity+":"+e.Action)
+	}
+	want := []string{
+		"employee:purge", "payroll:delete", "payroll:delete", "review:delete", "employee:offboard",
+		"payroll:correct", "payroll:void", "payroll:create", "review:transition", "review:create", "employee:create",
+	}
+	if strings.Join(actions, ",") != strings.Join(want, ",") {
+		t.Fatalf("unexpected audit trail:\n got %v\nwant %v", actions, want)
+	}
+
+	transitions := mustListAudit(t, store, AuditFilter{Action: AuditActionTransition})
+	if string(transitions[0].Before) != `{"state":"draft"}` || string(transitions[0].After) != `{"state":"submitted"}` {
+		t.Fatalf("unexpected transition diff %s -> %s", transitions[0].Before, transitions[0].After)
+	}
+	deleted := mustListAudit(t, store, AuditFilter{Entity: AuditEntityPayroll, EntityID: corrected.ID, Action: AuditActionDelete})
+	if len(deleted) != 1 || deleted[0].After != nil || !strings.Contains(string(deleted[0].Before), `"baseSalary":12.00`) {
+		t.Fatalf("expected the deleted row's last contents, got %+v", deleted)
+	}
+}
+
+func TestAudit_purgeUnlinksReportsAndUsers(t *testing.T) {
+	store := newMemoryStore(t)
+	defer store.Close()
+
+	manager := mustCreateEmployee(t, store, "Boss")
+	report, err := store.CreateEmployee(EmployeeInput{Name: "Report", ManagerID: manager.ID})
+	if err != nil {
+		t.Fatalf("create report: %v", err)
+	}
+	user := mustCreateUser(t, store, "boss@example.com", RoleManager, manager.ID)
+	if _, err := store.OffboardEmployee(manager.ID, ""); err != nil {
+		t.Fatalf("offboard: %v", err)
+	}
+	if err := store.PurgeEmployee(manager.ID); err != nil {
+		t.Fatalf("purge: %v", err)
+	}
+
+	got, err := store.GetEmployee(report.ID)
+	if err != nil {
+		t.Fatalf("get report: %v", err)
+	}
+	if got.ManagerID != 0 || got.Version != report.Version+1 {
+		t.Fatalf("expected the report unlinked with a new version, got %+v", got)
+	}
+	unlinked := mustListAudit(t, store, AuditFilter{Entity: AuditEntityEmployee, EntityID: report.ID, Action: AuditActionUpdate})
+	if len(unlinked) != 1 || !strings.Contains(string(unlinked[0].Before), `"managerId"`) || strings.Contains(string(unlinked[0].After), `"managerId"`) {
+		t.Fatalf("expected an audit entry clearing managerId, got %+v", unlinked)
+	}
+
+	account, err := store.GetUser(user.ID)
+	if err != nil {
+		t.Fatalf("get user: %v", err)
+	}
+	if account.EmployeeID != 0 {
+		t.Fatalf("expected the user unlinked, got %+v", account)
+	}
+	unlinked = mustListAudit(t, store, AuditFilter{Entity: AuditEntityUser, EntityID: user.ID, Action: AuditActionUpdate})
+	if len(unlinked) != 1 || !strings.Contains(string(unlinked[0].Before), `"employeeId"`) || strings.Contains(string(unlinked[0].After), `"employeeId"`) {
+		t.Fatalf("expected an audit entry clearing employeeId, got %+v", unlinked)
+	}
+}
+
+func TestAuditEndpoint(t *testing.T) {
+	store, mux := newTestMux(t)
+	defer store.Close()
+	hr := withTestPrincipal(mux, Principal{User: User{ID: 3, Email: "hr@example.com", Role: RoleHR}})
+	admin := withTestPrincipal(mux, Principal{User: User{ID: 4, Email: "admin@example.com", Role: RoleAdmin}})
+
+	doJSON(t, hr, http.MethodPost, "/employees", map[string]string{"name": "Alice"})
+	doJSON(t, hr, http.MethodPost, "/payroll", map[string]any{"employeeId": 1, "period": "2024-01", "baseSalary": 1000})
+	doJSON(t, admin, http.MethodPut, "/employees/1", map[string]string{"department": "Sales"})
+
+	rr := doJSON(t, hr, http.MethodGet, "/audit?entity=employee&entityId=1&actorId=4", nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	var entries []AuditEntry
+	if err := json.Unmarshal(rr.Body.Bytes(), &entries); err != nil {
+		t.Fatalf("json: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Action != AuditActionUpdate || entries[0].ActorEmail != "admin@example.com" {
+		t.Fatalf("unexpected entries %+v", entries)
+	}
+	if string(entries[0].After) != `{"department":"Sales"}` {
+		t.Fatalf("unexpected diff %s", entries[0].After)
+	}
+
+	rr = doJSON(t, hr, http.MethodGet, "/audit?entity=payroll&since=2000-01-01&limit=5", nil)
+	if !strings.Contains(rr.Body.String(), `"baseSalary":1000.00`) {
+		t.Fatalf("expected HR to see baseSalary in payroll entries: %s", rr.Body.String())
+	}
+	rr = doJSON(t, admin, http.MethodGet, "/audit?entity=payroll", nil)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "netPay") {
+		t.Fatalf("expected payroll entries for admin, got %d %s", rr.Code, rr.Body.String())
+	}
+	for _, field := range payComponentFields {
+		if strings.Contains(rr.Body.String(), `"`+field+`"`) {
+			t.Fatalf("expected %s redacted for admin, got %s", field, rr.Body.String())
+		}
+	}
+	if rr := doJSON(t, hr, http.MethodGet, "/audit?until=2000-01-01", nil); strings.TrimSpace(rr.Body.String()) != "[]" {
+		t.Fatalf("expected no entries before 2000, got %s", rr.Body.String())
+	}
+
+	for _, q := range []string{"entityId=x", "actorId=1.5", "limit=0", "since=yesterday", "until=2024-13-01"} {
+		if rr := doJSON(t, hr, http.MethodGet, "/audit?"+q, nil); rr.Code != http.StatusUnprocessableEntity {
+			t.Fatalf("%s: expected 422, got %d", q, rr.Code)
+		}
+	}
+	finance := withTestPrincipal(mux, Principal{User: User{ID: 5, Role: RoleFinance}})
+	if rr := doJSON(t, finance, http.MethodGet, "/audit", nil); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for finance, got %d", rr.Code)
+	}
+	if rr := doJSON(t, hr, http.MethodPost, "/audit", nil); rr.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,384 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Passwords are stored as "pbkdf2-sha256$<iterations>$<salt>$<hash>" so the
+// iteration count can be raised later without invalidating existing hashes.
+const (
+	passwordHashScheme = "pbkdf2-sha256"
+	passwordSaltBytes  = 16
+	passwordKeyBytes   = 32
+)
+
+// passwordIterations follows the OWASP recommendation for PBKDF2-HMAC-SHA256.
+var passwordIterations = 600_000
+
+// dummyPasswordHash is verified against when a login email does not exist.
+var dummyPasswordHash = mustHashPassword("timing-equaliser")
+
+func hashPassword(password string) (string, error) {
+	salt := make([]byte, passwordSaltBytes)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key, err := pbkdf2.Key(sha256.New, password, salt, passwordIterations, passwordKeyBytes)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s$%d$%s$%s", passwordHashScheme, passwordIterations,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func mustHashPassword(password string) string {
+	hash, err := hashPassword(password)
+	if err != nil {
+		panic(err)
+	}
+	return hash
+}
+
+func verifyPassword(encoded, password string) bool {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 4 || parts[0] != passwordHashScheme {
+		return false
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations <= 0 {
+		return false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	want, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil {
+		return false
+	}
+	got, err := pbkdf2.Key(sha256.New, password, salt, iterations, len(want))
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(got, want) == 1
+}
+
+// TokenSigner issues and verifies HS256 JWTs for logged-in users.
+type TokenSigner struct {
+	secret []byte
+	ttl    time.Duration
+	now    func() time.Time
+}
+
+var errInvalidToken = errors.New("invalid token")
+
+func NewTokenSigner(secret []byte, ttl time.Duration) *TokenSigner {
+	return &TokenSigner{secret: secret, ttl: ttl, now: time.Now}
+}
+
+type tokenClaims struct {
+	Subject   string `json:"sub"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// jwtHeader is fixed: only HS256 is ever issued or accepted.
+var jwtHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+func (ts *TokenSigner) Sign(userID int64) (string, time.Time, error) {
+	now := ts.now().UTC()
+	expires := now.Add(ts.ttl)
+	claims, err := json.Marshal(tokenClaims{
+		Subject:   strconv.FormatInt(userID, 10),
+		IssuedAt:  now.Unix(),
+		ExpiresAt: expires.Unix(),
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	unsigned := jwtHeader + "." + base64.RawURLEncoding.EncodeToString(claims)
+	return unsigned + "." + ts.signature(unsigned), expires, nil
+}
+
+// Verify checks the signature and expiry and returns the user ID in the token.
+func (ts *TokenSigner) Verify(token string) (int64, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != jwtHeader {
+		return 0, errInvalidToken
+	}
+	unsigned := parts[0] + "." + parts[1]
+	if !hmac.Equal([]byte(parts[2]), []byte(ts.signature(unsigned))) {
+		return 0, errInvalidToken
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return 0, errInvalidToken
+	}
+	var claims tokenClaims
+	if err := json.Unmarshal(raw, &claims); err != nil {
+		return 0, errInvalidToken
+	}
+	if ts.now().Unix() >= claims.ExpiresAt {
+		return 0, errInvalidToken
+	}
+	userID, err := strconv.ParseInt(claims.Subject, 10, 64)
+	if err != nil {
+		return 0, errInvalidToken
+	}
+	return userID, nil
+}
+
+func (ts *TokenSigner) signature(unsigned string) string {
+	mac := hmac.New(sha256.New, ts.secret)
+	mac.Write([]byte(unsigned))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Principal is the authenticated caller attached to each request context.
+type Principal struct {
+	User User
+	// APITokenID is set when the request used a personal token instead of a login token.
+	APITokenID int64
+}
+
+type principalKey struct{}
+
+func withPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+func principalFrom(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
+
+// publicPaths can be reached without credentials.
+var publicPaths = map[string]struct{}{
+	"/auth/login": {},
+}
+
+// AllowAnonymous lets requests without an Authorization header through as p,
+// which main only does in development mode. Tokens that are sent are still
+// verified, and the /auth routes always need a real user.
+func (a *API) AllowAnonymous(p Principal) {
+	a.anonymous = &p
+}
+
+// anonymousPrincipal is who unauthenticated callers act as in development
+// mode. HR reaches every screen of the frontend but cannot manage users or
+// purge employees.
+var anonymousPrincipal = Principal{User: User{Email: "anonymous", Name: "Anonymous", Role: RoleHR}}
+
+// Authenticate wraps the mux and rejects requests without a valid bearer
+// token, unless AllowAnonymous was called.
+func (a *API) Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := publicPaths[r.URL.Path]; ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if a.anonymous != nil && r.Header.Get("Authorization") == "" && !strings.HasPrefix(r.URL.Path, "/auth/") {
+			next.ServeHTTP(w, r.WithContext(withPrincipal(r.Context(), *a.anonymous)))
+			return
+		}
+		principal, err := a.principalForRequest(r)
+		if err != nil {
+			setJSON(w)
+			if errors.Is(err, ErrInvalidCredentials) {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="tp07"`)
+				writeError(w, http.StatusUnauthorized, "unauthorized")
+				return
+			}
+			writeError(w, http.StatusInternalServerError, internalErrorMsg)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(withPrincipal(r.Context(), principal)))
+	})
+}
+
+func (a *API) principalForRequest(r *http.Request) (Principal, error) {
+	header := r.Header.Get("Authorization")
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+		return Principal{}, ErrInvalidCredentials
+	}
+	token = strings.TrimSpace(token)
+	if strings.HasPrefix(token, apiTokenPrefix) {
+		user, tokenID, err := a.store.UserForAPIToken(token)
+		if err != nil {
+			return Principal{}, err
+		}
+		return Principal{User: user, APITokenID: tokenID}, nil
+	}
+	userID, err := a.tokens.Verify(token)
+	if err != nil {
+		return Principal{}, ErrInvalidCredentials
+	}
+	user, err := a.store.GetUser(userID)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return Principal{}, ErrInvalidCredentials
+		}
+		return Principal{}, err
+	}
+	return Principal{User: user}, nil
+}
+
+// Auth handlers
+
+type loginPayload struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type loginResponse struct {
+	Token     string `json:"token"`
+	ExpiresAt string `json:"expiresAt"`
+	User      User   `json:"user"`
+}
+
+func (a *API) handleLogin(w http.ResponseWriter, r *http.Request) {
+	var payload loginPayload
+	if !decodeJSON(w, r, &payload) {
+		return
+	}
+	user, err := a.store.AuthenticateUser(payload.Email, payload.Password)
+	if err != nil {
+		if errors.Is(err, ErrInvalidCredentials) {
+			writeErrorFor(w, http.StatusUnauthorized, err)
+			return
+		}
+		writeError(w, http.StatusInternalServerError, internalErrorMsg)
+		return
+	}
+	token, expires, err := a.tokens.Sign(user.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, internalErrorMsg)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(loginResponse{
+		Token:     token,
+		ExpiresAt: expires.Format(time.RFC3339),
+		User:      user,
+	})
+}
+
+func (a *API) handleMe(w http.ResponseWriter, r *http.Request) {
+	principal, _ := principalFrom(r.Context())
+	_ = json.NewEncoder(w).Encode(principal.User)
+}
+
+type apiTokenPayload struct {
+	Name string `json:"name"`
+}
+
+type apiTokenCreatedResponse struct {
+	APIToken
+	// Token is the secret; it cannot be retrieved again after this response.
+	Token string `json:"token"`
+}
+
+func (a *API) handleCreateAPIToken(w http.ResponseWriter, r *http.Request) {
+	var payload apiTokenPayload
+	if !decodeJSON(w, r, &payload) {
+		return
+	}
+	if strings.TrimSpace(payload.Name) == "" {
+		writeErrorFor(w, http.StatusUnprocessableEntity, fieldError("name", FieldRequired, "name is required"))
+		return
+	}
+	principal, _ := principalFrom(r.Context())
+	token, secret, err := a.storeFor(r).CreateAPIToken(principal.User.ID, payload.Name)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, internalErrorMsg)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(apiTokenCreatedResponse{APIToken: token, Token: secret})
+}
+
+func (a *API) handleListAPITokens(w http.ResponseWriter, r *http.Request) {
+	principal, _ := principalFrom(r.Context())
+	tokens, err := a.store.ListAPITokens(principal.User.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, internalErrorMsg)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(tokens)
+}
+
+func (a *API) handleRevokeAPIToken(w http.ResponseWriter, r *http.Request, id int64) {
+	principal, _ := principalFrom(r.Context())
+	if err := a.storeFor(r).RevokeAPIToken(principal.User.ID, id); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			writeError(w, http.StatusNotFound, "not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, internalErrorMsg)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type userPayload struct {
+	Email      string `json:"email"`
+	Name       string `json:"name"`
+	Password   string `json:"password"`
+	Role       string `json:"role"`
+	EmployeeID int64  `json:"employeeId"`
+}
+
+func (a *API) handleCreateUser(w http.ResponseWriter, r *http.Request) {
+	var payload userPayload
+	if !decodeJSON(w, r, &payload) {
+		return
+	}
+	input := UserInput{
+		Email:      normalizeEmail(payload.Email),
+		Name:       strings.TrimSpace(payload.Name),
+		Password:   payload.Password,
+		Role:       normalizeRole(payload.Role),
+		EmployeeID: payload.EmployeeID,
+	}
+	if err := validateUserInput(input); err != nil {
+		writeErrorFor(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	created, err := a.storeFor(r).CreateUser(input)
+	if err != nil {
+		if errors.Is(err, ErrDuplicateUserEmail) {
+			writeErrorFor(w, http.StatusConflict, err)
+			return
+		}
+		if errors.Is(err, ErrEmployeeNotFound) {
+			writeErrorCode(w, http.StatusUnprocessableEntity, "unknown_employee", unknownEmployeeMsg)
+			return
+		}
+		writeError(w, http.StatusInternalServerError, internalErrorMsg)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(created)
+}
+
+func (a *API) handleListUsers(w http.ResponseWriter, _ *http.Request) {
+	users, err := a.store.ListUsers()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, internalErrorMsg)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(users)
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func setupAuthServer(t *testing.T) (*Store, http.Handler) {
+	t.Helper()
+	store, mux := newTestMux(t)
+	api := NewAPI(store, NewTokenSigner([]byte("test-secret"), time.Hour))
+	return store, api.Authenticate(mux)
+}
+
+func mustCreateUser(t *testing.T, store *Store, email, role string, employeeID int64) User {
+	t.Helper()
+	user, err := store.CreateUser(UserInput{
+		Email:      email,
+		Name:       "User " + email,
+		Password:   "correct horse",
+		Role:       role,
+		EmployeeID: employeeID,
+	})
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return user
+}
+
+func mustLogin(t *testing.T, handler http.Handler, email string) string {
+	t.Helper()
+	rr := doJSON(t, handler, http.MethodPost, "/auth/login", map[string]string{"email": email, "password": "correct horse"})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("login: expected 200, got %d body %s", rr.Code, rr.Body.String())
+	}
+	var resp loginResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("json: %v", err)
+	}
+	return resp.Token
+}
+
+func TestPasswordHashing(t *testing.T) {
+	hash, err := hashPassword("s3cret-pass")
+	if err != nil {
+		t.Fatalf("hash: %v", err)
+	}
+	if !strings.HasPrefix(hash, passwordHashScheme+"$") || strings.Contains(hash, "s3cret-pass") {
+		t.Fatalf("unexpected hash format %q", hash)
+	}
+	if !verifyPassword(hash, "s3cret-pass") {
+		t.Fatalf("expected password to verify")
+	}
+	if verifyPassword(hash, "wrong") {
+		t.Fatalf("expected wrong password to fail")
+	}
+	for _, bad := range []string{"", "plain", "pbkdf2-sha256$x$a$b", "md5$1$a$b"} {
+		if verifyPassword(bad, "s3cret-pass") {
+			t.Fatalf("expected malformed hash %q to fail", bad)
+		}
+	}
+}
+
+func TestTokenSigner(t *testing.T) {
+	signer := NewTokenSigner([]byte("secret"), time.Minute)
+	token, expires, err := signer.Sign(42)
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	if id, err := signer.Verify(token); err != nil || id != 42 {
+		t.Fatalf("expected user 42, got %d %v", id, err)
+	}
+
+	other := NewTokenSigner([]byte("other"), time.Minute)
+	if _, err := other.Verify(token); err == nil {
+		t.Fatalf("expected token signed with another secret to fail")
+	}
+	parts := strings.Split(token, ".")
+	if _, err := signer.Verify(parts[0] + "." + parts[1] + "x." + parts[2]); err == nil {
+		t.Fatalf("expected tampered token to fail")
+	}
+
+	signer.now = func() time.Time { return expires.Add(time.Second) }
+	if _, err := signer.Verify(token); err == nil {
+		t.Fatalf("expected expired token to fail")
+	}
+}
+
+func TestAuthenticate_requiresToken(t *testing.T) {
+	store, handler := setupAuthServer(t)
+	defer store.Close()
+
+	for _, token := range []string{"", "garbage", apiTokenPrefix + "unknown"} {
+		rr := doJSONWithToken(t, handler, http.MethodGet, "/payroll", token, nil)
+		if rr.Code != http.StatusUnauthorized {
+			t.Fatalf("expected 401 for token %q, got %d", token, rr.Code)
+		}
+		if rr.Header().Get("WWW-Authenticate") == "" {
+			t.Fatalf("expected WWW-Authenticate header")
+		}
+	}
+}
+
+func TestAuthenticate_allowAnonymous(t *testing.T) {
+	store, mux := newTestMux(t)
+	defer store.Close()
+	api := NewAPI(store, NewTokenSigner([]byte("test-secret"), time.Hour))
+	api.AllowAnonymous(anonymousPrincipal)
+	handler := api.Authenticate(mux)
+
+	if rr := doJSONWithToken(t, handler, http.MethodGet, "/employees", "", nil); rr.Code != http.StatusOK {
+		t.Fatalf("expected anonymous access, got %d", rr.Code)
+	}
+	if rr := doJSONWithToken(t, handler, http.MethodGet, "/users", "", nil); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected anonymous callers to keep their role's limits, got %d", rr.Code)
+	}
+	if rr := doJSONWithToken(t, handler, http.MethodGet, "/employees", "garbage", nil); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected a bad token to be rejected, got %d", rr.Code)
+	}
+	if rr := doJSONWithToken(t, handler, http.MethodPost, "/auth/tokens", "", map[string]string{"name": "ci"}); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected the /auth routes to need a real user, got %d", rr.Code)
+	}
+}
+
+func TestTokenSignerFromEnv_requiresSecretOutsideDevMode(t *testing.T) {
+	t.Setenv("AUTH_SECRET", "")
+	t.Setenv("APP_ENV", "")
+	if _, err := tokenSignerFromEnv(); err == nil {
+		t.Fatalf("expected a missing secret to be refused")
+	}
+	t.Setenv("APP_ENV", "development")
+	if _, err := tokenSignerFromEnv(); err != nil {
+		t.Fatalf("expected dev mode to generate a secret, got %v", err)
+	}
+}
+
+func TestLoginAndMe(t *testing.T) {
+	store, handler := setupAuthServer(t)
+	defer store.Close()
+
+	user := mustCreateUser(t, store, "hr@example.com", RoleAdmin, 0)
+	token := mustLogin(t, handler, "HR@example.com")
+
+	rr := doJSONWithToken(t, handler, http.MethodGet, "/auth/me", token, nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	var me User
+	if err := json.Unmarshal(rr.Body.Bytes(), &me); err != nil {
+		t.Fatalf("json: %v", err)
+	}
+	if me.ID != user.ID || me.Email != "hr@example.com" {
+		t.Fatalf("unexpected user %+v", me)
+	}
+	if rr := doJSONWithToken(t, handler, http.MethodGet, "/employees", token, nil); rr.Code != http.StatusOK {
+		t.Fatalf("expected authenticated request to succeed, got %d", rr.Code)
+	}
+}
+
+func TestLogin_invalidCredentials(t *testing.T) {
+	store, handler := setupAuthServer(t)
+	defer store.Close()
+
+	mustCreateUser(t, store, "hr@example.com", RoleAdmin, 0)
+	for _, creds := range []map[string]string{
+		{"email": "hr@example.com", "password": "wrong password"},
+		{"email": "nobody@example.com", "password": "correct horse"},
+	} {
+		rr := doJSON(t, handler, http.MethodPost, "/auth/login", creds)
+		if rr.Code != http.StatusUnauthorized {
+			t.Fatalf("expected 401, got %d", rr.Code)
+		}
+	}
+	if rr := doJSON(t, handler, http.MethodGet, "/auth/login", nil); rr.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405, got %d", rr.Code)
+	}
+}
+
+func TestAPITokenLifecycle(t *testing.T) {
+	store, handler := setupAuthServer(t)
+	defer store.Close()
+
+	mustCreateUser(t, store, "ops@example.com", RoleAdmin, 0)
+	session := mustLogin(t, handler, "ops@example.com")
+
+	rr := doJSONWithToken(t, handler, http.MethodPost, "/auth/tokens", session, map[string]string{"name": "payroll import"})
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d body %s", rr.Code, rr.Body.String())
+	}
+	var created apiTokenCreatedResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &created); err != nil {
+		t.Fatalf("json: %v", err)
+	}
+	if !strings.HasPrefix(created.Token, apiTokenPrefix) {
+		t.Fatalf("unexpected token %q", created.Token)
+	}
+
+	if rr := doJSONWithToken(t, handler, http.MethodGet, "/payroll", created.Token, nil); rr.Code != http.StatusOK {
+		t.Fatalf("expected api token to authenticate, got %d", rr.Code)
+	}
+
+	rr = doJSONWithToken(t, handler, http.MethodGet, "/auth/tokens", session, nil)
+	var tokens []APIToken
+	if err := json.Unmarshal(rr.Body.Bytes(), &tokens); err != nil {
+		t.Fatalf("json: %v", err)
+	}
+	if len(tokens) != 1 || tokens[0].LastUsedAt == "" || strings.Contains(rr.Body.String(), created.Token) {
+		t.Fatalf("unexpected token list %s", rr.Body.String())
+	}
+
+	if rr := doJSONWithToken(t, handler, http.MethodDelete, "/auth/tokens/1", session, nil); rr.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", rr.Code)
+	}
+	if rr := doJSONWithToken(t, handler, http.MethodGet, "/payroll", created.Token, nil); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected revoked token to be rejected, got %d", rr.Code)
+	}
+	if rr := doJSONWithToken(t, handler, http.MethodDelete, "/auth/tokens/99", session, nil); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rr.Code)
+	}
+	if rr := doJSONWithToken(t, handler, http.MethodPost, "/auth/tokens", session, map[string]string{"name": " "}); rr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d", rr.Code)
+	}
+}
+
+func TestCreateUser(t *testing.T) {
+	store, handler := setupAuthServer(t)
+	defer store.Close()
+
+	mustCreateUser(t, store, "admin@example.com", RoleAdmin, 0)
+	session := mustLogin(t, handler, "admin@example.com")
+
+	rr := doJSONWithToken(t, handler, http.MethodPost, "/users", session, map[string]string{"email": "new@example.com", "name": "New", "password": "short", "role": "hr"})
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 for weak password, got %d", rr.Code)
+	}
+	rr = doJSONWithToken(t, handler, http.MethodPost, "/users", session, map[string]string{"email": "new@example.com", "name": "New", "password": "long enough", "role": "hr"})
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d body %s", rr.Code, rr.Body.String())
+	}
+	if strings.Contains(rr.Body.String(), "password") {
+		t.Fatalf("password hash must never be returned: %s", rr.Body.String())
+	}
+	rr = doJSONWithToken(t, handler, http.MethodPost, "/users", session, map[string]string{"email": "NEW@example.com", "name": "Dup", "password": "long enough", "role": "hr"})
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d", rr.Code)
+	}
+	rr = doJSONWithToken(t, handler, http.MethodGet, "/users", session, nil)
+	var users []User
+	if err := json.Unmarshal(rr.Body.Bytes(), &users); err != nil || len(users) != 2 {
+		t.Fatalf("expected 2 users, got %s %v", rr.Body.String(), err)
+	}
+}
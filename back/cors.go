@@ -0,0 +1,204 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// corsRouteMethods lists the methods each route answers, keyed like the
+// ServeMux patterns in RegisterRoutes; keys ending in "/" match by prefix.
+// TestCORSRouteMethods_matchRegisteredRoutes fails when the two drift apart.
+var corsRouteMethods = map[string][]string{
+	"/auth/login":       {http.MethodPost},
+	"/auth/me":          {http.MethodGet},
+	"/auth/tokens":      {http.MethodGet, http.MethodPost},
+	"/auth/tokens/":     {http.MethodDelete},
+	"/users":            {http.MethodGet, http.MethodPost},
+	"/audit":            {http.MethodGet},
+	"/employees":        {http.MethodGet, http.MethodPost},
+	"/employees/":       {http.MethodGet, http.MethodPut, http.MethodDelete, http.MethodPost},
+	"/admin/employees/": {http.MethodDelete},
+	"/reviews":          {http.MethodGet, http.MethodPost},
+	"/reviews/":         {http.MethodGet, http.MethodPut},
+	"/payroll":          {http.MethodGet, http.MethodPost},
+	"/payroll/":         {http.MethodGet, http.MethodPost},
+}
+
+// corsAllowedHeaders are the request headers browsers may send cross-origin.
+var corsAllowedHeaders = []string{"Authorization", "Content-Type", "If-Match", "If-None-Match", "Idempotency-Key"}
+
+// corsExposedHeaders are the response headers scripts may read.
+var corsExposedHeaders = []string{"WWW-Authenticate", "ETag", "Idempotent-Replayed", "Link", "X-Total-Count"}
+
+// CORSPolicy decides which browser origins may call the API. Origins are
+// either exact ("https://app.example.com") or wildcard subdomains
+// ("https://*.example.com"), which match any host below the domain but not
+// the domain itself.
+type CORSPolicy struct {
+	exact            map[string]struct{}
+	wildcards        []originPattern
+	allowCredentials bool
+	maxAge           time.Duration
+}
+
+type originPattern struct {
+	scheme string
+	suffix string // ".example.com"
+	port   string
+}
+
+// NewCORSPolicy validates the configured origins. A bare "*" is rejected:
+// credentials can never be combined with it.
+func NewCORSPolicy(origins []string, allowCredentials bool, maxAge time.Duration) (*CORSPolicy, error) {
+	p := &CORSPolicy{exact: map[string]struct{}{}, allowCredentials: allowCredentials, maxAge: maxAge}
+	for _, raw := range origins {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		scheme, host, port, err := splitOrigin(strings.TrimSuffix(raw, "/"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid CORS origin %q: %w", raw, err)
+		}
+		if rest, ok := strings.CutPrefix(host, "*."); ok {
+			if rest == "" || strings.Contains(rest, "*") {
+				return nil, fmt.Errorf("invalid CORS origin %q: wildcard must be a leading subdomain", raw)
+			}
+			p.wildcards = append(p.wildcards, originPattern{scheme: scheme, suffix: "." + rest, port: port})
+			continue
+		}
+		if strings.Contains(host, "*") {
+			return nil, fmt.Errorf("invalid CORS origin %q: wildcard must be a leading subdomain", raw)
+		}
+		p.exact[joinOrigin(scheme, host, port)] = struct{}{}
+	}
+	return p, nil
+}
+
+// splitOrigin parses "scheme://host[:port]" and lowercases scheme and host.
+func splitOrigin(origin string) (scheme, host, port string, err error) {
+	u, err := url.Parse(origin)
+	if err != nil {
+		return "", "", "", err
+	}
+	scheme = strings.ToLower(u.Scheme)
+	if scheme != "http" && scheme != "https" {
+		return "", "", "", fmt.Errorf("scheme must be http or https")
+	}
+	if u.Host == "" || u.Path != "" || u.RawQuery != "" || u.User != nil {
+		return "", "", "", fmt.Errorf("must be scheme://host[:port]")
+	}
+	return scheme, strings.ToLower(u.Hostname()), u.Port(), nil
+}
+
+func joinOrigin(scheme, host, port string) string {
+	if port != "" {
+		host += ":" + port
+	}
+	return scheme + "://" + host
+}
+
+// allows reports whether a request Origin header matches the policy.
+func (p *CORSPolicy) allows(origin string) bool {
+	scheme, host, port, err := splitOrigin(origin)
+	if err != nil {
+		return false
+	}
+	if _, ok := p.exact[joinOrigin(scheme, host, port)]; ok {
+		return true
+	}
+	for _, w := range p.wildcards {
+		if w.scheme == scheme && w.port == port && strings.HasSuffix(host, w.suffix) && len(host) > len(w.suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// routeMethods finds the methods for a path, preferring an exact route over
+// the longest matching prefix route, as ServeMux does.
+func routeMethods(path string) []string {
+	if methods, ok := corsRouteMethods[path]; ok {
+		return methods
+	}
+	best := ""
+	for route := range corsRouteMethods {
+		if strings.HasSuffix(route, "/") && strings.HasPrefix(path, route) && len(route) > len(best) {
+			best = route
+		}
+	}
+	return corsRouteMethods[best]
+}
+
+// withCORS applies the policy. Requests without an Origin header are not
+// cross-origin and pass through untouched; requests from origins outside the
+// allowlist are refused so cookie-based auth cannot be abused cross-site.
+func withCORS(policy *CORSPolicy, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		requested := r.Header.Get("Access-Control-Request-Method")
+		if origin == "" {
+			if r.Method == http.MethodOptions {
+				writeAllow(w, r.URL.Path)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Origin")
+		if !policy.allows(origin) {
+			setJSON(w)
+			writeError(w, http.StatusForbidden, "origin not allowed")
+			return
+		}
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		if policy.allowCredentials {
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if r.Method != http.MethodOptions {
+			w.Header().Set("Access-Control-Expose-Headers", strings.Join(corsExposedHeaders, ", "))
+			next.ServeHTTP(w, r)
+			return
+		}
+		if requested == "" {
+			writeAllow(w, r.URL.Path)
+			return
+		}
+
+		// Preflight: only the route's own methods and the known headers pass.
+		w.Header().Add("Vary", "Access-Control-Request-Method")
+		w.Header().Add("Vary", "Access-Control-Request-Headers")
+		methods := routeMethods(r.URL.Path)
+		if !slices.Contains(methods, requested) {
+			setJSON(w)
+			writeError(w, http.StatusForbidden, "method not allowed for this route")
+			return
+		}
+		for _, h := range strings.Split(r.Header.Get("Access-Control-Request-Headers"), ",") {
+			h = strings.TrimSpace(h)
+			if h != "" && !slices.ContainsFunc(corsAllowedHeaders, func(allowed string) bool { return strings.EqualFold(allowed, h) }) {
+				setJSON(w)
+				writeError(w, http.StatusForbidden, "header not allowed: "+h)
+				return
+			}
+		}
+		w.Header().Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+		w.Header().Set("Access-Control-Allow-Headers", strings.Join(corsAllowedHeaders, ", "))
+		// Browsers cache the preflight for this long instead of repeating it per request.
+		w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(policy.maxAge.Seconds())))
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// writeAllow answers a plain OPTIONS request with the route's methods.
+func writeAllow(w http.ResponseWriter, path string) {
+	w.Header().Set("Allow", strings.Join(slices.Concat(routeMethods(path), []string{http.MethodOptions}), ", "))
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestCORS(t *testing.T, next http.Handler) http.Handler {
+	t.Helper()
+	policy, err := NewCORSPolicy([]string{"https://app.example.com", "https://*.preview.example.com", "http://localhost:3000/"}, true, 10*time.Minute)
+	if err != nil {
+		t.Fatalf("new policy: %v", err)
+	}
+	return withCORS(policy, next)
+}
+
+func corsRequest(handler http.Handler, method, path, origin string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestNewCORSPolicy_rejectsInvalidOrigins(t *testing.T) {
+	for _, origin := range []string{"*", "https://*", "https://api.*.example.com", "ftp://example.com", "https://example.com/path", "example.com"} {
+		if _, err := NewCORSPolicy([]string{origin}, true, time.Minute); err == nil {
+			t.Fatalf("expected %q to be rejected", origin)
+		}
+	}
+}
+
+func TestCORSPolicy_allows(t *testing.T) {
+	policy, err := NewCORSPolicy([]string{"https://app.example.com", "https://*.preview.example.com", "http://localhost:3000/"}, true, time.Minute)
+	if err != nil {
+		t.Fatalf("new policy: %v", err)
+	}
+	cases := map[string]bool{
+		"https://app.example.com":                true,
+		"HTTPS://App.Example.com":                true,
+		"http://localhost:3000":                  true,
+		"https://pr-12.preview.example.com":      true,
+		"https://a.b.preview.example.com":        true,
+		"https://preview.example.com":            false,
+		"https://evilpreview.example.com":        false,
+		"http://pr-12.preview.example.com":       false,
+		"https://pr-12.preview.example.com:8443": false,
+		"http://app.example.com":                 false,
+		"https://app.example.com.evil.com":       false,
+		"http://localhost:3001":                  false,
+		"null":                                   false,
+	}
+	for origin, want := range cases {
+		if got := policy.allows(origin); got != want {
+			t.Errorf("allows(%q) = %v, want %v", origin, got, want)
+		}
+	}
+}
+
+func TestCORSPolicyFromEnv_requiresOriginsOutsideDevMode(t *testing.T) {
+	t.Setenv("CORS_ALLOWED_ORIGINS", "") // restores the variable afterwards
+	os.Unsetenv("CORS_ALLOWED_ORIGINS")
+	t.Setenv("APP_ENV", "")
+	if _, err := corsPolicyFromEnv(); err == nil {
+		t.Fatalf("expected missing origins to be refused")
+	}
+	t.Setenv("APP_ENV", "development")
+	policy, err := corsPolicyFromEnv()
+	if err != nil || !policy.allows("http://localhost:3000") {
+		t.Fatalf("expected dev mode to allow the local frontend, got %v", err)
+	}
+	t.Setenv("APP_ENV", "")
+	t.Setenv("CORS_ALLOWED_ORIGINS", "https://app.example.com")
+	if policy, err := corsPolicyFromEnv(); err != nil || !policy.allows("https://app.example.com") {
+		t.Fatalf("expected the configured origin to be allowed, got %v", err)
+	}
+}
+
+func TestCORS_preflight(t *testing.T) {
+	store, mux := setupTestServer(t)
+	defer store.Close()
+	handler := newTestCORS(t, mux)
+
+	rr := corsRequest(handler, http.MethodOptions, "/employees/3", "https://app.example.com", map[string]string{
+		"Access-Control-Request-Method":  http.MethodPut,
+		"Access-Control-Request-Headers": "authorization, content-type",
+	})
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", rr.Code)
+	}
+	h := rr.Header()
+	if h.Get("Access-Control-Allow-Origin") != "https://app.example.com" || h.Get("Access-Control-Allow-Credentials") != "true" {
+		t.Fatalf("unexpected origin headers %v", h)
+	}
+	if h.Get("Access-Control-Allow-Methods") != "GET, PUT, DELETE, POST" {
+		t.Fatalf("expected the route's methods, got %q", h.Get("Access-Control-Allow-Methods"))
+	}
+	if h.Get("Access-Control-Max-Age") != "600" {
+		t.Fatalf("expected preflight to be cacheable, got %q", h.Get("Access-Control-Max-Age"))
+	}
+	if !strings.Contains(strings.Join(h.Values("Vary"), ","), "Origin") {
+		t.Fatalf("expected Vary: Origin, got %v", h.Values("Vary"))
+	}
+
+	if rr := corsRequest(handler, http.MethodOptions, "/reviews", "https://app.example.com", map[string]string{
+		"Access-Control-Request-Method": http.MethodDelete,
+	}); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for a method the route does not serve, got %d", rr.Code)
+	}
+	if rr := corsRequest(handler, http.MethodOptions, "/reviews", "https://app.example.com", map[string]string{
+		"Access-Control-Request-Method":  http.MethodGet,
+		"Access-Control-Request-Headers": "X-Debug",
+	}); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for an unknown header, got %d", rr.Code)
+	}
+}
+
+func TestCORS_rejectedOrigins(t *testing.T) {
+	store, mux := setupTestServer(t)
+	defer store.Close()
+	handler := newTestCORS(t, mux)
+
+	for _, origin := range []string{"https://evil.example.com", "https://preview.example.com", "null"} {
+		pre := corsRequest(handler, http.MethodOptions, "/employees", origin, map[string]string{"Access-Control-Request-Method": http.MethodGet})
+		if pre.Code != http.StatusForbidden || pre.Header().Get("Access-Control-Allow-Origin") != "" {
+			t.Fatalf("preflight from %q: expected 403 without allow headers, got %d %v", origin, pre.Code, pre.Header())
+		}
+		rr := corsRequest(handler, http.MethodGet, "/employees", origin, nil)
+		if rr.Code != http.StatusForbidden || rr.Header().Get("Access-Control-Allow-Origin") != "" {
+			t.Fatalf("request from %q: expected 403 without allow headers, got %d", origin, rr.Code)
+		}
+	}
+}
+
+func TestCORS_actualRequests(t *testing.T) {
+	store, mux := setupTestServer(t)
+	defer store.Close()
+	handler := newTestCORS(t, mux)
+
+	rr := corsRequest(handler, http.MethodGet, "/employees", "https://pr-7.preview.example.com", nil)
+	if rr.Code != http.StatusOK || rr.Header().Get("Access-Control-Allow-Origin") != "https://pr-7.preview.example.com" {
+		t.Fatalf("expected allowed wildcard origin, got %d %v", rr.Code, rr.Header())
+	}
+	if rr.Header().Get("Access-Control-Expose-Headers") == "" {
+		t.Fatalf("expected exposed headers")
+	}
+
+	// Same-origin and non-browser clients send no Origin and get no CORS headers.
+	rr = corsRequest(handler, http.MethodGet, "/employees", "", nil)
+	if rr.Code != http.StatusOK || rr.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatalf("expected plain response, got %d %v", rr.Code, rr.Header())
+	}
+	rr = corsRequest(handler, http.MethodOptions, "/payroll/1", "", nil)
+	if rr.Code != http.StatusNoContent || rr.Header().Get("Allow") != "GET, POST, OPTIONS" {
+		t.Fatalf("expected Allow header, got %d %v", rr.Code, rr.Header())
+	}
+}
+
+func TestCORS_withoutCredentials(t *testing.T) {
+	policy, err := NewCORSPolicy([]string{"https://app.example.com"}, false, time.Minute)
+	if err != nil {
+		t.Fatalf("new policy: %v", err)
+	}
+	handler := withCORS(policy, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	rr := corsRequest(handler, http.MethodGet, "/employees", "https://app.example.com", nil)
+	if rr.Header().Get("Access-Control-Allow-Credentials") != "" {
+		t.Fatalf("expected no credentials header, got %v", rr.Header())
+	}
+}
+
+// recordingMux notes every pattern RegisterRoutes registers.
+type recordingMux struct {
+	*http.ServeMux
+	patterns []string
+}
+
+func (m *recordingMux) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	m.patterns = append(m.patterns, pattern)
+	m.ServeMux.HandleFunc(pattern, handler)
+}
+
+func TestCORSRouteMethods_matchRegisteredRoutes(t *testing.T) {
+	store, err := NewStore(":memory:")
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	defer store.Close()
+	if err := store.Migrate(); err != nil {
+		t.Fatalf("migrate store: %v", err)
+	}
+	mux := &recordingMux{ServeMux: http.NewServeMux()}
+	NewAPI(store, NewTokenSigner([]byte("test-secret"), time.Hour)).RegisterRoutes(mux)
+	handler := withTestPrincipal(mux, Principal{User: User{ID: 1, Role: RoleHR}})
+
+	registered := slices.Sorted(slices.Values(mux.patterns))
+	listed := slices.Sorted(maps.Keys(corsRouteMethods))
+	if !slices.Equal(registered, listed) {
+		t.Fatalf("corsRouteMethods lists %v but RegisterRoutes registers %v", listed, registered)
+	}
+
+	// Prefix routes are probed on an id and on each action below it; a method
+	// the route answers must get past the 405 on at least one of them. Actions
+	// a route lacks fail id parsing instead, which does not count.
+	for pattern, methods := range corsRouteMethods {
+		paths := []string{pattern}
+		if strings.HasSuffix(pattern, "/") {
+			paths = []string{pattern + "1"}
+			for _, action := range []string{"restore", "history", "status", "void", "correct"} {
+				paths = append(paths, pattern+"1/"+action)
+			}
+		}
+		for _, method := range []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete} {
+			answered := false
+			for _, path := range paths {
+				rr := corsRequest(handler, method, path, "", nil)
+				if rr.Code != http.StatusMethodNotAllowed && !strings.Contains(rr.Body.String(), "invalid id") {
+					answered = true
+				}
+			}
+			if want := slices.Contains(methods, method); answered != want {
+				t.Errorf("%s %s: corsRouteMethods says %v, but the route answering is %v", method, pattern, want, answered)
+			}
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// maxBodyBytes caps every request body. The largest legitimate payload is a
+// few hundred bytes, so this only stops abuse.
+const maxBodyBytes = 1 << 20
+
+const (
+	codeUnsupportedMediaType = "unsupported_media_type"
+	codePayloadTooLarge      = "payload_too_large"
+	codeUnknownField         = "unknown_field"
+	codeTrailingData         = "trailing_data"
+)
+
+// decodeJSON reads a single JSON value from the request body into dst. The
+// body must be declared as application/json, fit in maxBodyBytes and hold only
+// fields dst knows about. On failure it writes the problem response and
+// returns false, so handlers only need to return.
+func decodeJSON(w http.ResponseWriter, r *http.Request, dst any) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		writeErrorCode(w, http.StatusUnsupportedMediaType, codeUnsupportedMediaType, "Content-Type must be application/json")
+		return false
+	}
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(dst); err != nil {
+		writeDecodeError(w, err)
+		return false
+	}
+	// A second value, or anything but whitespace, after the first one is
+	// rejected rather than ignored.
+	if err := dec.Decode(&json.RawMessage{}); !errors.Is(err, io.EOF) {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeDecodeError(w, err)
+			return false
+		}
+		writeErrorCode(w, http.StatusUnprocessableEntity, codeTrailingData, "request body must contain a single JSON value")
+		return false
+	}
+	return true
+}
+
+func writeDecodeError(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeErrorCode(w, http.StatusRequestEntityTooLarge, codePayloadTooLarge, "request body must not exceed 1 MiB")
+		return
+	}
+	// encoding/json has no typed error for unknown fields, only this message.
+	if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		field = strings.Trim(field, `"`)
+		writeProblem(w, Problem{
+			Status: http.StatusUnprocessableEntity,
+			Code:   codeUnknownField,
+			Detail: "unknown field " + field,
+			Errors: []FieldError{{Field: field, Code: codeUnknownField, Message: "unknown field " + field}},
+		})
+		return
+	}
+	writeInvalidPayload(w)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDecodeJSON_RejectsMalformedRequests(t *testing.T) {
+	store, mux := setupTestServer(t)
+	defer store.Close()
+
+	cases := []struct {
+		name        string
+		contentType string
+		body        string
+		status      int
+		code        string
+	}{
+		{"missing content type", "", `{"name":"A"}`, http.StatusUnsupportedMediaType, codeUnsupportedMediaType},
+		{"form content type", "application/x-www-form-urlencoded", `name=A`, http.StatusUnsupportedMediaType, codeUnsupportedMediaType},
+		{"unknown field", "application/json", `{"name":"A","nmae":"B"}`, http.StatusUnprocessableEntity, codeUnknownField},
+		{"trailing garbage", "application/json", `{"name":"A"} garbage`, http.StatusUnprocessableEntity, codeTrailingData},
+		{"second value", "application/json", `{"name":"A"}{"name":"B"}`, http.StatusUnprocessableEntity, codeTrailingData},
+		{"too large", "application/json", `{"name":"` + strings.Repeat("a", maxBodyBytes) + `"}`, http.StatusRequestEntityTooLarge, codePayloadTooLarge},
+		{"syntax error", "application/json", `{`, http.StatusUnprocessableEntity, codeInvalidPayload},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodPost, "/employees", strings.NewReader(tc.body))
+		if tc.contentType != "" {
+			req.Header.Set("Content-Type", tc.contentType)
+		}
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		if rr.Code != tc.status {
+			t.Fatalf("%s: expected %d, got %d body %s", tc.name, tc.status, rr.Code, rr.Body.String())
+		}
+		if p := decodeProblem(t, rr.Body.Bytes()); p.Code != tc.code {
+			t.Fatalf("%s: expected code %q, got %+v", tc.name, tc.code, p)
+		}
+	}
+	list, err := store.ListEmployees(EmployeeFilter{})
+	if err != nil {
+		t.Fatalf("list employees: %v", err)
+	}
+	if len(list) != 0 {
+		t.Fatalf("expected no employee to be created, got %+v", list)
+	}
+}
+
+func TestDecodeJSON_UnknownFieldIsNamed(t *testing.T) {
+	store, mux := setupTestServer(t)
+	defer store.Close()
+	emp := mustCreateEmployee(t, store, "Alice")
+
+	resp := doJSON(t, mux, http.MethodPost, "/payroll", map[string]any{"employeeId": emp.ID, "period": "2024-11", "baseSallary": 1000})
+	if resp.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d", resp.Code)
+	}
+	p := decodeProblem(t, resp.Body.Bytes())
+	if len(p.Errors) != 1 || p.Errors[0].Field != "baseSallary" {
+		t.Fatalf("expected the misspelled field to be named, got %+v", p)
+	}
+}
+
+func TestDecodeJSON_AcceptsCharsetParameter(t *testing.T) {
+	store, mux := setupTestServer(t)
+	defer store.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/employees", strings.NewReader(`{"name":"A"}`+"\n"))
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d body %s", rr.Code, rr.Body.String())
+	}
+}
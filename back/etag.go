@@ -0,0 +1,64 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// versionETag is the strong entity tag for a row version, e.g. "3".
+func versionETag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// derivedETag tags a representation that embeds data from other rows. It keeps
+// the version in front, so the tag still works with If-Match, and adds a short
+// hash of the extra data so GET revalidation notices when only that changed.
+func derivedETag(version int64, extra any) string {
+	b, err := json.Marshal(extra)
+	if err != nil {
+		return versionETag(version)
+	}
+	sum := sha256.Sum256(b)
+	return fmt.Sprintf(`"%d-%x"`, version, sum[:4])
+}
+
+// ifMatchVersion reads the version a write is conditioned on. No header, or
+// "*", means no condition and returns 0. ok is false when the header can never
+// match, such as a weak or malformed tag, and the caller should answer 412.
+func ifMatchVersion(r *http.Request) (version int64, ok bool) {
+	v := strings.TrimSpace(r.Header.Get("If-Match"))
+	if v == "" || v == "*" {
+		return 0, true
+	}
+	if len(v) < 2 || v[0] != '"' || v[len(v)-1] != '"' {
+		return 0, false
+	}
+	tag, _, _ := strings.Cut(v[1:len(v)-1], "-")
+	version, err := strconv.ParseInt(tag, 10, 64)
+	if err != nil || version < 1 {
+		return 0, false
+	}
+	return version, true
+}
+
+// notModified sets the ETag and answers 304 when If-None-Match already holds
+// it. GET revalidation uses weak comparison, so W/ prefixes are ignored.
+func notModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	w.Header().Set("ETag", etag)
+	for _, tag := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
+
+func writePreconditionFailed(w http.ResponseWriter) {
+	writeError(w, http.StatusPreconditionFailed, "resource was modified; fetch it again and retry")
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIfMatchVersion(t *testing.T) {
+	cases := []struct {
+		header  string
+		version int64
+		ok      bool
+	}{
+		{"", 0, true},
+		{"*", 0, true},
+		{`"3"`, 3, true},
+		{`"3-1a2b3c4d"`, 3, true},
+		{`W/"3"`, 0, false},
+		{`3`, 0, false},
+		{`"0"`, 0, false},
+		{`"abc"`, 0, false},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodPut, "/", nil)
+		if tc.header != "" {
+			req.Header.Set("If-Match", tc.header)
+		}
+		version, ok := ifMatchVersion(req)
+		if version != tc.version || ok != tc.ok {
+			t.Errorf("If-Match %q: expected (%d, %v), got (%d, %v)", tc.header, tc.version, tc.ok, version, ok)
+		}
+	}
+}
+
+func TestEmployeeETagAndIfMatch(t *testing.T) {
+	store, mux := setupTestServer(t)
+	defer store.Close()
+	emp := mustCreateEmployee(t, store, "Alice")
+
+	resp := doJSON(t, mux, http.MethodGet, "/employees/1", nil)
+	etag := resp.Header().Get("ETag")
+	if resp.Code != http.StatusOK || etag == "" {
+		t.Fatalf("expected 200 with an ETag, got %d %q", resp.Code, etag)
+	}
+	resp = doJSONWithHeaders(t, mux, http.MethodGet, "/employees/1", map[string]string{"If-None-Match": etag}, nil)
+	if resp.Code != http.StatusNotModified || resp.Body.Len() != 0 {
+		t.Fatalf("expected 304 without a body, got %d %s", resp.Code, resp.Body.String())
+	}
+
+	resp = doJSONWithHeaders(t, mux, http.MethodPut, "/employees/1", map[string]string{"If-Match": etag}, map[string]any{"department": "Sales"})
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected 200 with a fresh ETag, got %d %s", resp.Code, resp.Body.String())
+	}
+	if got := resp.Header().Get("ETag"); got != `"2"` {
+		t.Fatalf("expected ETag \"2\", got %q", got)
+	}
+
+	// A second writer still holding the first ETag loses.
+	resp = doJSONWithHeaders(t, mux, http.MethodPut, "/employees/1", map[string]string{"If-Match": etag}, map[string]any{"department": "Ops"})
+	if resp.Code != http.StatusPreconditionFailed {
+		t.Fatalf("expected 412 for a stale ETag, got %d", resp.Code)
+	}
+	current, err := store.GetEmployee(emp.ID)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if current.Department != "Sales" || current.Version != 2 {
+		t.Fatalf("stale write was applied: %+v", current)
+	}
+
+	resp = doJSONWithHeaders(t, mux, http.MethodGet, "/employees/1", map[string]string{"If-None-Match": etag}, nil)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected 200 after a change, got %d", resp.Code)
+	}
+
+	// Without If-Match the update is unconditional, as before.
+	resp = doJSON(t, mux, http.MethodPut, "/employees/1", map[string]any{"department": "Ops"})
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected 200 without If-Match, got %d", resp.Code)
+	}
+}
+
+func TestEmployeeETagChangesWithSummaries(t *testing.T) {
+	store, mux := setupTestServer(t)
+	defer store.Close()
+	emp := mustCreateEmployee(t, store, "Alice")
+
+	etag := doJSON(t, mux, http.MethodGet, "/employees/1", nil).Header().Get("ETag")
+	mustCreateReview(t, store, emp.ID)
+	resp := doJSONWithHeaders(t, mux, http.MethodGet, "/employees/1", map[string]string{"If-None-Match": etag}, nil)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected 200 once the review summary changed, got %d", resp.Code)
+	}
+	// The employee row itself did not change, so writes with the old tag still apply.
+	resp = doJSONWithHeaders(t, mux, http.MethodPut, "/employees/1", map[string]string{"If-Match": etag}, map[string]any{"jobTitle": "Lead"})
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.Code)
+	}
+}
+
+func TestReviewETagAndIfMatch(t *testing.T) {
+	store, mux := setupTestServer(t)
+	defer store.Close()
+	emp := mustCreateEmployee(t, store, "Alice")
+	mustCreateReview(t, store, emp.ID)
+
+	resp := doJSON(t, mux, http.MethodGet, "/reviews/1", nil)
+	etag := resp.Header().Get("ETag")
+	if etag != `"1"` {
+		t.Fatalf("expected ETag \"1\", got %q", etag)
+	}
+	if resp := doJSONWithHeaders(t, mux, http.MethodGet, "/reviews/1", map[string]string{"If-None-Match": `W/"1"`}, nil); resp.Code != http.StatusNotModified {
+		t.Fatalf("expected 304, got %d", resp.Code)
+	}
+
+	resp = doJSONWithHeaders(t, mux, http.MethodPut, "/reviews/1", map[string]string{"If-Match": etag}, map[string]any{"rating": 5})
+	if resp.Code != http.StatusOK || resp.Header().Get("ETag") != `"2"` {
+		t.Fatalf("expected 200 with ETag \"2\", got %d %q", resp.Code, resp.Header().Get("ETag"))
+	}
+	resp = doJSONWithHeaders(t, mux, http.MethodPut, "/reviews/1", map[string]string{"If-Match": etag}, map[string]any{"rating": 1})
+	if resp.Code != http.StatusPreconditionFailed {
+		t.Fatalf("expected 412 for a stale update, got %d", resp.Code)
+	}
+	resp = doJSONWithHeaders(t, mux, http.MethodPut, "/reviews/1/status", map[string]string{"If-Match": etag}, map[string]string{"state": ReviewStateSubmitted})
+	if resp.Code != http.StatusPreconditionFailed {
+		t.Fatalf("expected 412 for a stale transition, got %d", resp.Code)
+	}
+	resp = doJSONWithHeaders(t, mux, http.MethodPut, "/reviews/1/status", map[string]string{"If-Match": `W/"2"`}, map[string]string{"state": ReviewStateSubmitted})
+	if resp.Code != http.StatusPreconditionFailed {
+		t.Fatalf("expected 412 for a weak tag, got %d", resp.Code)
+	}
+	resp = doJSONWithHeaders(t, mux, http.MethodPut, "/reviews/1/status", map[string]string{"If-Match": `"2"`}, map[string]string{"state": ReviewStateSubmitted})
+	if resp.Code != http.StatusOK || resp.Header().Get("ETag") != `"3"` {
+		t.Fatalf("expected 200 with ETag \"3\", got %d %q", resp.Code, resp.Header().Get("ETag"))
+	}
+}
+
+func TestPayrollETag(t *testing.T) {
+	store, mux := setupTestServer(t)
+	defer store.Close()
+	emp := mustCreateEmployee(t, store, "Alice")
+	if _, err := store.CreatePayrollRecord(PayrollRecordInput{EmployeeID: emp.ID, Period: "2024-11", BaseSalary: 100000}); err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+
+	etag := doJSON(t, mux, http.MethodGet, "/payroll/1", nil).Header().Get("ETag")
+	if resp := doJSONWithHeaders(t, mux, http.MethodGet, "/payroll/1", map[string]string{"If-None-Match": etag}, nil); resp.Code != http.StatusNotModified {
+		t.Fatalf("expected 304, got %d", resp.Code)
+	}
+	if resp := doJSON(t, mux, http.MethodPost, "/payroll/1/void", map[string]string{"reason": "typo"}); resp.Code != http.StatusOK {
+		t.Fatalf("expected 200 void, got %d %s", resp.Code, resp.Body.String())
+	}
+	if resp := doJSONWithHeaders(t, mux, http.MethodGet, "/payroll/1", map[string]string{"If-None-Match": etag}, nil); resp.Code != http.StatusOK {
+		t.Fatalf("expected 200 after voiding, got %d", resp.Code)
+	}
+}
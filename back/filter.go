@@ -0,0 +1,208 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// column is a SQL column reference. Only constants declared in this package
+// have this type, so request data can reach a query as an argument but never
+// as SQL text.
+type column string
+
+// Range bounds a value on either side. A nil bound is open; both are inclusive.
+type Range[T any] struct {
+	Min *T
+	Max *T
+}
+
+// Exactly is the range holding only v.
+func Exactly[T any](v T) Range[T] {
+	return Range[T]{Min: &v, Max: &v}
+}
+
+func (r Range[T]) apply(w *where, c column) {
+	if r.Min != nil {
+		w.add(string(c)+" >= ?", *r.Min)
+	}
+	if r.Max != nil {
+		w.add(string(c)+" <= ?", *r.Max)
+	}
+}
+
+// where builds a WHERE clause from typed conditions joined with AND.
+type where struct {
+	clauses []string
+	args    []any
+}
+
+// add appends a condition. clause must be built from columns and constants only.
+func (w *where) add(clause string, args ...any) {
+	w.clauses = append(w.clauses, clause)
+	w.args = append(w.args, args...)
+}
+
+func (w *where) eq(c column, v any) {
+	w.add(string(c)+" = ?", v)
+}
+
+// in matches any of values; an empty list adds no condition.
+func in[T any](w *where, c column, values []T) {
+	if len(values) == 0 {
+		return
+	}
+	args := make([]any, len(values))
+	for i, v := range values {
+		args[i] = v
+	}
+	w.add(string(c)+" IN ("+placeholders(len(values))+")", args...)
+}
+
+// contains matches a case-insensitive substring; an empty string adds no condition.
+func (w *where) contains(c column, s string) {
+	if s == "" {
+		return
+	}
+	escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+	w.add(string(c)+` LIKE ? ESCAPE '\'`, "%"+escaped+"%")
+}
+
+// String is the clause with its leading " WHERE ", or "" without conditions.
+func (w *where) String() string {
+	if len(w.clauses) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(w.clauses, " AND ")
+}
+
+// Query string filters are written field, operator, value:
+//
+//	employeeId=1,2,3   one of several values
+//	period>=2024-01    inclusive lower bound
+//	period<=2024-06    inclusive upper bound
+//	name~=ali          case-insensitive substring
+var filterTermPattern = regexp.MustCompile(`^([A-Za-z]+)(>=|<=|~=|!=|=|>|<)(.*)$`)
+
+// filterTermName is the part of a term before its operator.
+var filterTermName = regexp.MustCompile(`^[^<>=~!]*`)
+
+// filterField is one field a list endpoint can be filtered on.
+type filterField struct {
+	ops   []string
+	apply func(op, value string) error
+}
+
+// parseFilterQuery applies every condition in the query string to the fields
+// they name. It reads the raw query because url.Values would split
+// period>=2024-01 at the "=" inside the operator. Terms that name no filter,
+// such as the paging parameters or a cache buster, are left alone.
+func parseFilterQuery(r *http.Request, fields map[string]filterField) error {
+	var v ValidationError
+	for _, part := range strings.Split(r.URL.RawQuery, "&") {
+		if part == "" {
+			continue
+		}
+		term, err := url.QueryUnescape(part)
+		if err != nil {
+			v.add(part, FieldInvalidFormat, "query term is not valid URL encoding")
+			continue
+		}
+		field, ok := fields[filterTermName.FindString(term)]
+		if !ok {
+			continue
+		}
+		m := filterTermPattern.FindStringSubmatch(term)
+		if m == nil {
+			v.add(term, FieldInvalidFormat, "filters are written field, operator, value, e.g. period>=2024-01")
+			continue
+		}
+		name, op, value := m[1], m[2], m[3]
+		if !slices.Contains(field.ops, op) {
+			v.add(name, FieldInvalidFormat, fmt.Sprintf("%s supports %s", name, strings.Join(field.ops, " ")))
+			continue
+		}
+		if err := field.apply(op, value); err != nil {
+			v.add(name, FieldInvalidFormat, fmt.Sprintf("invalid %s: %v", name, err))
+		}
+	}
+	return v.orNil()
+}
+
+func idListField(dst *[]int64) filterField {
+	return filterField{ops: []string{"="}, apply: func(_, value string) error {
+		for _, s := range strings.Split(value, ",") {
+			id, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+			if err != nil || id < 1 {
+				return fmt.Errorf("%q is not an id", s)
+			}
+			*dst = append(*dst, id)
+		}
+		return nil
+	}}
+}
+
+func stringListField(dst *[]string) filterField {
+	return filterField{ops: []string{"="}, apply: func(_, value string) error {
+		for _, s := range strings.Split(value, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				*dst = append(*dst, s)
+			}
+		}
+		return nil
+	}}
+}
+
+func boolField(dst *bool) filterField {
+	return filterField{ops: []string{"="}, apply: func(_, value string) error {
+		b, err := strconv.ParseBool(value)
+		*dst = b
+		return err
+	}}
+}
+
+// textField takes a free-text value, such as a search query, with plain =.
+func textField(dst *string) filterField {
+	return filterField{ops: []string{"="}, apply: func(_, value string) error {
+		*dst = strings.TrimSpace(value)
+		return nil
+	}}
+}
+
+func containsField(dst *string) filterField {
+	return filterField{ops: []string{"~="}, apply: func(_, value string) error {
+		*dst = strings.TrimSpace(value)
+		return nil
+	}}
+}
+
+// rangeField accepts = for an exact value and >= or <= for either bound.
+func rangeField[T any](dst *Range[T], parse func(string) (T, error)) filterField {
+	return filterField{ops: []string{"=", ">=", "<="}, apply: func(op, value string) error {
+		v, err := parse(strings.TrimSpace(value))
+		if err != nil {
+			return err
+		}
+		switch op {
+		case "=":
+			*dst = Exactly(v)
+		case ">=":
+			dst.Min = &v
+		case "<=":
+			dst.Max = &v
+		}
+		return nil
+	}}
+}
+
+func parseDate(s string) (string, error) {
+	if _, err := time.Parse(hireDateLayout, s); err != nil {
+		return "", fmt.Errorf("%q is not a YYYY-MM-DD date", s)
+	}
+	return s, nil
+}
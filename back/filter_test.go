@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestListPayroll_RichFilters(t *testing.T) {
+	store, mux := setupTestServer(t)
+	defer store.Close()
+	alice := mustCreateEmployee(t, store, "Alice")
+	bob := mustCreateEmployee(t, store, "Bob")
+	carol := mustCreateEmployee(t, store, "Carol")
+	for _, emp := range []Employee{alice, bob, carol} {
+		for i, period := range []Period{"2023-12", "2024-01", "2024-02"} {
+			if _, err := store.CreatePayrollRecord(PayrollRecordInput{EmployeeID: emp.ID, Period: period, BaseSalary: Money(100000 * (i + 1))}); err != nil {
+				t.Fatalf("seed payroll: %v", err)
+			}
+		}
+	}
+
+	count := func(query string) int {
+		t.Helper()
+		resp := doJSON(t, mux, http.MethodGet, "/payroll?"+query, nil)
+		if resp.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d body %s", query, resp.Code, resp.Body.String())
+		}
+		var body struct {
+			Items []PayrollRecord `json:"items"`
+		}
+		if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil {
+			t.Fatalf("json: %v", err)
+		}
+		return len(body.Items)
+	}
+	cases := map[string]int{
+		"period>=2024-01":                 6,
+		"period>=2024-01&period<=2024-01": 3,
+		"period%3E%3D2024-01":             6,
+		"employeeId=1,2":                  6,
+		"employeeId=1,2&period=2023-12":   2,
+		"netPay>=2000":                    6,
+		"netPay<=1000&employeeName~=ro":   1,
+		"employeeName~=AL":                3,
+		"employeeName~=%25":               0,
+		"period<=2024-01&sort=-netPay":    6,
+	}
+	for query, want := range cases {
+		if got := count(query); got != want {
+			t.Errorf("%s: expected %d records, got %d", query, want, got)
+		}
+	}
+}
+
+func TestListReviews_RatingRangeAndStates(t *testing.T) {
+	store, mux := setupTestServer(t)
+	defer store.Close()
+	emp := mustCreateEmployee(t, store, "Alice")
+	for i, reviewer := range []string{"A", "B", "C"} {
+		if _, err := store.CreatePerformanceReview(PerformanceReviewInput{EmployeeID: emp.ID, Period: "2024-Q4", Reviewer: reviewer, Rating: i + 2}); err != nil {
+			t.Fatalf("seed review: %v", err)
+		}
+	}
+	if _, err := store.TransitionPerformanceReview(1, ReviewStateSubmitted, "", 0); err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+
+	resp := doJSON(t, mux, http.MethodGet, "/reviews?rating>=3&state=draft,submitted", nil)
+	var body reviewListResponse
+	if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil {
+		t.Fatalf("json: %v", err)
+	}
+	if len(body.Items) != 2 || body.Page.Total != 2 {
+		t.Fatalf("expected the two reviews rated 3 and 4, got %+v", body.Items)
+	}
+}
+
+func TestListFilters_RejectBadTerms(t *testing.T) {
+	store, mux := setupTestServer(t)
+	defer store.Close()
+
+	cases := map[string]string{
+		"/payroll?period>2024-01":          "period",
+		"/payroll?netPay>=lots":            "netPay",
+		"/payroll?employeeId=1,x":          "employeeId",
+		"/reviews?rating~=4":               "rating",
+		"/employees?includeArchived=maybe": "includeArchived",
+		"/employees?name=Al":               "name",
+	}
+	for path, field := range cases {
+		resp := doJSON(t, mux, http.MethodGet, path, nil)
+		if resp.Code != http.StatusUnprocessableEntity {
+			t.Fatalf("%s: expected 422, got %d", path, resp.Code)
+		}
+		if p := decodeProblem(t, resp.Body.Bytes()); len(p.Errors) != 1 || p.Errors[0].Field != field {
+			t.Fatalf("%s: expected a %s error, got %+v", path, field, p)
+		}
+	}
+}
+
+func TestListFilters_IgnoreOtherParameters(t *testing.T) {
+	store, mux := setupTestServer(t)
+	defer store.Close()
+	emp := mustCreateEmployee(t, store, "Alice")
+	if _, err := store.CreatePayrollRecord(PayrollRecordInput{EmployeeID: emp.ID, Period: "2024-11", BaseSalary: 1000}); err != nil {
+		t.Fatalf("seed payroll: %v", err)
+	}
+
+	for _, path := range []string{"/payroll?_t=1718000000", "/payroll?salary=1&period>=2024-01", "/reviews?_t=", "/employees?flag"} {
+		if resp := doJSON(t, mux, http.MethodGet, path, nil); resp.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d body %s", path, resp.Code, resp.Body.String())
+		}
+	}
+	resp := doJSON(t, mux, http.MethodGet, "/payroll?_t=1&period>=2025-01", nil)
+	var list payrollList
+	if err := json.Unmarshal(resp.Body.Bytes(), &list); err != nil {
+		t.Fatalf("json: %v", err)
+	}
+	if len(list.Items) != 0 {
+		t.Fatalf("expected the period filter to still apply, got %+v", list.Items)
+	}
+}
+
+func TestListFilters_KeptInPageLinks(t *testing.T) {
+	store, mux := setupTestServer(t)
+	defer store.Close()
+	mustCreateEmployee(t, store, "Alice")
+	mustCreateEmployee(t, store, "Alan")
+	mustCreateEmployee(t, store, "Bob")
+
+	resp := doJSON(t, mux, http.MethodGet, "/employees?name~=al&limit=1", nil)
+	link := resp.Header().Get("Link")
+	if !strings.Contains(link, "name~=al") || resp.Header().Get("X-Total-Count") != "2" {
+		t.Fatalf("expected the filter to survive in the links, got %q", link)
+	}
+}
+
+func TestListEmployees_SearchAndProfileFilters(t *testing.T) {
+	store, mux := setupTestServer(t)
+	defer store.Close()
+	seed := []EmployeeInput{
+		{Name: "José Pérez", Department: "Sales", JobTitle: "Account Executive", HireDate: "2019-03-01"},
+		{Name: "Josefina Gómez", Department: "sales", JobTitle: "Sales Manager", HireDate: "2022-07-15", Status: EmploymentStatusOnLeave},
+		{Name: "Ana Núñez", Department: "Finance", JobTitle: "Analyst", HireDate: "2023-01-10"},
+	}
+	for _, input := range seed {
+		if _, err := store.CreateEmployee(input); err != nil {
+			t.Fatalf("seed employee: %v", err)
+		}
+	}
+
+	cases := map[string]int{
+		"q=jose":                      2,
+		"q=jos%C3%A9+p":               1,
+		"department=SALES":            2,
+		"department=sales,finance":    3,
+		"jobTitle~=manager":           1,
+		"status=on_leave":             1,
+		"hireDate>=2020-01-01":        2,
+		"q=jose&hireDate<=2020-01-01": 1,
+		"q=jose&department=Finance":   0,
+	}
+	for query, want := range cases {
+		resp := doJSON(t, mux, http.MethodGet, "/employees?"+query, nil)
+		if resp.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d body %s", query, resp.Code, resp.Body.String())
+		}
+		var list []Employee
+		if err := json.Unmarshal(resp.Body.Bytes(), &list); err != nil {
+			t.Fatalf("json: %v", err)
+		}
+		if len(list) != want {
+			t.Errorf("%s: expected %d employees, got %d", query, want, len(list))
+		}
+	}
+	if resp := doJSON(t, mux, http.MethodGet, "/employees?hireDate>=2020", nil); resp.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 for a partial date, got %d", resp.Code)
+	}
+}
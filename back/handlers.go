@@ -3,68 +3,215 @@ package main
 import (
 	"encoding/json"
 	"errors"
-	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
 )
 
 type API struct {
-	store *Store
+	store  *Store
+	tokens *TokenSigner
+	// anonymous is who requests without credentials act as; nil requires a token.
+	anonymous *Principal
 }
 
-func NewAPI(store *Store) *API {
-	return &API{store: store}
+func NewAPI(store *Store, tokens *TokenSigner) *API {
+	return &API{store: store, tokens: tokens}
+}
+
+// storeFor returns the store bound to the caller, so that mutations are
+// attributed to them in the audit log.
+func (a *API) storeFor(r *http.Request) *Store {
+	principal, _ := principalFrom(r.Context())
+	return a.store.WithActor(Actor{UserID: principal.User.ID, Email: principal.User.Email})
 }
 
 const internalErrorMsg = "internal error"
+const unknownEmployeeMsg = "employeeId does not match an existing employee"
+
+// routeRegistrar is the part of *http.ServeMux RegisterRoutes uses, so tests
+// can record the registered patterns.
+type routeRegistrar interface {
+	HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request))
+}
+
+func (a *API) RegisterRoutes(mux routeRegistrar) {
+	// The /auth routes only need a signed-in caller (login itself is public);
+	// every other route declares the permission each method requires.
+	mux.HandleFunc("/auth/login", func(w http.ResponseWriter, r *http.Request) {
+		setJSON(w)
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		a.handleLogin(w, r)
+	})
 
-func (a *API) RegisterRoutes(mux *http.ServeMux) {
-	mux.HandleFunc("/employees", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/auth/me", func(w http.ResponseWriter, r *http.Request) {
+		setJSON(w)
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		a.handleMe(w, r)
+	})
+
+	mux.HandleFunc("/auth/tokens", func(w http.ResponseWriter, r *http.Request) {
 		setJSON(w)
 		switch r.Method {
 		case http.MethodGet:
-			a.handleListEmployees(w, r)
+			a.handleListAPITokens(w, r)
 		case http.MethodPost:
-			a.handleCreateEmployee(w, r)
+			a.handleCreateAPIToken(w, r)
 		default:
 			w.WriteHeader(http.StatusMethodNotAllowed)
 		}
 	})
 
-	mux.HandleFunc("/employees/", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/auth/tokens/", func(w http.ResponseWriter, r *http.Request) {
+		setJSON(w)
+		if r.Method != http.MethodDelete {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		id, err := strconv.ParseInt(strings.TrimPrefix(r.URL.Path, "/auth/tokens/"), 10, 64)
+		if err != nil {
+			writeError(w, http.StatusUnprocessableEntity, "invalid id")
+			return
+		}
+		a.handleRevokeAPIToken(w, r, id)
+	})
+
+	mux.HandleFunc("/users", a.guard(methodPermissions{
+		http.MethodGet:  permManageUsers,
+		http.MethodPost: permManageUsers,
+	}, func(w http.ResponseWriter, r *http.Request) {
+		setJSON(w)
+		switch r.Method {
+		case http.MethodGet:
+			a.handleListUsers(w, r)
+		case http.MethodPost:
+			a.handleCreateUser(w, r)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+
+	mux.HandleFunc("/audit", a.guard(methodPermissions{
+		http.MethodGet: permReadAudit,
+	}, func(w http.ResponseWriter, r *http.Request) {
+		setJSON(w)
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		a.handleListAudit(w, r)
+	}))
+
+	mux.HandleFunc("/employees", a.guard(methodPermissions{
+		http.MethodGet:  permReadEmployees,
+		http.MethodPost: permWriteEmployees,
+	}, func(w http.ResponseWriter, r *http.Request) {
+		setJSON(w)
+		switch r.Method {
+		case http.MethodGet:
+			a.handleListEmployees(w, r)
+		case http.MethodPost:
+			a.idempotent(a.handleCreateEmployee)(w, r)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+
+	mux.HandleFunc("/employees/", a.guard(methodPermissions{
+		http.MethodGet:    permReadEmployees,
+		http.MethodPut:    permWriteEmployees,
+		http.MethodDelete: permWriteEmployees,
+		http.MethodPost:   permWriteEmployees,
+	}, func(w http.ResponseWriter, r *http.Request) {
 		setJSON(w)
-		idStr := strings.TrimPrefix(r.URL.Path, "/employees/")
-		id, err := strconv.ParseInt(idStr, 10, 64)
+		path := strings.TrimPrefix(r.URL.Path, "/employees/")
+		if strings.HasSuffix(path, "/restore") {
+			if r.Method != http.MethodPost {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			id, err := strconv.ParseInt(strings.TrimSuffix(path, "/restore"), 10, 64)
+			if err != nil {
+				writeError(w, http.StatusUnprocessableEntity, "invalid id")
+				return
+			}
+			a.handleRestoreEmployee(w, r, id)
+			return
+		}
+		id, err := strconv.ParseInt(path, 10, 64)
 		if err != nil {
 			writeError(w, http.StatusUnprocessableEntity, "invalid id")
 			return
 		}
 		switch r.Method {
+		case http.MethodGet:
+			a.handleGetEmployee(w, r, id)
 		case http.MethodPut:
 			a.handleUpdateEmployee(w, r, id)
 		case http.MethodDelete:
-			a.handleDeleteEmployee(w, id)
+			a.handleOffboardEmployee(w, r, id)
 		default:
 			w.WriteHeader(http.StatusMethodNotAllowed)
 		}
-	})
+	}))
+
+	// Hard deletes bypass offboarding and wipe history, so they live under /admin.
+	mux.HandleFunc("/admin/employees/", a.guard(methodPermissions{
+		http.MethodDelete: permPurgeEmployees,
+	}, func(w http.ResponseWriter, r *http.Request) {
+		setJSON(w)
+		if r.Method != http.MethodDelete {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		id, err := strconv.ParseInt(strings.TrimPrefix(r.URL.Path, "/admin/employees/"), 10, 64)
+		if err != nil {
+			writeError(w, http.StatusUnprocessableEntity, "invalid id")
+			return
+		}
+		a.handlePurgeEmployee(w, r, id)
+	}))
 
-	mux.HandleFunc("/reviews", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/reviews", a.guard(methodPermissions{
+		http.MethodGet:  permReadReviews,
+		http.MethodPost: permWriteReviews,
+	}, func(w http.ResponseWriter, r *http.Request) {
 		setJSON(w)
 		switch r.Method {
 		case http.MethodGet:
 			a.handleListReviews(w, r)
 		case http.MethodPost:
-			a.handleCreateReview(w, r)
+			a.idempotent(a.handleCreateReview)(w, r)
 		default:
 			w.WriteHeader(http.StatusMethodNotAllowed)
 		}
-	})
+	}))
 
-	mux.HandleFunc("/reviews/", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/reviews/", a.guard(methodPermissions{
+		http.MethodGet: permReadReviews,
+		http.MethodPut: permWriteReviews,
+	}, func(w http.ResponseWriter, r *http.Request) {
 		setJSON(w)
 		path := strings.TrimPrefix(r.URL.Path, "/reviews/")
+		if strings.HasSuffix(path, "/history") {
+			if r.Method != http.MethodGet {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			id, err := strconv.ParseInt(strings.TrimSuffix(path, "/history"), 10, 64)
+			if err != nil {
+				writeError(w, http.StatusUnprocessableEntity, "invalid id")
+				return
+			}
+			a.handleReviewHistory(w, r, id)
+			return
+		}
 		if strings.HasSuffix(path, "/status") {
 			if r.Method != http.MethodPut {
 				w.WriteHeader(http.StatusMethodNotAllowed)
@@ -80,103 +227,305 @@ func (a *API) RegisterRoutes(mux *http.ServeMux) {
 			a.handleTransitionReview(w, r, id)
 			return
 		}
-		if r.Method != http.MethodPut {
-			w.WriteHeader(http.StatusMethodNotAllowed)
-			return
-		}
 		id, err := strconv.ParseInt(path, 10, 64)
 		if err != nil {
 			writeError(w, http.StatusUnprocessableEntity, "invalid id")
 			return
 		}
-		a.handleUpdateReview(w, r, id)
-	})
+		switch r.Method {
+		case http.MethodGet:
+			a.handleGetReview(w, r, id)
+		case http.MethodPut:
+			a.handleUpdateReview(w, r, id)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
 
-	mux.HandleFunc("/payroll", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/payroll", a.guard(methodPermissions{
+		http.MethodGet:  permReadPayroll,
+		http.MethodPost: permWritePayroll,
+	}, func(w http.ResponseWriter, r *http.Request) {
 		setJSON(w)
 		switch r.Method {
 		case http.MethodGet:
 			a.handleListPayroll(w, r)
 		case http.MethodPost:
-			a.handleCreatePayroll(w, r)
+			a.idempotent(a.handleCreatePayroll)(w, r)
 		default:
 			w.WriteHeader(http.StatusMethodNotAllowed)
 		}
-	})
+	}))
+
+	mux.HandleFunc("/payroll/", a.guard(methodPermissions{
+		http.MethodGet:  permReadPayroll,
+		http.MethodPost: permWritePayroll,
+	}, func(w http.ResponseWriter, r *http.Request) {
+		setJSON(w)
+		path := strings.TrimPrefix(r.URL.Path, "/payroll/")
+		// Payroll records are never edited in place: changes go through void or correct.
+		for suffix, handle := range map[string]func(http.ResponseWriter, *http.Request, int64){
+			"/void":    a.handleVoidPayroll,
+			"/correct": a.handleCorrectPayroll,
+		} {
+			if !strings.HasSuffix(path, suffix) {
+				continue
+			}
+			if r.Method != http.MethodPost {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			id, err := strconv.ParseInt(strings.TrimSuffix(path, suffix), 10, 64)
+			if err != nil {
+				writeError(w, http.StatusUnprocessableEntity, "invalid id")
+				return
+			}
+			handle(w, r, id)
+			return
+		}
+		id, err := strconv.ParseInt(path, 10, 64)
+		if err != nil {
+			writeError(w, http.StatusUnprocessableEntity, "invalid id")
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			a.handleGetPayroll(w, r, id)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
 }
 
 func setJSON(w http.ResponseWriter) {
 	w.Header().Set("Content-Type", "application/json")
 }
 
-func writeError(w http.ResponseWriter, code int, msg string) {
-	w.WriteHeader(code)
-	_ = json.NewEncoder(w).Encode(map[string]string{"error": msg})
+type employeePayload struct {
+	Name       string `json:"name"`
+	Email      string `json:"email"`
+	Department string `json:"department"`
+	JobTitle   string `json:"jobTitle"`
+	HireDate   string `json:"hireDate"`
+	Status     string `json:"status"`
+	NationalID string `json:"nationalId"`
+	ManagerID  int64  `json:"managerId"`
 }
 
-type employeePayload struct {
-	Name string `json:"name"`
+type employeeUpdatePayload struct {
+	Name       *string `json:"name"`
+	Email      *string `json:"email"`
+	Department *string `json:"department"`
+	JobTitle   *string `json:"jobTitle"`
+	HireDate   *string `json:"hireDate"`
+	Status     *string `json:"status"`
+	NationalID *string `json:"nationalId"`
+	ManagerID  *int64  `json:"managerId"`
 }
 
-func (a *API) handleListEmployees(w http.ResponseWriter, _ *http.Request) {
-	list, err := a.store.ListEmployees()
+func (a *API) handleListEmployees(w http.ResponseWriter, r *http.Request) {
+	filter := EmployeeFilter{}
+	if err := parseFilterQuery(r, map[string]filterField{
+		"id":              idListField(&filter.IDs),
+		"name":            containsField(&filter.Name),
+		"q":               textField(&filter.Search),
+		"department":      stringListField(&filter.Departments),
+		"jobTitle":        containsField(&filter.JobTitle),
+		"status":          stringListField(&filter.Statuses),
+		"managerId":       idListField(&filter.ManagerIDs),
+		"hireDate":        rangeField(&filter.HireDate, parseDate),
+		"includeArchived": boolField(&filter.IncludeArchived),
+	}); err != nil {
+		writeErrorFor(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	req, err := parsePageRequest(r.URL.Query())
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, internalErrorMsg)
+		writeErrorFor(w, http.StatusUnprocessableEntity, err)
 		return
 	}
-	_ = json.NewEncoder(w).Encode(list)
+	principal, _ := principalFrom(r.Context())
+	filter.Scope = principal.employeeScope()
+	page, err := a.store.ListEmployeesPage(filter, req)
+	if err != nil {
+		writeListError(w, err)
+		return
+	}
+	// The body stays a bare array for existing clients; paging is in the headers.
+	writePageHeaders(w, r, page.PageInfo)
+	_ = json.NewEncoder(w).Encode(page.Items)
+}
+
+// writeListError answers 422 for a bad sort or cursor and 500 otherwise.
+func writeListError(w http.ResponseWriter, err error) {
+	if errors.As(err, new(*ValidationError)) {
+		writeErrorFor(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	writeError(w, http.StatusInternalServerError, internalErrorMsg)
+}
+
+// employeeDetailResponse leaves out the summaries the caller is not allowed to read.
+type employeeDetailResponse struct {
+	Employee
+	ReviewSummary  *employeeReviewSummary     `json:"reviewSummary,omitempty"`
+	PayrollSummary *payrollAggregatesResponse `json:"payrollSummary,omitempty"`
+}
+
+type employeeReviewSummary struct {
+	Count         int64   `json:"count"`
+	AverageRating float64 `json:"averageRating"`
+	LatestState   string  `json:"latestState"`
+}
+
+func (a *API) handleGetEmployee(w http.ResponseWriter, r *http.Request, id int64) {
+	// Check scope first so out-of-scope callers get the same answer whether
+	// or not the employee exists.
+	principal, _ := principalFrom(r.Context())
+	if !a.requireInScope(w, principal.employeeScope(), id) {
+		return
+	}
+	emp, err := a.store.GetEmployee(id)
+	if err != nil {
+		writeEmployeeStoreError(w, err)
+		return
+	}
+	detail := employeeDetailResponse{Employee: emp}
+	if principal.can(permReadReviews) {
+		aggregates, err := a.store.ListReviewAggregates(PerformanceReviewFilter{EmployeeIDs: []int64{id}, Scope: principal.reviewScope()})
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, internalErrorMsg)
+			return
+		}
+		detail.ReviewSummary = &employeeReviewSummary{}
+		// Aggregates are grouped by employee, so filtering by one yields at most one row.
+		if len(aggregates) > 0 {
+			detail.ReviewSummary = &employeeReviewSummary{
+				Count:         aggregates[0].Count,
+				AverageRating: aggregates[0].Average,
+				LatestState:   aggregates[0].LatestState,
+			}
+		}
+	}
+	if principal.can(permReadPayroll) {
+		totals, grand, err := a.store.PayrollTotals(PayrollFilter{EmployeeIDs: []int64{id}, Scope: principal.payrollScope()})
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, internalErrorMsg)
+			return
+		}
+		detail.PayrollSummary = &payrollAggregatesResponse{
+			TotalsByPeriod: totals,
+			GrandTotalNet:  grand,
+		}
+	}
+	if notModified(w, r, derivedETag(emp.Version, []any{detail.ReviewSummary, detail.PayrollSummary})) {
+		return
+	}
+	_ = json.NewEncoder(w).Encode(detail)
 }
 
 func (a *API) handleCreateEmployee(w http.ResponseWriter, r *http.Request) {
 	var p employeePayload
-	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
-		writeError(w, http.StatusUnprocessableEntity, "invalid payload")
+	if !decodeJSON(w, r, &p) {
 		return
 	}
-	name := strings.TrimSpace(p.Name)
-	if name == "" {
-		writeError(w, http.StatusUnprocessableEntity, "name is required")
+	input := normalizeEmployeeInput(EmployeeInput{
+		Name:       p.Name,
+		Email:      p.Email,
+		Department: p.Department,
+		JobTitle:   p.JobTitle,
+		HireDate:   p.HireDate,
+		Status:     p.Status,
+		NationalID: p.NationalID,
+		ManagerID:  p.ManagerID,
+	})
+	if err := validateEmployeeInput(input); err != nil {
+		writeErrorFor(w, http.StatusUnprocessableEntity, err)
 		return
 	}
-	created, err := a.store.CreateEmployee(name)
+	created, err := a.storeFor(r).CreateEmployee(input)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, internalErrorMsg)
+		writeEmployeeStoreError(w, err)
 		return
 	}
+	w.Header().Set("ETag", versionETag(created.Version))
 	w.WriteHeader(http.StatusCreated)
 	_ = json.NewEncoder(w).Encode(created)
 }
 
 func (a *API) handleUpdateEmployee(w http.ResponseWriter, r *http.Request, id int64) {
-	var p employeePayload
-	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
-		writeError(w, http.StatusUnprocessableEntity, "invalid payload")
+	var p employeeUpdatePayload
+	if !decodeJSON(w, r, &p) {
+		return
+	}
+	update := normalizeEmployeeUpdate(EmployeeUpdate{
+		Name:       p.Name,
+		Email:      p.Email,
+		Department: p.Department,
+		JobTitle:   p.JobTitle,
+		HireDate:   p.HireDate,
+		Status:     p.Status,
+		NationalID: p.NationalID,
+		ManagerID:  p.ManagerID,
+	})
+	if err := validateEmployeeUpdate(update); err != nil {
+		writeErrorFor(w, http.StatusUnprocessableEntity, err)
 		return
 	}
-	name := strings.TrimSpace(p.Name)
-	if name == "" {
-		writeError(w, http.StatusUnprocessableEntity, "name is required")
+	var ok bool
+	if update.IfVersion, ok = ifMatchVersion(r); !ok {
+		writePreconditionFailed(w)
 		return
 	}
-	updated, err := a.store.UpdateEmployee(id, name)
+	updated, err := a.storeFor(r).UpdateEmployee(id, update)
 	if err != nil {
-		if errors.Is(err, ErrNotFound) {
-			writeError(w, http.StatusNotFound, "not found")
-			return
-		}
-		writeError(w, http.StatusInternalServerError, internalErrorMsg)
+		writeEmployeeStoreError(w, err)
 		return
 	}
+	w.Header().Set("ETag", versionETag(updated.Version))
 	_ = json.NewEncoder(w).Encode(updated)
 }
 
-func (a *API) handleDeleteEmployee(w http.ResponseWriter, id int64) {
-	if err := a.store.DeleteEmployee(id); err != nil {
-		if errors.Is(err, ErrNotFound) {
-			writeError(w, http.StatusNotFound, "not found")
-			return
-		}
+func writeEmployeeStoreError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		writeError(w, http.StatusNotFound, "not found")
+	case errors.Is(err, ErrDuplicateEmail), errors.Is(err, ErrDuplicateNationalID),
+		errors.Is(err, ErrEmployeeArchived), errors.Is(err, ErrEmployeeNotArchived):
+		writeErrorFor(w, http.StatusConflict, err)
+	case errors.Is(err, ErrInvalidTerminationDate), errors.Is(err, ErrManagerNotFound), errors.Is(err, ErrInvalidManager),
+		errors.As(err, new(*ValidationError)):
+		writeErrorFor(w, http.StatusUnprocessableEntity, err)
+	case errors.Is(err, ErrVersionMismatch):
+		writePreconditionFailed(w)
+	default:
 		writeError(w, http.StatusInternalServerError, internalErrorMsg)
+	}
+}
+
+// handleOffboardEmployee soft-deletes an employee. The optional terminationDate
+// query parameter defaults to today.
+func (a *API) handleOffboardEmployee(w http.ResponseWriter, r *http.Request, id int64) {
+	if _, err := a.storeFor(r).OffboardEmployee(id, r.URL.Query().Get("terminationDate")); err != nil {
+		writeEmployeeStoreError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *API) handleRestoreEmployee(w http.ResponseWriter, r *http.Request, id int64) {
+	restored, err := a.storeFor(r).RestoreEmployee(id)
+	if err != nil {
+		writeEmployeeStoreError(w, err)
+		return
+	}
+	w.Header().Set("ETag", versionETag(restored.Version))
+	_ = json.NewEncoder(w).Encode(restored)
+}
+
+func (a *API) handlePurgeEmployee(w http.ResponseWriter, r *http.Request, id int64) {
+	if err := a.storeFor(r).PurgeEmployee(id); err != nil {
+		writeEmployeeStoreError(w, err)
 		return
 	}
 	w.WriteHeader(http.StatusNoContent)
@@ -186,7 +535,7 @@ func (a *API) handleDeleteEmployee(w http.ResponseWriter, id int64) {
 
 type reviewPayload struct {
 	EmployeeID    int64  `json:"employeeId"`
-	Period        string `json:"period"`
+	Period        Period `json:"period"`
 	Reviewer      string `json:"reviewer"`
 	Rating        int    `json:"rating"`
 	Strengths     string `json:"strengths"`
@@ -194,72 +543,91 @@ type reviewPayload struct {
 }
 
 type reviewListResponse struct {
-	Items      []PerformanceReview       `json:"items"`
+	Items      []reviewView              `json:"items"`
 	Aggregates []ReviewEmployeeAggregate `json:"aggregates"`
+	Page       pageLinks                 `json:"page"`
 }
 
 func (a *API) handleCreateReview(w http.ResponseWriter, r *http.Request) {
 	var payload reviewPayload
-	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
-		writeError(w, http.StatusUnprocessableEntity, "invalid payload")
+	if !decodeJSON(w, r, &payload) {
 		return
 	}
-	if err := validateReviewPayload(payload); err != nil {
-		writeError(w, http.StatusUnprocessableEntity, err.Error())
-		return
-	}
-	created, err := a.store.CreatePerformanceReview(PerformanceReviewInput{
+	input := PerformanceReviewInput{
 		EmployeeID:    payload.EmployeeID,
-		Period:        strings.TrimSpace(payload.Period),
+		Period:        payload.Period,
 		Reviewer:      strings.TrimSpace(payload.Reviewer),
 		Rating:        payload.Rating,
 		Strengths:     strings.TrimSpace(payload.Strengths),
 		Opportunities: strings.TrimSpace(payload.Opportunities),
-	})
+	}
+	if err := validateReviewInput(input); err != nil {
+		writeErrorFor(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	principal, _ := principalFrom(r.Context())
+	if !a.requireInScope(w, principal.reviewScope(), input.EmployeeID) {
+		return
+	}
+	created, err := a.storeFor(r).CreatePerformanceReview(input)
 	if err != nil {
+		if errors.Is(err, ErrEmployeeNotFound) {
+			writeErrorCode(w, http.StatusUnprocessableEntity, "unknown_employee", unknownEmployeeMsg)
+			return
+		}
+		var dup *DuplicateError
+		if errors.As(err, &dup) {
+			writeErrorFor(w, http.StatusConflict, dup)
+			return
+		}
 		writeError(w, http.StatusInternalServerError, internalErrorMsg)
 		return
 	}
+	w.Header().Set("ETag", versionETag(created.Version))
 	w.WriteHeader(http.StatusCreated)
-	_ = json.NewEncoder(w).Encode(created)
+	_ = json.NewEncoder(w).Encode(a.reviewView(created))
 }
 
-func validateReviewPayload(p reviewPayload) error {
-	if p.EmployeeID == 0 {
-		return fmt.Errorf("employeeId is required")
-	}
-	if strings.TrimSpace(p.Period) == "" {
-		return fmt.Errorf("period is required")
-	}
-	if strings.TrimSpace(p.Reviewer) == "" {
-		return fmt.Errorf("reviewer is required")
-	}
-	if p.Rating < 1 || p.Rating > 5 {
-		return fmt.Errorf("rating must be between 1 and 5")
+// reviewView adds the states the review may move to next and what can still
+// be edited, so clients can offer only the actions the workflow allows.
+type reviewView struct {
+	PerformanceReview
+	AllowedTransitions []NextState `json:"allowedTransitions"`
+	Editing            EditScope   `json:"editing"`
+}
+
+func (a *API) reviewView(review PerformanceReview) reviewView {
+	workflow := a.store.ReviewWorkflow()
+	return reviewView{
+		PerformanceReview:  review,
+		AllowedTransitions: workflow.Next(review.State),
+		Editing:            workflow.Editing(review.State),
 	}
-	return nil
 }
 
 func (a *API) handleListReviews(w http.ResponseWriter, r *http.Request) {
 	filter := PerformanceReviewFilter{}
-	if v := r.URL.Query().Get("employeeId"); v != "" {
-		id, err := strconv.ParseInt(v, 10, 64)
-		if err != nil {
-			writeError(w, http.StatusUnprocessableEntity, "invalid employeeId")
-			return
-		}
-		filter.EmployeeID = id
-	}
-	if v := r.URL.Query().Get("period"); v != "" {
-		filter.Period = v
+	if err := parseFilterQuery(r, map[string]filterField{
+		"employeeId":   idListField(&filter.EmployeeIDs),
+		"employeeName": containsField(&filter.EmployeeName),
+		"period":       rangeField(&filter.Period, ParsePeriod),
+		"state":        stringListField(&filter.States),
+		"rating":       rangeField(&filter.Rating, strconv.Atoi),
+	}); err != nil {
+		writeErrorFor(w, http.StatusUnprocessableEntity, err)
+		return
 	}
-	if v := r.URL.Query().Get("state"); v != "" {
-		filter.State = v
+	req, err := parsePageRequest(r.URL.Query())
+	if err != nil {
+		writeErrorFor(w, http.StatusUnprocessableEntity, err)
+		return
 	}
+	principal, _ := principalFrom(r.Context())
+	filter.Scope = principal.reviewScope()
 
-	items, err := a.store.ListPerformanceReviews(filter)
+	page, err := a.store.ListPerformanceReviewsPage(filter, req)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, internalErrorMsg)
+		writeListError(w, err)
 		return
 	}
 	aggregates, err := a.store.ListReviewAggregates(filter)
@@ -267,12 +635,48 @@ func (a *API) handleListReviews(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusInternalServerError, internalErrorMsg)
 		return
 	}
+	links := writePageHeaders(w, r, page.PageInfo)
+	views := make([]reviewView, 0, len(page.Items))
+	for _, item := range page.Items {
+		views = append(views, a.reviewView(item))
+	}
 	_ = json.NewEncoder(w).Encode(reviewListResponse{
-		Items:      items,
+		Items:      views,
 		Aggregates: aggregates,
+		Page:       links,
 	})
 }
 
+func (a *API) handleGetReview(w http.ResponseWriter, r *http.Request, id int64) {
+	review, ok := a.reviewInScope(w, r, id)
+	if !ok {
+		return
+	}
+	if notModified(w, r, versionETag(review.Version)) {
+		return
+	}
+	_ = json.NewEncoder(w).Encode(a.reviewView(review))
+}
+
+// reviewInScope loads a review and checks the caller may act on it, answering
+// 404 or 403 otherwise. Restricted callers get 403 for missing reviews too.
+func (a *API) reviewInScope(w http.ResponseWriter, r *http.Request, id int64) (PerformanceReview, bool) {
+	principal, _ := principalFrom(r.Context())
+	review, err := a.store.GetPerformanceReview(id)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			writeNotFoundInScope(w, principal.reviewScope())
+			return PerformanceReview{}, false
+		}
+		writeError(w, http.StatusInternalServerError, internalErrorMsg)
+		return PerformanceReview{}, false
+	}
+	if !a.requireInScope(w, principal.reviewScope(), review.EmployeeID) {
+		return PerformanceReview{}, false
+	}
+	return review, true
+}
+
 type reviewUpdatePayload struct {
 	Reviewer      *string `json:"reviewer"`
 	Rating        *int    `json:"rating"`
@@ -282,160 +686,319 @@ type reviewUpdatePayload struct {
 
 func (a *API) handleUpdateReview(w http.ResponseWriter, r *http.Request, id int64) {
 	var payload reviewUpdatePayload
-	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
-		writeError(w, http.StatusUnprocessableEntity, "invalid payload")
-		return
-	}
-	if payload.Rating != nil && (*payload.Rating < 1 || *payload.Rating > 5) {
-		writeError(w, http.StatusUnprocessableEntity, "rating must be between 1 and 5")
+	if !decodeJSON(w, r, &payload) {
 		return
 	}
-	updated, err := a.store.UpdatePerformanceReview(id, PerformanceReviewUpdate{
+	update := PerformanceReviewUpdate{
 		Reviewer:      payload.Reviewer,
 		Rating:        payload.Rating,
 		Strengths:     payload.Strengths,
 		Opportunities: payload.Opportunities,
-	})
+	}
+	if err := validateReviewUpdate(update); err != nil {
+		writeErrorFor(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	ifVersion, ok := ifMatchVersion(r)
+	if !ok {
+		writePreconditionFailed(w)
+		return
+	}
+	if _, ok := a.reviewInScope(w, r, id); !ok {
+		return
+	}
+	update.IfVersion = ifVersion
+	updated, err := a.storeFor(r).UpdatePerformanceReview(id, update)
 	if err != nil {
+		var validation *ValidationError
+		if errors.As(err, &validation) {
+			writeErrorFor(w, http.StatusUnprocessableEntity, err)
+			return
+		}
 		if errors.Is(err, ErrNotFound) {
 			writeError(w, http.StatusNotFound, "not found")
 			return
 		}
-		if errors.Is(err, ErrInvalidTransition) {
-			writeError(w, http.StatusUnprocessableEntity, "invalid state transition")
+		var dup *DuplicateError
+		if errors.Is(err, ErrReviewLocked) || errors.Is(err, ErrReviewCommentsOnly) || errors.As(err, &dup) {
+			writeErrorFor(w, http.StatusConflict, err)
+			return
+		}
+		if errors.Is(err, ErrVersionMismatch) {
+			writePreconditionFailed(w)
 			return
 		}
 		writeError(w, http.StatusInternalServerError, internalErrorMsg)
 		return
 	}
-	_ = json.NewEncoder(w).Encode(updated)
+	w.Header().Set("ETag", versionETag(updated.Version))
+	_ = json.NewEncoder(w).Encode(a.reviewView(updated))
 }
 
 type reviewTransitionPayload struct {
-	State string `json:"state"`
+	State   string `json:"state"`
+	Comment string `json:"comment"`
 }
 
 func (a *API) handleTransitionReview(w http.ResponseWriter, r *http.Request, id int64) {
 	var payload reviewTransitionPayload
-	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
-		writeError(w, http.StatusUnprocessableEntity, "invalid payload")
+	if !decodeJSON(w, r, &payload) {
 		return
 	}
 	state := strings.TrimSpace(payload.State)
 	if state == "" {
-		writeError(w, http.StatusUnprocessableEntity, "state is required")
+		writeErrorFor(w, http.StatusUnprocessableEntity, fieldError("state", FieldRequired, "state is required"))
+		return
+	}
+	ifVersion, ok := ifMatchVersion(r)
+	if !ok {
+		writePreconditionFailed(w)
+		return
+	}
+	if _, ok := a.reviewInScope(w, r, id); !ok {
 		return
 	}
-	updated, err := a.store.TransitionPerformanceReview(id, state)
+	updated, err := a.storeFor(r).TransitionPerformanceReview(id, state, payload.Comment, ifVersion)
 	if err != nil {
 		if errors.Is(err, ErrNotFound) {
 			writeError(w, http.StatusNotFound, "not found")
 			return
 		}
 		if errors.Is(err, ErrInvalidTransition) {
-			writeError(w, http.StatusUnprocessableEntity, "invalid transition")
+			writeErrorFor(w, http.StatusUnprocessableEntity, err)
+			return
+		}
+		if errors.Is(err, ErrTransitionConflict) {
+			writeErrorCode(w, http.StatusConflict, "transition_conflict", "review state changed, reload and retry")
+			return
+		}
+		if errors.Is(err, ErrVersionMismatch) {
+			writePreconditionFailed(w)
+			return
+		}
+		if errors.Is(err, ErrTransitionReasonRequired) {
+			writeErrorCode(w, http.StatusUnprocessableEntity, "reason_required", "comment is required as the reason for moving to "+state)
 			return
 		}
 		writeError(w, http.StatusInternalServerError, internalErrorMsg)
 		return
 	}
-	_ = json.NewEncoder(w).Encode(updated)
+	w.Header().Set("ETag", versionETag(updated.Version))
+	_ = json.NewEncoder(w).Encode(a.reviewView(updated))
+}
+
+func (a *API) handleReviewHistory(w http.ResponseWriter, r *http.Request, id int64) {
+	if _, ok := a.reviewInScope(w, r, id); !ok {
+		return
+	}
+	history, err := a.store.ListReviewTransitions(id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, internalErrorMsg)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(history)
 }
 
 // Payroll handlers
 
 type payrollPayload struct {
-	EmployeeID    int64   `json:"employeeId"`
-	Period        string  `json:"period"`
-	BaseSalary    float64 `json:"baseSalary"`
-	OvertimeHours float64 `json:"overtimeHours"`
-	OvertimeRate  float64 `json:"overtimeRate"`
-	Bonuses       float64 `json:"bonuses"`
-	Deductions    float64 `json:"deductions"`
+	EmployeeID    int64             `json:"employeeId"`
+	Period        Period            `json:"period"`
+	BaseSalary    Money             `json:"baseSalary"`
+	OvertimeHours float64           `json:"overtimeHours"`
+	OvertimeRate  Money             `json:"overtimeRate"`
+	LineItems     []PayrollLineItem `json:"lineItems"`
+	// Bonuses and Deductions are the single amounts older clients send; each
+	// becomes one generic line item.
+	Bonuses    Money `json:"bonuses"`
+	Deductions Money `json:"deductions"`
 }
 
 type payrollListResponse struct {
-	Items      []PayrollRecord           `json:"items"`
+	Items      []payrollRecordView       `json:"items"`
 	Aggregates payrollAggregatesResponse `json:"aggregates"`
+	Page       pageLinks                 `json:"page"`
 }
 
 type payrollAggregatesResponse struct {
 	TotalsByPeriod []PayrollPeriodTotal `json:"totalsByPeriod"`
-	GrandTotalNet  float64              `json:"grandTotalNet"`
+	GrandTotalNet  Money                `json:"grandTotalNet"`
 }
 
 func (a *API) handleCreatePayroll(w http.ResponseWriter, r *http.Request) {
 	var payload payrollPayload
-	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
-		writeError(w, http.StatusUnprocessableEntity, "invalid payload")
+	if !decodeJSON(w, r, &payload) {
 		return
 	}
-	if err := validatePayrollPayload(payload); err != nil {
-		writeError(w, http.StatusUnprocessableEntity, err.Error())
+	input := payload.input()
+	if err := validatePayrollInput(input); err != nil {
+		writeErrorFor(w, http.StatusUnprocessableEntity, err)
 		return
 	}
-	created, err := a.store.CreatePayrollRecord(PayrollRecordInput{
-		EmployeeID:    payload.EmployeeID,
-		Period:        strings.TrimSpace(payload.Period),
-		BaseSalary:    payload.BaseSalary,
-		OvertimeHours: payload.OvertimeHours,
-		OvertimeRate:  payload.OvertimeRate,
-		Bonuses:       payload.Bonuses,
-		Deductions:    payload.Deductions,
-	})
+	created, err := a.storeFor(r).CreatePayrollRecord(input)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, internalErrorMsg)
+		writePayrollStoreError(w, err)
 		return
 	}
+	w.Header().Set("ETag", versionETag(created.Version))
 	w.WriteHeader(http.StatusCreated)
-	_ = json.NewEncoder(w).Encode(created)
+	principal, _ := principalFrom(r.Context())
+	_ = json.NewEncoder(w).Encode(payrollView(principal, created))
 }
 
-func validatePayrollPayload(p payrollPayload) error {
-	if p.EmployeeID == 0 {
-		return fmt.Errorf("employeeId is required")
-	}
-	if strings.TrimSpace(p.Period) == "" {
-		return fmt.Errorf("period is required")
+func (p payrollPayload) input() PayrollRecordInput {
+	return PayrollRecordInput{
+		EmployeeID:    p.EmployeeID,
+		Period:        p.Period,
+		BaseSalary:    p.BaseSalary,
+		OvertimeHours: p.OvertimeHours,
+		OvertimeRate:  p.OvertimeRate,
+		LineItems:     append(trimLineItems(p.LineItems), genericLineItems(p.Bonuses, p.Deductions)...),
 	}
-	if p.BaseSalary < 0 {
-		return fmt.Errorf("baseSalary must be >= 0")
-	}
-	if p.OvertimeHours < 0 || p.OvertimeRate < 0 {
-		return fmt.Errorf("overtime values must be >= 0")
+}
+
+func trimLineItems(lines []PayrollLineItem) []PayrollLineItem {
+	trimmed := make([]PayrollLineItem, 0, len(lines))
+	for _, item := range lines {
+		item.Type = strings.TrimSpace(item.Type)
+		item.Code = strings.TrimSpace(item.Code)
+		item.Description = strings.TrimSpace(item.Description)
+		trimmed = append(trimmed, item)
 	}
-	return nil
+	return trimmed
 }
 
 func (a *API) handleListPayroll(w http.ResponseWriter, r *http.Request) {
 	filter := PayrollFilter{}
-	if v := r.URL.Query().Get("employeeId"); v != "" {
-		id, err := strconv.ParseInt(v, 10, 64)
-		if err != nil {
-			writeError(w, http.StatusUnprocessableEntity, "invalid employeeId")
-			return
-		}
-		filter.EmployeeID = id
+	if err := parseFilterQuery(r, map[string]filterField{
+		"employeeId":    idListField(&filter.EmployeeIDs),
+		"employeeName":  containsField(&filter.EmployeeName),
+		"period":        rangeField(&filter.Period, ParsePeriod),
+		"netPay":        rangeField(&filter.NetPay, ParseMoney),
+		"includeVoided": boolField(&filter.IncludeVoided),
+	}); err != nil {
+		writeErrorFor(w, http.StatusUnprocessableEntity, err)
+		return
 	}
-	if v := r.URL.Query().Get("period"); v != "" {
-		filter.Period = v
+	req, err := parsePageRequest(r.URL.Query())
+	if err != nil {
+		writeErrorFor(w, http.StatusUnprocessableEntity, err)
+		return
 	}
+	principal, _ := principalFrom(r.Context())
+	filter.Scope = principal.payrollScope()
 
-	items, err := a.store.ListPayrollRecords(filter)
+	page, err := a.store.ListPayrollRecordsPage(filter, req)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, internalErrorMsg)
+		writeListError(w, err)
 		return
 	}
+	items := make([]payrollRecordView, 0, len(page.Items))
+	for _, record := range page.Items {
+		items = append(items, payrollView(principal, record))
+	}
 	totals, grand, err := a.store.PayrollTotals(filter)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, internalErrorMsg)
 		return
 	}
+	links := writePageHeaders(w, r, page.PageInfo)
 	_ = json.NewEncoder(w).Encode(payrollListResponse{
 		Items: items,
 		Aggregates: payrollAggregatesResponse{
 			TotalsByPeriod: totals,
 			GrandTotalNet:  grand,
 		},
+		Page: links,
 	})
 }
+
+func (a *API) handleGetPayroll(w http.ResponseWriter, r *http.Request, id int64) {
+	principal, _ := principalFrom(r.Context())
+	record, err := a.store.GetPayrollRecord(id)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			writeNotFoundInScope(w, principal.payrollScope())
+			return
+		}
+		writeError(w, http.StatusInternalServerError, internalErrorMsg)
+		return
+	}
+	if !a.requireInScope(w, principal.payrollScope(), record.EmployeeID) {
+		return
+	}
+	if notModified(w, r, versionETag(record.Version)) {
+		return
+	}
+	_ = json.NewEncoder(w).Encode(payrollView(principal, record))
+}
+
+type payrollVoidPayload struct {
+	Reason string `json:"reason"`
+}
+
+type payrollCorrectionPayload struct {
+	payrollPayload
+	Reason string `json:"reason"`
+}
+
+func (a *API) handleVoidPayroll(w http.ResponseWriter, r *http.Request, id int64) {
+	var payload payrollVoidPayload
+	if !decodeJSON(w, r, &payload) {
+		return
+	}
+	if strings.TrimSpace(payload.Reason) == "" {
+		writeErrorFor(w, http.StatusUnprocessableEntity, fieldError("reason", FieldRequired, "reason is required"))
+		return
+	}
+	voided, err := a.storeFor(r).VoidPayrollRecord(id, payload.Reason)
+	if err != nil {
+		writePayrollStoreError(w, err)
+		return
+	}
+	principal, _ := principalFrom(r.Context())
+	_ = json.NewEncoder(w).Encode(payrollView(principal, voided))
+}
+
+func (a *API) handleCorrectPayroll(w http.ResponseWriter, r *http.Request, id int64) {
+	var payload payrollCorrectionPayload
+	if !decodeJSON(w, r, &payload) {
+		return
+	}
+	input := payload.input()
+	if err := validatePayrollInput(input); err != nil {
+		writeErrorFor(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	if strings.TrimSpace(payload.Reason) == "" {
+		writeErrorFor(w, http.StatusUnprocessableEntity, fieldError("reason", FieldRequired, "reason is required"))
+		return
+	}
+	corrected, err := a.storeFor(r).CorrectPayrollRecord(id, input, payload.Reason)
+	if err != nil {
+		writePayrollStoreError(w, err)
+		return
+	}
+	w.Header().Set("ETag", versionETag(corrected.Version))
+	w.WriteHeader(http.StatusCreated)
+	principal, _ := principalFrom(r.Context())
+	_ = json.NewEncoder(w).Encode(payrollView(principal, corrected))
+}
+
+func writePayrollStoreError(w http.ResponseWriter, err error) {
+	var dup *DuplicateError
+	switch {
+	case errors.Is(err, ErrNotFound):
+		writeError(w, http.StatusNotFound, "not found")
+	case errors.Is(err, ErrPayrollVoided):
+		writeErrorFor(w, http.StatusConflict, err)
+	case errors.Is(err, ErrEmployeeNotFound):
+		writeErrorCode(w, http.StatusUnprocessableEntity, "unknown_employee", unknownEmployeeMsg)
+	case errors.As(err, &dup):
+		writeErrorFor(w, http.StatusConflict, dup)
+	case errors.As(err, new(*ValidationError)):
+		writeErrorFor(w, http.StatusUnprocessableEntity, err)
+	default:
+		writeError(w, http.StatusInternalServerError, internalErrorMsg)
+	}
+}
@@ -7,25 +7,58 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"strconv"
 	"testing"
+	"time"
 )
 
-func setupTestServer(t *testing.T) (*Store, *http.ServeMux) {
+// setupTestServer serves the routes as an HR user, who may call every
+// employee, review and payroll endpoint except the admin purge.
+func setupTestServer(t *testing.T) (*Store, http.Handler) {
+	t.Helper()
+	store, mux := newTestMux(t)
+	return store, withTestPrincipal(mux, Principal{User: User{ID: 1, Role: RoleHR}})
+}
+
+// withTestPrincipal stands in for the auth middleware.
+func withTestPrincipal(next http.Handler, p Principal) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(withPrincipal(r.Context(), p)))
+	})
+}
+
+// newTestMux registers the routes without any authentication in front of them.
+func newTestMux(t *testing.T) (*Store, *http.ServeMux) {
 	t.Helper()
 	store, err := NewStore(":memory:")
 	if err != nil {
 		t.Fatalf("new store: %v", err)
 	}
-	if err := store.Init(); err != nil {
+	if err := store.Migrate(); err != nil {
 		store.Close()
-		t.Fatalf("init store: %v", err)
+		t.Fatalf("migrate store: %v", err)
 	}
 	mux := http.NewServeMux()
-	NewAPI(store).RegisterRoutes(mux)
+	NewAPI(store, NewTokenSigner([]byte("test-secret"), time.Hour)).RegisterRoutes(mux)
 	return store, mux
 }
 
-func doJSON(t *testing.T, mux *http.ServeMux, method, path string, body any) *httptest.ResponseRecorder {
+func doJSON(t *testing.T, mux http.Handler, method, path string, body any) *httptest.ResponseRecorder {
+	t.Helper()
+	return doJSONWithToken(t, mux, method, path, "", body)
+}
+
+func doJSONWithToken(t *testing.T, handler http.Handler, method, path, token string, body any) *httptest.ResponseRecorder {
+	t.Helper()
+	headers := map[string]string{}
+	if token != "" {
+		headers["Authorization"] = "Bearer " + token
+	}
+	return doJSONWithHeaders(t, handler, method, path, headers, body)
+}
+
+func doJSONWithHeaders(t *testing.T, handler http.Handler, method, path string, headers map[string]string, body any) *httptest.ResponseRecorder {
 	t.Helper()
 	var r io.Reader
 	if body != nil {
@@ -36,8 +69,11 @@ func doJSON(t *testing.T, mux *http.ServeMux, method, path string, body any) *ht
 	if body != nil {
 		req.Header.Set("Content-Type", "application/json")
 	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
 	rr := httptest.NewRecorder()
-	mux.ServeHTTP(rr, req)
+	handler.ServeHTTP(rr, req)
 	return rr
 }
 
@@ -58,11 +94,76 @@ func TestCreateEmployee_ok(t *testing.T) {
 	}
 }
 
+func TestCreateEmployee_fullProfile(t *testing.T) {
+	store, mux := setupTestServer(t)
+	defer store.Close()
+
+	resp := doJSON(t, mux, http.MethodPost, "/employees", map[string]string{
+		"name":       "Lucía Gómez",
+		"email":      "Lucia@Example.com",
+		"department": "Engineering",
+		"jobTitle":   "Backend Developer",
+		"hireDate":   "2023-05-02",
+		"status":     EmploymentStatusOnLeave,
+		"nationalId": "27-33444555-1",
+	})
+	if resp.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d body %s", resp.Code, resp.Body.String())
+	}
+	var got Employee
+	if err := json.Unmarshal(resp.Body.Bytes(), &got); err != nil {
+		t.Fatalf("json: %v", err)
+	}
+	if got.Email != "lucia@example.com" || got.Department != "Engineering" || got.JobTitle != "Backend Developer" ||
+		got.HireDate != "2023-05-02" || got.Status != EmploymentStatusOnLeave || got.NationalID != "27334445551" {
+		t.Fatalf("unexpected body: %+v", got)
+	}
+
+	dup := doJSON(t, mux, http.MethodPost, "/employees", map[string]string{"name": "Other", "email": "lucia@example.com"})
+	if dup.Code != http.StatusConflict {
+		t.Fatalf("expected 409 duplicate email, got %d", dup.Code)
+	}
+	dup = doJSON(t, mux, http.MethodPut, "/employees/"+strconv.FormatInt(got.ID, 10), map[string]string{"nationalId": "27334445551"})
+	if dup.Code != http.StatusOK {
+		t.Fatalf("expected 200 when keeping own national id, got %d", dup.Code)
+	}
+}
+
+func TestCreateEmployee_invalidProfile_422(t *testing.T) {
+	store, mux := setupTestServer(t)
+	defer store.Close()
+
+	resp := doJSON(t, mux, http.MethodPost, "/employees", map[string]string{"name": "A", "hireDate": "yesterday"})
+	if resp.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d", resp.Code)
+	}
+	resp = doJSON(t, mux, http.MethodPost, "/employees", map[string]string{"name": "A", "email": "a@"})
+	if resp.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d", resp.Code)
+	}
+}
+
+func TestUpdateEmployee_duplicateEmail_409(t *testing.T) {
+	store, mux := setupTestServer(t)
+	defer store.Close()
+
+	if _, err := store.CreateEmployee(EmployeeInput{Name: "A", Email: "a@example.com"}); err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+	if _, err := store.CreateEmployee(EmployeeInput{Name: "B"}); err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+	rr := doJSON(t, mux, http.MethodPut, "/employees/2", map[string]string{"email": "a@example.com"})
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d", rr.Code)
+	}
+}
+
 func TestUpdateEmployee_ok(t *testing.T) {
 	store, mux := setupTestServer(t)
 	defer store.Close()
 
-	created, err := store.CreateEmployee("Bob")
+	created, err := store.CreateEmployee(EmployeeInput{Name: "Bob"})
 	if err != nil {
 		t.Fatalf("seed: %v", err)
 	}
@@ -120,10 +221,10 @@ func TestListEmployees_withData(t *testing.T) {
 	store, mux := setupTestServer(t)
 	defer store.Close()
 
-	if _, err := store.CreateEmployee("A"); err != nil {
+	if _, err := store.CreateEmployee(EmployeeInput{Name: "A"}); err != nil {
 		t.Fatalf("seed: %v", err)
 	}
-	if _, err := store.CreateEmployee("B"); err != nil {
+	if _, err := store.CreateEmployee(EmployeeInput{Name: "B"}); err != nil {
 		t.Fatalf("seed: %v", err)
 	}
 
@@ -164,7 +265,7 @@ func TestUpdateEmployee_422(t *testing.T) {
 	store, mux := setupTestServer(t)
 	defer store.Close()
 
-	if _, err := store.CreateEmployee("A"); err != nil {
+	if _, err := store.CreateEmployee(EmployeeInput{Name: "A"}); err != nil {
 		t.Fatalf("seed: %v", err)
 	}
 	rr := doJSON(t, mux, http.MethodPut, "/employees/1", map[string]string{"name": "  "})
@@ -190,7 +291,7 @@ func TestUpdateEmployee_invalidJSON_422(t *testing.T) {
 	store, mux := setupTestServer(t)
 	defer store.Close()
 
-	if _, err := store.CreateEmployee("A"); err != nil {
+	if _, err := store.CreateEmployee(EmployeeInput{Name: "A"}); err != nil {
 		t.Fatalf("seed: %v", err)
 	}
 	req := httptest.NewRequest(http.MethodPut, "/employees/1", bytes.NewBufferString("{"))
@@ -231,39 +332,150 @@ func TestUpdateEmployee_500(t *testing.T) {
 	}
 }
 
-func TestCORS_OPTIONS_OK(t *testing.T) {
+func TestDeleteEmployee_ok(t *testing.T) {
 	store, mux := setupTestServer(t)
 	defer store.Close()
-	handler := withCORS(mux)
 
-	req := httptest.NewRequest(http.MethodOptions, "/employees", nil)
-	rr := httptest.NewRecorder()
-	handler.ServeHTTP(rr, req)
+	emp, err := store.CreateEmployee(EmployeeInput{Name: "ToDelete"})
+	if err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+
+	rr := doJSON(t, mux, http.MethodDelete, "/employees/1", nil)
 	if rr.Code != http.StatusNoContent {
-		t.Fatalf("expected 204, got %d", rr.Code)
+		t.Fatalf("expected 204, got %d body %s", rr.Code, rr.Body.String())
+	}
+
+	_, err = store.UpdateEmployee(emp.ID, EmployeeUpdate{Name: strPtr("new")})
+	if !errors.Is(err, ErrEmployeeArchived) {
+		t.Fatalf("expected archived employee to be read-only after delete, got %v", err)
 	}
-	if got := rr.Header().Get("Access-Control-Allow-Origin"); got == "" {
-		t.Fatalf("missing CORS header")
+	list, err := store.ListEmployees(EmployeeFilter{})
+	if err != nil || len(list) != 0 {
+		t.Fatalf("expected archived employee hidden from list, got %v %v", list, err)
 	}
 }
 
-func TestDeleteEmployee_ok(t *testing.T) {
+func TestOffboardEmployee_keepsHistory(t *testing.T) {
 	store, mux := setupTestServer(t)
 	defer store.Close()
 
-	emp, err := store.CreateEmployee("ToDelete")
+	emp, err := store.CreateEmployee(EmployeeInput{Name: "Leaving", HireDate: "2020-01-10"})
 	if err != nil {
 		t.Fatalf("seed: %v", err)
 	}
+	if _, err := store.CreatePayrollRecord(PayrollRecordInput{EmployeeID: emp.ID, Period: "2024-11", BaseSalary: 100000}); err != nil {
+		t.Fatalf("seed payroll: %v", err)
+	}
 
-	rr := doJSON(t, mux, http.MethodDelete, "/employees/1", nil)
+	rr := doJSON(t, mux, http.MethodDelete, "/employees/1?terminationDate=2019-12-31", nil)
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 for termination before hire, got %d", rr.Code)
+	}
+	rr = doJSON(t, mux, http.MethodDelete, "/employees/1?terminationDate=2024-12-15", nil)
 	if rr.Code != http.StatusNoContent {
 		t.Fatalf("expected 204, got %d body %s", rr.Code, rr.Body.String())
 	}
+	rr = doJSON(t, mux, http.MethodDelete, "/employees/1", nil)
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("expected 409 when offboarding twice, got %d", rr.Code)
+	}
+
+	rr = doJSON(t, mux, http.MethodGet, "/employees?includeArchived=true", nil)
+	var all []Employee
+	if err := json.Unmarshal(rr.Body.Bytes(), &all); err != nil {
+		t.Fatalf("json: %v", err)
+	}
+	if len(all) != 1 || all[0].Status != EmploymentStatusTerminated || all[0].TerminationDate != "2024-12-15" || all[0].ArchivedAt == "" {
+		t.Fatalf("unexpected archived employee: %+v", all)
+	}
+
+	payroll := doJSON(t, mux, http.MethodGet, "/payroll?employeeId=1", nil)
+	var list payrollList
+	if err := json.Unmarshal(payroll.Body.Bytes(), &list); err != nil {
+		t.Fatalf("json: %v", err)
+	}
+	if len(list.Items) != 1 {
+		t.Fatalf("expected payroll history to survive offboarding, got %+v", list.Items)
+	}
+}
+
+func TestRestoreEmployee(t *testing.T) {
+	store, mux := setupTestServer(t)
+	defer store.Close()
+
+	emp, err := store.CreateEmployee(EmployeeInput{Name: "Back Again"})
+	if err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+	rr := doJSON(t, mux, http.MethodPost, "/employees/1/restore", nil)
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("expected 409 restoring active employee, got %d", rr.Code)
+	}
+	if _, err := store.OffboardEmployee(emp.ID, ""); err != nil {
+		t.Fatalf("offboard: %v", err)
+	}
+	rr = doJSON(t, mux, http.MethodPost, "/employees/1/restore", nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d body %s", rr.Code, rr.Body.String())
+	}
+	var got Employee
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatalf("json: %v", err)
+	}
+	if got.Status != EmploymentStatusActive || got.ArchivedAt != "" || got.TerminationDate != "" {
+		t.Fatalf("unexpected restored employee: %+v", got)
+	}
 
-	_, err = store.UpdateEmployee(emp.ID, "new")
-	if !errors.Is(err, ErrNotFound) {
-		t.Fatalf("expected not found after delete, got %v", err)
+	if rr := doJSON(t, mux, http.MethodPost, "/employees/99/restore", nil); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rr.Code)
+	}
+	if rr := doJSON(t, mux, http.MethodGet, "/employees/1/restore", nil); rr.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405, got %d", rr.Code)
+	}
+}
+
+func TestPurgeEmployee(t *testing.T) {
+	store, rawMux := newTestMux(t)
+	defer store.Close()
+	mux := withTestPrincipal(rawMux, Principal{User: User{ID: 1, Role: RoleAdmin}})
+
+	emp, err := store.CreateEmployee(EmployeeInput{Name: "Purged"})
+	if err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+	if _, err := store.CreatePayrollRecord(PayrollRecordInput{EmployeeID: emp.ID, Period: "2024-11", BaseSalary: 100000}); err != nil {
+		t.Fatalf("seed payroll: %v", err)
+	}
+
+	rr := doJSON(t, mux, http.MethodDelete, "/admin/employees/1", nil)
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("expected 409 purging active employee, got %d", rr.Code)
+	}
+	if _, err := store.OffboardEmployee(emp.ID, ""); err != nil {
+		t.Fatalf("offboard: %v", err)
+	}
+	rr = doJSON(t, mux, http.MethodDelete, "/admin/employees/1", nil)
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d body %s", rr.Code, rr.Body.String())
+	}
+	records, err := store.ListPayrollRecords(PayrollFilter{})
+	if err != nil || len(records) != 0 {
+		t.Fatalf("expected payroll purged, got %v %v", records, err)
+	}
+	var count int
+	if err := store.db.QueryRow("SELECT COUNT(*) FROM payroll_records").Scan(&count); err != nil || count != 0 {
+		t.Fatalf("expected no orphaned payroll rows, got %d %v", count, err)
+	}
+
+	if rr := doJSON(t, mux, http.MethodDelete, "/admin/employees/1", nil); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rr.Code)
+	}
+	if rr := doJSON(t, mux, http.MethodDelete, "/admin/employees/x", nil); rr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d", rr.Code)
+	}
+	if rr := doJSON(t, mux, http.MethodGet, "/admin/employees/1", nil); rr.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405, got %d", rr.Code)
 	}
 }
 
@@ -296,7 +508,7 @@ func TestReviews_CreateListTransition(t *testing.T) {
 	store, mux := setupTestServer(t)
 	defer store.Close()
 
-	emp, err := store.CreateEmployee("Alice")
+	emp, err := store.CreateEmployee(EmployeeInput{Name: "Alice"})
 	if err != nil {
 		t.Fatalf("seed: %v", err)
 	}
@@ -392,7 +604,7 @@ func TestReviews_UpdateInvalidRating(t *testing.T) {
 	store, mux := setupTestServer(t)
 	defer store.Close()
 
-	emp, err := store.CreateEmployee("Alice")
+	emp, err := store.CreateEmployee(EmployeeInput{Name: "Alice"})
 	if err != nil {
 		t.Fatalf("seed employee: %v", err)
 	}
@@ -454,7 +666,7 @@ func TestReviews_TransitionInvalidJSON(t *testing.T) {
 	store, mux := setupTestServer(t)
 	defer store.Close()
 
-	emp, err := store.CreateEmployee("Bob")
+	emp, err := store.CreateEmployee(EmployeeInput{Name: "Bob"})
 	if err != nil {
 		t.Fatalf("seed employee: %v", err)
 	}
@@ -480,7 +692,7 @@ func TestReviews_TransitionBlankState(t *testing.T) {
 	store, mux := setupTestServer(t)
 	defer store.Close()
 
-	emp, err := store.CreateEmployee("Carol")
+	emp, err := store.CreateEmployee(EmployeeInput{Name: "Carol"})
 	if err != nil {
 		t.Fatalf("seed employee: %v", err)
 	}
@@ -508,6 +720,221 @@ func TestReviews_TransitionNotFound(t *testing.T) {
 	}
 }
 
+func TestReviews_History(t *testing.T) {
+	store, mux := setupTestServer(t)
+	defer store.Close()
+
+	emp := mustCreateEmployee(t, store, "Alice")
+	review := mustCreateReview(t, store, emp.ID)
+
+	resp := doJSON(t, mux, http.MethodPut, "/reviews/1/status", map[string]string{"state": ReviewStateSubmitted, "comment": "  ready for sign-off "})
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected 200 transition, got %d", resp.Code)
+	}
+	resp = doJSON(t, mux, http.MethodPut, "/reviews/1/status", map[string]string{"state": ReviewStateApproved})
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected 200 transition, got %d", resp.Code)
+	}
+
+	resp = doJSON(t, mux, http.MethodGet, "/reviews/1/history", nil)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.Code)
+	}
+	var history []ReviewTransition
+	if err := json.Unmarshal(resp.Body.Bytes(), &history); err != nil {
+		t.Fatalf("json: %v", err)
+	}
+	want := []struct{ from, to, comment string }{
+		{"", ReviewStateDraft, ""},
+		{ReviewStateDraft, ReviewStateSubmitted, "ready for sign-off"},
+		{ReviewStateSubmitted, ReviewStateApproved, ""},
+	}
+	if len(history) != len(want) {
+		t.Fatalf("expected %d transitions, got %+v", len(want), history)
+	}
+	for i, w := range want {
+		got := history[i]
+		if got.ReviewID != review.ID || got.FromState != w.from || got.ToState != w.to || got.Comment != w.comment || got.OccurredAt == "" {
+			t.Fatalf("transition %d: unexpected %+v", i, got)
+		}
+	}
+	if history[1].ActorID != 1 {
+		t.Fatalf("expected the caller as actor, got %+v", history[1])
+	}
+
+	if resp := doJSON(t, mux, http.MethodGet, "/reviews/999/history", nil); resp.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", resp.Code)
+	}
+	if resp := doJSON(t, mux, http.MethodGet, "/reviews/abc/history", nil); resp.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d", resp.Code)
+	}
+	if resp := doJSON(t, mux, http.MethodPost, "/reviews/1/history", nil); resp.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405, got %d", resp.Code)
+	}
+}
+
+func TestReviews_ReturnAndReject(t *testing.T) {
+	store, mux := setupTestServer(t)
+	defer store.Close()
+
+	emp := mustCreateEmployee(t, store, "Alice")
+	mustCreateReview(t, store, emp.ID)
+
+	transition := func(state, comment string) (*httptest.ResponseRecorder, reviewView) {
+		t.Helper()
+		resp := doJSON(t, mux, http.MethodPut, "/reviews/1/status", map[string]string{"state": state, "comment": comment})
+		var view reviewView
+		if resp.Code == http.StatusOK {
+			if err := json.Unmarshal(resp.Body.Bytes(), &view); err != nil {
+				t.Fatalf("json: %v", err)
+			}
+		}
+		return resp, view
+	}
+
+	resp, view := transition(ReviewStateSubmitted, "")
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected 200 submit, got %d", resp.Code)
+	}
+	if len(view.AllowedTransitions) != 3 || !view.AllowedTransitions[2].RequiresReason {
+		t.Fatalf("unexpected allowed transitions %+v", view.AllowedTransitions)
+	}
+
+	if resp, _ := transition(ReviewStateReturned, ""); resp.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 without a reason, got %d", resp.Code)
+	}
+	resp, view = transition(ReviewStateReturned, "rating needs a rationale")
+	if resp.Code != http.StatusOK || view.State != ReviewStateReturned {
+		t.Fatalf("expected returned review, got %d %+v", resp.Code, view)
+	}
+	if len(view.AllowedTransitions) != 1 || view.AllowedTransitions[0].State != ReviewStateSubmitted {
+		t.Fatalf("expected resubmission only, got %+v", view.AllowedTransitions)
+	}
+
+	if resp, _ := transition(ReviewStateSubmitted, ""); resp.Code != http.StatusOK {
+		t.Fatalf("expected 200 resubmit, got %d", resp.Code)
+	}
+	resp, view = transition(ReviewStateRejected, "objectives were never agreed")
+	if resp.Code != http.StatusOK || view.State != ReviewStateRejected || len(view.AllowedTransitions) != 0 {
+		t.Fatalf("expected final rejected review, got %d %+v", resp.Code, view)
+	}
+
+	resp = doJSON(t, mux, http.MethodGet, "/reviews/1", nil)
+	if err := json.Unmarshal(resp.Body.Bytes(), &view); err != nil {
+		t.Fatalf("json: %v", err)
+	}
+	if view.AllowedTransitions == nil || len(view.AllowedTransitions) != 0 {
+		t.Fatalf("expected an empty list for a final state, got %+v", view.AllowedTransitions)
+	}
+}
+
+func TestReviews_UpdateLockedByState(t *testing.T) {
+	store, mux := setupTestServer(t)
+	defer store.Close()
+
+	emp := mustCreateEmployee(t, store, "Alice")
+	review := mustCreateReview(t, store, emp.ID)
+	if _, err := store.TransitionPerformanceReview(review.ID, ReviewStateSubmitted, "", 0); err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+
+	resp := doJSON(t, mux, http.MethodPut, "/reviews/1", map[string]any{"rating": 1})
+	if resp.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for rating on a submitted review, got %d", resp.Code)
+	}
+	var body map[string]string
+	_ = json.Unmarshal(resp.Body.Bytes(), &body)
+	if body["error"] != "only strengths and opportunities can be edited while the review is submitted" {
+		t.Fatalf("unexpected error %q", body["error"])
+	}
+
+	resp = doJSON(t, mux, http.MethodPut, "/reviews/1", map[string]any{"opportunities": "Delegation"})
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected 200 for comments on a submitted review, got %d", resp.Code)
+	}
+	var view reviewView
+	if err := json.Unmarshal(resp.Body.Bytes(), &view); err != nil {
+		t.Fatalf("json: %v", err)
+	}
+	if view.Opportunities != "Delegation" || view.Editing != EditComments {
+		t.Fatalf("unexpected review %+v", view)
+	}
+
+	if _, err := store.TransitionPerformanceReview(review.ID, ReviewStateApproved, "", 0); err != nil {
+		t.Fatalf("approve: %v", err)
+	}
+	resp = doJSON(t, mux, http.MethodPut, "/reviews/1", map[string]any{"strengths": "Rewritten"})
+	if resp.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for an approved review, got %d", resp.Code)
+	}
+	_ = json.Unmarshal(resp.Body.Bytes(), &body)
+	if body["error"] != "review can no longer be edited once it is approved" {
+		t.Fatalf("unexpected error %q", body["error"])
+	}
+}
+
+func TestReviews_DuplicatePeriod_409(t *testing.T) {
+	store, mux := setupTestServer(t)
+	defer store.Close()
+
+	emp := mustCreateEmployee(t, store, "Alice")
+	existing := mustCreateReview(t, store, emp.ID)
+
+	payload := map[string]any{"employeeId": emp.ID, "period": "2024-Q4", "reviewer": "  manager ", "rating": 3}
+	resp := doJSON(t, mux, http.MethodPost, "/reviews", payload)
+	if resp.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d body %s", resp.Code, resp.Body.String())
+	}
+	var body struct {
+		Error      string `json:"error"`
+		ExistingID int64  `json:"existingId"`
+	}
+	if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil {
+		t.Fatalf("json: %v", err)
+	}
+	if body.ExistingID != existing.ID || body.Error == "" {
+		t.Fatalf("unexpected conflict body %+v", body)
+	}
+
+	// Another reviewer, or another period, is a separate review.
+	payload["reviewer"] = "Peer"
+	if resp := doJSON(t, mux, http.MethodPost, "/reviews", payload); resp.Code != http.StatusCreated {
+		t.Fatalf("expected 201 for another reviewer, got %d", resp.Code)
+	}
+	payload["period"] = "2025-Q1"
+	if resp := doJSON(t, mux, http.MethodPost, "/reviews", payload); resp.Code != http.StatusCreated {
+		t.Fatalf("expected 201 for another period, got %d", resp.Code)
+	}
+}
+
+func TestReviews_UpdateReviewerCollision_409(t *testing.T) {
+	store, mux := setupTestServer(t)
+	defer store.Close()
+
+	emp := mustCreateEmployee(t, store, "Alice")
+	existing := mustCreateReview(t, store, emp.ID)
+	peer, err := store.CreatePerformanceReview(PerformanceReviewInput{EmployeeID: emp.ID, Period: "2024-Q4", Reviewer: "Peer", Rating: 3})
+	if err != nil {
+		t.Fatalf("create review: %v", err)
+	}
+
+	resp := doJSON(t, mux, http.MethodPut, "/reviews/"+strconv.FormatInt(peer.ID, 10), map[string]any{"reviewer": " manager "})
+	if resp.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d body %s", resp.Code, resp.Body.String())
+	}
+	problem := decodeProblem(t, resp.Body.Bytes())
+	if problem.Code != codeDuplicateRecord || problem.ExistingID != existing.ID {
+		t.Fatalf("unexpected conflict body %+v", problem)
+	}
+	unchanged, err := store.GetPerformanceReview(peer.ID)
+	if err != nil {
+		t.Fatalf("get review: %v", err)
+	}
+	if unchanged.Reviewer != "Peer" || unchanged.Version != peer.Version {
+		t.Fatalf("review changed after conflict: %+v", unchanged)
+	}
+}
+
 type payrollList struct {
 	Items      []PayrollRecord           `json:"items"`
 	Aggregates payrollAggregatesResponse `json:"aggregates"`
@@ -517,7 +944,7 @@ func TestPayroll_CreateAndList(t *testing.T) {
 	store, mux := setupTestServer(t)
 	defer store.Close()
 
-	emp, err := store.CreateEmployee("Bob")
+	emp, err := store.CreateEmployee(EmployeeInput{Name: "Bob"})
 	if err != nil {
 		t.Fatalf("seed: %v", err)
 	}
@@ -538,9 +965,9 @@ func TestPayroll_CreateAndList(t *testing.T) {
 	if err := json.Unmarshal(resp.Body.Bytes(), &created); err != nil {
 		t.Fatalf("json: %v", err)
 	}
-	expectedNet := 1000.0 + (10.0 * 50.0) + 200.0 - 100.0
+	expectedNet := Money((1000 + (10 * 50) + 200 - 100) * 100)
 	if created.NetPay != expectedNet {
-		t.Fatalf("expected net %.2f, got %.2f", expectedNet, created.NetPay)
+		t.Fatalf("expected net %s, got %s", expectedNet, created.NetPay)
 	}
 
 	listResp := doJSON(t, mux, http.MethodGet, "/payroll?employeeId=1", nil)
@@ -555,7 +982,7 @@ func TestPayroll_CreateAndList(t *testing.T) {
 		t.Fatalf("expected payroll list 1, got %+v", list)
 	}
 	if list.Aggregates.GrandTotalNet != expectedNet {
-		t.Fatalf("expected grand total %.2f, got %.2f", expectedNet, list.Aggregates.GrandTotalNet)
+		t.Fatalf("expected grand total %s, got %s", expectedNet, list.Aggregates.GrandTotalNet)
 	}
 }
 
@@ -619,3 +1046,462 @@ func TestPayroll_CreateInternalError(t *testing.T) {
 		t.Fatalf("expected 500, got %d", resp.Code)
 	}
 }
+
+func TestGetEmployee_withSummaries(t *testing.T) {
+	store, mux := setupTestServer(t)
+	defer store.Close()
+
+	emp, err := store.CreateEmployee(EmployeeInput{Name: "Alice", Department: "Sales"})
+	if err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+	for i, rating := range []int{3, 5} {
+		period := []Period{"2024-Q3", "2024-Q4"}[i]
+		if _, err := store.CreatePerformanceReview(PerformanceReviewInput{EmployeeID: emp.ID, Period: period, Reviewer: "Boss", Rating: rating}); err != nil {
+			t.Fatalf("seed review: %v", err)
+		}
+	}
+	if _, err := store.CreatePayrollRecord(PayrollRecordInput{EmployeeID: emp.ID, Period: "2024-11", BaseSalary: 100000}); err != nil {
+		t.Fatalf("seed payroll: %v", err)
+	}
+
+	rr := doJSON(t, mux, http.MethodGet, "/employees/1", nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d body %s", rr.Code, rr.Body.String())
+	}
+	var got employeeDetailResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatalf("json: %v", err)
+	}
+	if got.ID != emp.ID || got.Department != "Sales" {
+		t.Fatalf("unexpected employee: %+v", got.Employee)
+	}
+	if got.ReviewSummary.Count != 2 || got.ReviewSummary.AverageRating != 4 || got.ReviewSummary.LatestState != ReviewStateDraft {
+		t.Fatalf("unexpected review summary: %+v", got.ReviewSummary)
+	}
+	if got.PayrollSummary.GrandTotalNet != 100000 || len(got.PayrollSummary.TotalsByPeriod) != 1 {
+		t.Fatalf("unexpected payroll summary: %+v", got.PayrollSummary)
+	}
+}
+
+func TestGetEmployee_notFound(t *testing.T) {
+	store, mux := setupTestServer(t)
+	defer store.Close()
+
+	if rr := doJSON(t, mux, http.MethodGet, "/employees/42", nil); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rr.Code)
+	}
+}
+
+func TestGetReview(t *testing.T) {
+	store, mux := setupTestServer(t)
+	defer store.Close()
+
+	emp, err := store.CreateEmployee(EmployeeInput{Name: "Alice"})
+	if err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+	review, err := store.CreatePerformanceReview(PerformanceReviewInput{EmployeeID: emp.ID, Period: "2024-Q4", Reviewer: "Boss", Rating: 4})
+	if err != nil {
+		t.Fatalf("seed review: %v", err)
+	}
+
+	rr := doJSON(t, mux, http.MethodGet, "/reviews/1", nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	var got PerformanceReview
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatalf("json: %v", err)
+	}
+	if got != review {
+		t.Fatalf("expected %+v, got %+v", review, got)
+	}
+	if rr := doJSON(t, mux, http.MethodGet, "/reviews/2", nil); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rr.Code)
+	}
+	if rr := doJSON(t, mux, http.MethodDelete, "/reviews/1", nil); rr.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405, got %d", rr.Code)
+	}
+}
+
+func TestGetPayroll(t *testing.T) {
+	store, mux := setupTestServer(t)
+	defer store.Close()
+
+	emp, err := store.CreateEmployee(EmployeeInput{Name: "Bob"})
+	if err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+	record, err := store.CreatePayrollRecord(PayrollRecordInput{EmployeeID: emp.ID, Period: "2024-11", BaseSalary: 150000, LineItems: genericLineItems(10000, 0)})
+	if err != nil {
+		t.Fatalf("seed payroll: %v", err)
+	}
+
+	rr := doJSON(t, mux, http.MethodGet, "/payroll/1", nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	var got PayrollRecord
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatalf("json: %v", err)
+	}
+	if !reflect.DeepEqual(got, record) {
+		t.Fatalf("expected %+v, got %+v", record, got)
+	}
+	if rr := doJSON(t, mux, http.MethodGet, "/payroll/2", nil); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rr.Code)
+	}
+	if rr := doJSON(t, mux, http.MethodGet, "/payroll/abc", nil); rr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d", rr.Code)
+	}
+	if rr := doJSON(t, mux, http.MethodPatch, "/payroll/1", nil); rr.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405, got %d", rr.Code)
+	}
+}
+
+func TestGetPayroll_internalError(t *testing.T) {
+	store, mux := setupTestServer(t)
+	_ = store.Close()
+
+	for _, path := range []string{"/payroll/1", "/reviews/1", "/employees/1"} {
+		if rr := doJSON(t, mux, http.MethodGet, path, nil); rr.Code != http.StatusInternalServerError {
+			t.Fatalf("expected 500 for %s, got %d", path, rr.Code)
+		}
+	}
+}
+
+func TestPayroll_VoidAndCorrect(t *testing.T) {
+	store, mux := setupTestServer(t)
+	defer store.Close()
+
+	emp, err := store.CreateEmployee(EmployeeInput{Name: "Bob"})
+	if err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+	for _, period := range []Period{"2024-11", "2024-10"} {
+		if _, err := store.CreatePayrollRecord(PayrollRecordInput{EmployeeID: emp.ID, Period: period, BaseSalary: 100000}); err != nil {
+			t.Fatalf("seed payroll: %v", err)
+		}
+	}
+
+	rr := doJSON(t, mux, http.MethodPost, "/payroll/2/void", map[string]string{"reason": ""})
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 without reason, got %d", rr.Code)
+	}
+	rr = doJSON(t, mux, http.MethodPost, "/payroll/2/void", map[string]string{"reason": "duplicate entry"})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d body %s", rr.Code, rr.Body.String())
+	}
+	rr = doJSON(t, mux, http.MethodPost, "/payroll/2/void", map[string]string{"reason": "duplicate entry"})
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("expected 409 voiding twice, got %d", rr.Code)
+	}
+
+	rr = doJSON(t, mux, http.MethodPost, "/payroll/1/correct", map[string]any{
+		"employeeId": emp.ID,
+		"period":     "2024-11",
+		"baseSalary": 1200.0,
+		"reason":     "raise not applied",
+	})
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d body %s", rr.Code, rr.Body.String())
+	}
+	var corrected PayrollRecord
+	if err := json.Unmarshal(rr.Body.Bytes(), &corrected); err != nil {
+		t.Fatalf("json: %v", err)
+	}
+	if corrected.CorrectsID != 1 || corrected.NetPay != 120000 {
+		t.Fatalf("unexpected correction: %+v", corrected)
+	}
+
+	list := doJSON(t, mux, http.MethodGet, "/payroll", nil)
+	var active payrollList
+	if err := json.Unmarshal(list.Body.Bytes(), &active); err != nil {
+		t.Fatalf("json: %v", err)
+	}
+	if len(active.Items) != 1 || active.Aggregates.GrandTotalNet != 120000 {
+		t.Fatalf("expected only the correction to count, got %+v", active)
+	}
+
+	list = doJSON(t, mux, http.MethodGet, "/payroll?includeVoided=true", nil)
+	var all payrollList
+	if err := json.Unmarshal(list.Body.Bytes(), &all); err != nil {
+		t.Fatalf("json: %v", err)
+	}
+	if len(all.Items) != 3 || all.Aggregates.GrandTotalNet != 120000 {
+		t.Fatalf("expected voided records listed but not totalled, got %+v", all)
+	}
+}
+
+func TestPayroll_DuplicatePeriod_409(t *testing.T) {
+	store, mux := setupTestServer(t)
+	defer store.Close()
+
+	emp := mustCreateEmployee(t, store, "Bob")
+	payload := map[string]any{"employeeId": emp.ID, "period": "2024-11", "baseSalary": 1000}
+	if resp := doJSON(t, mux, http.MethodPost, "/payroll", payload); resp.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", resp.Code)
+	}
+	resp := doJSON(t, mux, http.MethodPost, "/payroll", payload)
+	if resp.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d body %s", resp.Code, resp.Body.String())
+	}
+	var body struct {
+		ExistingID int64 `json:"existingId"`
+	}
+	if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil {
+		t.Fatalf("json: %v", err)
+	}
+	if body.ExistingID != 1 {
+		t.Fatalf("expected existingId 1, got %d", body.ExistingID)
+	}
+
+	// A voided payslip frees the period again.
+	if resp := doJSON(t, mux, http.MethodPost, "/payroll/1/void", map[string]string{"reason": "wrong amount"}); resp.Code != http.StatusOK {
+		t.Fatalf("expected 200 void, got %d", resp.Code)
+	}
+	if resp := doJSON(t, mux, http.MethodPost, "/payroll", payload); resp.Code != http.StatusCreated {
+		t.Fatalf("expected 201 after voiding, got %d", resp.Code)
+	}
+
+}
+
+func TestPayroll_CorrectionKeepsEmployeeAndPeriod(t *testing.T) {
+	store, mux := setupTestServer(t)
+	defer store.Close()
+
+	bob := mustCreateEmployee(t, store, "Bob")
+	ana := mustCreateEmployee(t, store, "Ana")
+	original, err := store.CreatePayrollRecord(PayrollRecordInput{EmployeeID: bob.ID, Period: "2024-12", BaseSalary: 1000})
+	if err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+
+	cases := map[string]struct {
+		body  map[string]any
+		field string
+	}{
+		"another employee": {map[string]any{"employeeId": ana.ID, "period": "2024-12", "baseSalary": 1100, "reason": "wrong person"}, "employeeId"},
+		"another period":   {map[string]any{"employeeId": bob.ID, "period": "2024-11", "baseSalary": 1100, "reason": "wrong month"}, "period"},
+	}
+	for name, tc := range cases {
+		resp := doJSON(t, mux, http.MethodPost, "/payroll/"+strconv.FormatInt(original.ID, 10)+"/correct", tc.body)
+		if resp.Code != http.StatusUnprocessableEntity {
+			t.Fatalf("%s: expected 422, got %d body %s", name, resp.Code, resp.Body.String())
+		}
+		p := decodeProblem(t, resp.Body.Bytes())
+		if len(p.Errors) != 1 || p.Errors[0].Field != tc.field || p.Errors[0].Code != FieldImmutable {
+			t.Fatalf("%s: unexpected problem %+v", name, p)
+		}
+	}
+	record, err := store.GetPayrollRecord(original.ID)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if record.Status != PayrollStatusActive || record.Version != original.Version {
+		t.Fatalf("a rejected correction must not touch the original, got %+v", record)
+	}
+}
+
+func TestPayroll_VoidAndCorrectErrors(t *testing.T) {
+	store, mux := setupTestServer(t)
+	defer store.Close()
+
+	cases := []struct {
+		name   string
+		method string
+		path   string
+		body   any
+		want   int
+	}{
+		{"void not found", http.MethodPost, "/payroll/9/void", map[string]string{"reason": "x"}, http.StatusNotFound},
+		{"correct not found", http.MethodPost, "/payroll/9/correct", map[string]any{"employeeId": 1, "period": "2024-11", "baseSalary": 1, "reason": "x"}, http.StatusNotFound},
+		{"correct invalid payload", http.MethodPost, "/payroll/9/correct", map[string]any{"employeeId": 1, "period": "2024-11", "baseSalary": -1, "reason": "x"}, http.StatusUnprocessableEntity},
+		{"correct missing reason", http.MethodPost, "/payroll/9/correct", map[string]any{"employeeId": 1, "period": "2024-11", "baseSalary": 1}, http.StatusUnprocessableEntity},
+		{"void invalid id", http.MethodPost, "/payroll/x/void", map[string]string{"reason": "x"}, http.StatusUnprocessableEntity},
+		{"void wrong method", http.MethodGet, "/payroll/1/void", nil, http.StatusMethodNotAllowed},
+	}
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			if rr := doJSON(t, mux, tc.method, tc.path, tc.body); rr.Code != tc.want {
+				t.Fatalf("expected %d, got %d body %s", tc.want, rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestPayroll_WireFormatIsExact(t *testing.T) {
+	store, mux := setupTestServer(t)
+	defer store.Close()
+
+	emp, err := store.CreateEmployee(EmployeeInput{Name: "Cents"})
+	if err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+	for _, period := range []string{"2024-09", "2024-10", "2024-11"} {
+		resp := doJSON(t, mux, http.MethodPost, "/payroll", map[string]any{
+			"employeeId": emp.ID,
+			"period":     period,
+			"baseSalary": 0.1,
+			"bonuses":    "0.2",
+		})
+		if resp.Code != http.StatusCreated {
+			t.Fatalf("expected 201, got %d body %s", resp.Code, resp.Body.String())
+		}
+	}
+	rr := doJSON(t, mux, http.MethodGet, "/payroll", nil)
+	var raw struct {
+		Aggregates struct {
+			GrandTotalNet json.RawMessage `json:"grandTotalNet"`
+		} `json:"aggregates"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &raw); err != nil {
+		t.Fatalf("json: %v", err)
+	}
+	if string(raw.Aggregates.GrandTotalNet) != "0.90" {
+		t.Fatalf("expected grand total 0.90, got %s", raw.Aggregates.GrandTotalNet)
+	}
+
+	resp := doJSON(t, mux, http.MethodPost, "/payroll", map[string]any{
+		"employeeId": emp.ID,
+		"period":     "2024-11",
+		"baseSalary": "a lot",
+	})
+	if resp.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 for non-numeric amount, got %d", resp.Code)
+	}
+}
+
+func TestPayroll_LineItems(t *testing.T) {
+	store, mux := setupTestServer(t)
+	defer store.Close()
+	emp := mustCreateEmployee(t, store, "Alice")
+
+	resp := doJSON(t, mux, http.MethodPost, "/payroll", map[string]any{
+		"employeeId": emp.ID,
+		"period":     "2024-11",
+		"baseSalary": 1000,
+		"lineItems": []map[string]any{
+			{"type": "earning", "code": "PRESENTISM", "description": "Attendance bonus", "amount": 50},
+			{"type": "deduction", "code": "PENSION", "description": "Pension 11%", "amount": 110},
+			{"type": "deduction", "code": "HEALTH", "description": "Health 3%", "amount": 30},
+			{"type": "deduction", "code": "UNION", "description": "Union dues", "amount": "12.50"},
+		},
+	})
+	if resp.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d body %s", resp.Code, resp.Body.String())
+	}
+
+	list := doJSON(t, mux, http.MethodGet, "/payroll", nil)
+	var body struct {
+		Items []PayrollRecord `json:"items"`
+	}
+	if err := json.Unmarshal(list.Body.Bytes(), &body); err != nil {
+		t.Fatalf("json: %v", err)
+	}
+	if len(body.Items) != 1 {
+		t.Fatalf("expected one record, got %+v", body.Items)
+	}
+	rec := body.Items[0]
+	if len(rec.LineItems) != 4 || rec.LineItems[1].Code != "PENSION" || rec.LineItems[3].Amount != 1250 {
+		t.Fatalf("expected the line items in order, got %+v", rec.LineItems)
+	}
+	if rec.Bonuses != 5000 || rec.Deductions != 15250 || rec.NetPay != 89750 {
+		t.Fatalf("expected totals derived from the lines, got %+v", rec)
+	}
+}
+
+func TestPayroll_LegacyAmountsBecomeGenericLines(t *testing.T) {
+	store, mux := setupTestServer(t)
+	defer store.Close()
+	emp := mustCreateEmployee(t, store, "Alice")
+
+	resp := doJSON(t, mux, http.MethodPost, "/payroll", map[string]any{
+		"employeeId": emp.ID, "period": "2024-11", "baseSalary": 1000, "bonuses": 200, "deductions": 100,
+	})
+	if resp.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d body %s", resp.Code, resp.Body.String())
+	}
+	var rec PayrollRecord
+	if err := json.Unmarshal(resp.Body.Bytes(), &rec); err != nil {
+		t.Fatalf("json: %v", err)
+	}
+	want := []PayrollLineItem{
+		{Type: PayrollLineEarning, Code: genericBonusCode, Description: "Bonuses", Amount: 20000},
+		{Type: PayrollLineDeduction, Code: genericDeductionCode, Description: "Deductions", Amount: 10000},
+	}
+	if !reflect.DeepEqual(rec.LineItems, want) || rec.NetPay != 110000 {
+		t.Fatalf("expected one generic line per amount, got %+v", rec)
+	}
+}
+
+func TestPayroll_InvalidLineItems(t *testing.T) {
+	store, mux := setupTestServer(t)
+	defer store.Close()
+	emp := mustCreateEmployee(t, store, "Alice")
+
+	resp := doJSON(t, mux, http.MethodPost, "/payroll", map[string]any{
+		"employeeId": emp.ID,
+		"period":     "2024-11",
+		"baseSalary": 1000,
+		"lineItems": []map[string]any{
+			{"type": "deduction", "code": "PENSION", "amount": 110},
+			{"type": "refund", "code": " ", "amount": -5},
+		},
+	})
+	if resp.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d", resp.Code)
+	}
+	var fields []string
+	for _, fe := range decodeProblem(t, resp.Body.Bytes()).Errors {
+		fields = append(fields, fe.Field)
+	}
+	if want := []string{"lineItems[1].type", "lineItems[1].code", "lineItems[1].amount"}; !reflect.DeepEqual(fields, want) {
+		t.Fatalf("expected errors for %v, got %v", want, fields)
+	}
+}
+
+func TestCreateForUnknownEmployee_422(t *testing.T) {
+	store, mux := setupTestServer(t)
+	defer store.Close()
+
+	resp := doJSON(t, mux, http.MethodPost, "/reviews", map[string]any{
+		"employeeId": 77,
+		"period":     "2024-Q4",
+		"reviewer":   "Boss",
+		"rating":     4,
+	})
+	if resp.Code != http.StatusUnprocessableEntity || !bytes.Contains(resp.Body.Bytes(), []byte(unknownEmployeeMsg)) {
+		t.Fatalf("expected 422 unknown employee for review, got %d %s", resp.Code, resp.Body.String())
+	}
+	resp = doJSON(t, mux, http.MethodPost, "/payroll", map[string]any{
+		"employeeId": 77,
+		"period":     "2024-11",
+		"baseSalary": 100,
+	})
+	if resp.Code != http.StatusUnprocessableEntity || !bytes.Contains(resp.Body.Bytes(), []byte(unknownEmployeeMsg)) {
+		t.Fatalf("expected 422 unknown employee for payroll, got %d %s", resp.Code, resp.Body.String())
+	}
+
+	emp, err := store.CreateEmployee(EmployeeInput{Name: "Real"})
+	if err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+	if _, err := store.CreatePayrollRecord(PayrollRecordInput{EmployeeID: emp.ID, Period: "2024-11", BaseSalary: 100}); err != nil {
+		t.Fatalf("seed payroll: %v", err)
+	}
+	resp = doJSON(t, mux, http.MethodPost, "/payroll/1/correct", map[string]any{
+		"employeeId": 77,
+		"period":     "2024-11",
+		"baseSalary": 100,
+		"reason":     "wrong employee",
+	})
+	if resp.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 correcting to unknown employee, got %d", resp.Code)
+	}
+	if rec, err := store.GetPayrollRecord(1); err != nil || rec.Status != PayrollStatusActive {
+		t.Fatalf("expected failed correction to leave the original active, got %+v %v", rec, err)
+	}
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+)
+
+var ErrIdempotencyKeyReused = errors.New("Idempotency-Key was already used for a different request")
+var ErrIdempotencyKeyInProgress = errors.New("a request with this Idempotency-Key is still being processed")
+
+// idempotencyKeyTTL is how long a key is remembered. Retries after that are
+// treated as new requests.
+const idempotencyKeyTTL = 24 * time.Hour
+
+// idempotencyLeaseTTL is how long a reservation holds the key while its
+// request runs. A request that never finished, say because the process died,
+// frees the key once the lease runs out instead of blocking it for a day.
+const idempotencyLeaseTTL = 5 * time.Minute
+
+const maxIdempotencyKeyLength = 255
+
+// idempotentHeaders are the response headers stored with the body and replayed.
+var idempotentHeaders = []string{"Content-Type", "ETag"}
+
+// IdempotentResponse is a stored response. Status 0 means the first request
+// holding the key has not finished yet.
+type IdempotentResponse struct {
+	Status int
+	Header map[string]string
+	Body   []byte
+}
+
+// ReserveIdempotencyKey claims the key for the caller. When the key is new it
+// returns nil and the caller must later call CompleteIdempotencyKey or
+// ReleaseIdempotencyKey. When the key was already used with the same
+// fingerprint it returns the stored response; a different fingerprint gives
+// ErrIdempotencyKeyReused and an unfinished request ErrIdempotencyKeyInProgress
+// until its lease runs out.
+func (s *Store) ReserveIdempotencyKey(userID int64, key, fingerprint string) (*IdempotentResponse, error) {
+	var stored *IdempotentResponse
+	err := s.inTx(func(tx *sql.Tx) error {
+		now := time.Now().UTC()
+		// Expired keys and lapsed reservations are dropped here rather than by
+		// a background job.
+		if _, err := tx.Exec(`DELETE FROM idempotency_keys WHERE created_at < ? OR (status = 0 AND created_at < ?)`,
+			now.Add(-idempotencyKeyTTL).Format(time.RFC3339), now.Add(-idempotencyLeaseTTL).Format(time.RFC3339)); err != nil {
+			return err
+		}
+		var (
+			storedFingerprint string
+			status            int
+			header            sql.NullString
+			body              []byte
+		)
+		err := tx.QueryRow(`SELECT fingerprint, status, header_json, body FROM idempotency_keys WHERE user_id = ? AND key = ?`,
+			userID, key).Scan(&storedFingerprint, &status, &header, &body)
+		if errors.Is(err, sql.ErrNoRows) {
+			_, err = tx.Exec(`INSERT INTO idempotency_keys(user_id, key, fingerprint, status, created_at) VALUES(?, ?, ?, 0, ?)`,
+				userID, key, fingerprint, now.Format(time.RFC3339))
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		if storedFingerprint != fingerprint {
+			return ErrIdempotencyKeyReused
+		}
+		if status == 0 {
+			return ErrIdempotencyKeyInProgress
+		}
+		stored = &IdempotentResponse{Status: status, Body: body}
+		if header.Valid {
+			if err := json.Unmarshal([]byte(header.String), &stored.Header); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stored, nil
+}
+
+// CompleteIdempotencyKey stores the response for replays.
+func (s *Store) CompleteIdempotencyKey(userID int64, key string, resp IdempotentResponse) error {
+	header, err := json.Marshal(resp.Header)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`UPDATE idempotency_keys SET status = ?, header_json = ?, body = ? WHERE user_id = ? AND key = ?`,
+		resp.Status, string(header), resp.Body, userID, key)
+	return err
+}
+
+// ReleaseIdempotencyKey forgets a reservation so the request can be retried.
+func (s *Store) ReleaseIdempotencyKey(userID int64, key string) error {
+	_, err := s.db.Exec(`DELETE FROM idempotency_keys WHERE user_id = ? AND key = ? AND status = 0`, userID, key)
+	return err
+}
+
+// idempotencyFingerprint identifies a request by method, path and body. A
+// JSON body is hashed in canonical form, so a retry that serializes the same
+// payload with other key order or whitespace still matches. Anything else is
+// hashed as sent.
+func idempotencyFingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+	h.Write(canonicalJSON(body))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// canonicalJSON re-encodes a JSON document with sorted object keys and no
+// insignificant whitespace. Numbers keep their original text. Input that is
+// not a single JSON document is returned unchanged.
+func canonicalJSON(body []byte) []byte {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil || dec.More() {
+		return body
+	}
+	canonical, err := json.Marshal(v)
+	if err != nil {
+		return body
+	}
+	return canonical
+}
+
+// responseRecorder passes a response through while keeping a copy of it.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
+
+// idempotent makes a create handler safe to retry. Requests carrying an
+// Idempotency-Key run once per caller and key; repeats get the stored response
+// back with an Idempotent-Replayed header. Server errors are not stored, so
+// the client can retry them with the same key, and neither is a handler that
+// panics.
+func (a *API) idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		principal, _ := principalFrom(r.Context())
+		// Keys are kept per user; anonymous development callers have none to
+		// share them under, so their requests simply run.
+		if key == "" || principal.User.ID == 0 {
+			next(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			writeError(w, http.StatusUnprocessableEntity, "Idempotency-Key must be at most 255 characters")
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+		if err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		userID := principal.User.ID
+		stored, err := a.store.ReserveIdempotencyKey(userID, key, idempotencyFingerprint(r, body))
+		switch {
+		case errors.Is(err, ErrIdempotencyKeyReused):
+			writeErrorFor(w, http.StatusUnprocessableEntity, err)
+			return
+		case errors.Is(err, ErrIdempotencyKeyInProgress):
+			writeErrorFor(w, http.StatusConflict, err)
+			return
+		case err != nil:
+			writeError(w, http.StatusInternalServerError, internalErrorMsg)
+			return
+		case stored != nil:
+			for name, value := range stored.Header {
+				w.Header().Set(name, value)
+			}
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(stored.Status)
+			_, _ = w.Write(stored.Body)
+			return
+		}
+
+		rec := &responseRecorder{ResponseWriter: w}
+		defer func() {
+			if p := recover(); p != nil {
+				_ = a.store.ReleaseIdempotencyKey(userID, key)
+				panic(p)
+			}
+		}()
+		next(rec, r)
+		if rec.status == 0 || rec.status >= http.StatusInternalServerError {
+			_ = a.store.ReleaseIdempotencyKey(userID, key)
+			return
+		}
+		resp := IdempotentResponse{Status: rec.status, Header: map[string]string{}, Body: rec.body.Bytes()}
+		for _, name := range idempotentHeaders {
+			if v := w.Header().Get(name); v != "" {
+				resp.Header[name] = v
+			}
+		}
+		if err := a.store.CompleteIdempotencyKey(userID, key, resp); err != nil {
+			_ = a.store.ReleaseIdempotencyKey(userID, key)
+		}
+	}
+}
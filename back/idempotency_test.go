@@ -0,0 +1,189 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIdempotentCreateReplays(t *testing.T) {
+	store, mux := setupTestServer(t)
+	defer store.Close()
+	emp := mustCreateEmployee(t, store, "Alice")
+
+	headers := map[string]string{"Idempotency-Key": "import-2024-11-alice"}
+	payload := map[string]any{"employeeId": emp.ID, "period": "2024-11", "baseSalary": 1000}
+	first := doJSONWithHeaders(t, mux, http.MethodPost, "/payroll", headers, payload)
+	if first.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d %s", first.Code, first.Body.String())
+	}
+	if first.Header().Get("Idempotent-Replayed") != "" {
+		t.Fatalf("first response must not be marked as a replay")
+	}
+
+	retry := doJSONWithHeaders(t, mux, http.MethodPost, "/payroll", headers, payload)
+	if retry.Code != http.StatusCreated || retry.Body.String() != first.Body.String() {
+		t.Fatalf("expected the original response, got %d %s", retry.Code, retry.Body.String())
+	}
+	if retry.Header().Get("Idempotent-Replayed") != "true" || retry.Header().Get("ETag") != first.Header().Get("ETag") {
+		t.Fatalf("unexpected replay headers %v", retry.Header())
+	}
+	records, err := store.ListPayrollRecords(PayrollFilter{IncludeVoided: true})
+	if err != nil {
+		t.Fatalf("list payroll: %v", err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("expected a single payslip, got %d", len(records))
+	}
+
+	payload["baseSalary"] = 2000
+	resp := doJSONWithHeaders(t, mux, http.MethodPost, "/payroll", headers, payload)
+	if resp.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 for a different body under the same key, got %d", resp.Code)
+	}
+	resp = doJSONWithHeaders(t, mux, http.MethodPost, "/employees", headers, map[string]any{"name": "Bob"})
+	if resp.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 for the same key on another route, got %d", resp.Code)
+	}
+}
+
+func TestIdempotentCreateReplaysClientErrors(t *testing.T) {
+	store, mux := setupTestServer(t)
+	defer store.Close()
+
+	headers := map[string]string{"Idempotency-Key": "bad-review"}
+	payload := map[string]any{"employeeId": 404, "period": "2024-Q4", "reviewer": "Boss", "rating": 3}
+	first := doJSONWithHeaders(t, mux, http.MethodPost, "/reviews", headers, payload)
+	if first.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d", first.Code)
+	}
+	retry := doJSONWithHeaders(t, mux, http.MethodPost, "/reviews", headers, payload)
+	if retry.Code != first.Code || retry.Header().Get("Idempotent-Replayed") != "true" {
+		t.Fatalf("expected the stored 422 to be replayed, got %d %v", retry.Code, retry.Header())
+	}
+}
+
+func TestIdempotentKeysExpire(t *testing.T) {
+	store, mux := setupTestServer(t)
+	defer store.Close()
+
+	headers := map[string]string{"Idempotency-Key": "hire-bob"}
+	if resp := doJSONWithHeaders(t, mux, http.MethodPost, "/employees", headers, map[string]any{"name": "Bob"}); resp.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", resp.Code)
+	}
+	if _, err := store.db.Exec(`UPDATE idempotency_keys SET created_at = '2000-01-01T00:00:00Z'`); err != nil {
+		t.Fatalf("age key: %v", err)
+	}
+	resp := doJSONWithHeaders(t, mux, http.MethodPost, "/employees", headers, map[string]any{"name": "Bob"})
+	if resp.Code != http.StatusCreated || resp.Header().Get("Idempotent-Replayed") != "" {
+		t.Fatalf("expected an expired key to run the request again, got %d %v", resp.Code, resp.Header())
+	}
+}
+
+func TestIdempotencyKeysArePerUser(t *testing.T) {
+	store, mux := newTestMux(t)
+	defer store.Close()
+	alice := withTestPrincipal(mux, Principal{User: User{ID: 1, Role: RoleHR}})
+	bob := withTestPrincipal(mux, Principal{User: User{ID: 2, Role: RoleHR}})
+
+	headers := map[string]string{"Idempotency-Key": "same-key"}
+	if resp := doJSONWithHeaders(t, alice, http.MethodPost, "/employees", headers, map[string]any{"name": "Carol"}); resp.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", resp.Code)
+	}
+	resp := doJSONWithHeaders(t, bob, http.MethodPost, "/employees", headers, map[string]any{"name": "Carol"})
+	if resp.Code != http.StatusCreated || resp.Header().Get("Idempotent-Replayed") != "" {
+		t.Fatalf("expected another user's key not to replay, got %d %v", resp.Code, resp.Header())
+	}
+}
+
+func TestReserveIdempotencyKeyInProgress(t *testing.T) {
+	store := newMemoryStore(t)
+	defer store.Close()
+
+	if stored, err := store.ReserveIdempotencyKey(1, "k", "fp"); err != nil || stored != nil {
+		t.Fatalf("expected a fresh reservation, got %v %v", stored, err)
+	}
+	if _, err := store.ReserveIdempotencyKey(1, "k", "fp"); !errors.Is(err, ErrIdempotencyKeyInProgress) {
+		t.Fatalf("expected ErrIdempotencyKeyInProgress, got %v", err)
+	}
+	if err := store.ReleaseIdempotencyKey(1, "k"); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	if stored, err := store.ReserveIdempotencyKey(1, "k", "other"); err != nil || stored != nil {
+		t.Fatalf("expected a released key to be reusable, got %v %v", stored, err)
+	}
+}
+
+func TestReserveIdempotencyKeyReclaimsLapsedReservation(t *testing.T) {
+	store := newMemoryStore(t)
+	defer store.Close()
+
+	if _, err := store.ReserveIdempotencyKey(1, "k", "fp"); err != nil {
+		t.Fatalf("reserve: %v", err)
+	}
+	// The lease has not run out yet.
+	started := time.Now().UTC().Add(-idempotencyLeaseTTL / 2).Format(time.RFC3339)
+	if _, err := store.db.Exec(`UPDATE idempotency_keys SET created_at = ?`, started); err != nil {
+		t.Fatalf("age key: %v", err)
+	}
+	if _, err := store.ReserveIdempotencyKey(1, "k", "fp"); !errors.Is(err, ErrIdempotencyKeyInProgress) {
+		t.Fatalf("expected ErrIdempotencyKeyInProgress, got %v", err)
+	}
+
+	started = time.Now().UTC().Add(-idempotencyLeaseTTL - time.Minute).Format(time.RFC3339)
+	if _, err := store.db.Exec(`UPDATE idempotency_keys SET created_at = ?`, started); err != nil {
+		t.Fatalf("age key: %v", err)
+	}
+	if stored, err := store.ReserveIdempotencyKey(1, "k", "fp"); err != nil || stored != nil {
+		t.Fatalf("expected a lapsed reservation to be reclaimed, got %v %v", stored, err)
+	}
+
+	// Finished keys outlive the lease and keep replaying.
+	if err := store.CompleteIdempotencyKey(1, "k", IdempotentResponse{Status: http.StatusCreated, Body: []byte("{}")}); err != nil {
+		t.Fatalf("complete: %v", err)
+	}
+	if _, err := store.db.Exec(`UPDATE idempotency_keys SET created_at = ?`, started); err != nil {
+		t.Fatalf("age key: %v", err)
+	}
+	if stored, err := store.ReserveIdempotencyKey(1, "k", "fp"); err != nil || stored == nil || stored.Status != http.StatusCreated {
+		t.Fatalf("expected the finished key to replay, got %v %v", stored, err)
+	}
+}
+
+func TestIdempotencyFingerprintIgnoresJSONFormatting(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/payroll", nil)
+	compact := idempotencyFingerprint(req, []byte(`{"employeeId":1,"period":"2024-11","baseSalary":1000}`))
+	reordered := idempotencyFingerprint(req, []byte("{\n  \"baseSalary\": 1000,\n  \"period\": \"2024-11\",\n  \"employeeId\": 1\n}"))
+	if compact != reordered {
+		t.Fatalf("expected key order and whitespace not to change the fingerprint")
+	}
+	if changed := idempotencyFingerprint(req, []byte(`{"employeeId":1,"period":"2024-11","baseSalary":2000}`)); changed == compact {
+		t.Fatalf("expected a different value to change the fingerprint")
+	}
+}
+
+func TestIdempotentReleasesKeyWhenHandlerPanics(t *testing.T) {
+	store := newMemoryStore(t)
+	defer store.Close()
+	api := NewAPI(store, NewTokenSigner([]byte("test-secret"), time.Hour))
+	handler := withTestPrincipal(api.idempotent(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}), Principal{User: User{ID: 1, Role: RoleHR}})
+
+	req := httptest.NewRequest(http.MethodPost, "/employees", strings.NewReader(`{"name":"Bob"}`))
+	req.Header.Set("Idempotency-Key", "k")
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatalf("expected the panic to propagate")
+			}
+		}()
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}()
+	if stored, err := store.ReserveIdempotencyKey(1, "k", idempotencyFingerprint(req, []byte(`{"name":"Bob"}`))); err != nil || stored != nil {
+		t.Fatalf("expected the key to be free for a retry, got %v %v", stored, err)
+	}
+}
@@ -1,9 +1,14 @@
 package main
 
 import (
+	"crypto/rand"
+	"errors"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 func main() {
@@ -16,13 +21,46 @@ func main() {
 		log.Fatalf("failed to open db: %v", err)
 	}
 	defer store.Close()
-	if err := store.Init(); err != nil {
-		log.Fatalf("failed to init db: %v", err)
+	if err := store.Migrate(); err != nil {
+		log.Fatalf("failed to migrate db: %v", err)
+	}
+	// "server migrate" only applies pending migrations, e.g. as a deploy step.
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		version, err := store.SchemaVersion()
+		if err != nil {
+			log.Fatalf("failed to read schema version: %v", err)
+		}
+		log.Printf("database is at schema version %d", version)
+		return
+	}
+
+	if err := bootstrapAdmin(store); err != nil {
+		log.Fatalf("failed to bootstrap admin user: %v", err)
+	}
+	signer, err := tokenSignerFromEnv()
+	if err != nil {
+		log.Fatalf("failed to configure auth: %v", err)
+	}
+	cors, err := corsPolicyFromEnv()
+	if err != nil {
+		log.Fatalf("failed to configure CORS: %v", err)
+	}
+	// REVIEW_WORKFLOW_FILE points to a JSON workflow replacing the default one.
+	if path := os.Getenv("REVIEW_WORKFLOW_FILE"); path != "" {
+		workflow, err := LoadReviewWorkflow(path)
+		if err != nil {
+			log.Fatalf("failed to load review workflow: %v", err)
+		}
+		store.SetReviewWorkflow(workflow)
 	}
 
 	mux := http.NewServeMux()
-	api := NewAPI(store)
+	api := NewAPI(store, signer)
 	api.RegisterRoutes(mux)
+	if devMode() {
+		log.Printf("development mode: requests without a token act as %s", anonymousPrincipal.User.Role)
+		api.AllowAnonymous(anonymousPrincipal)
+	}
 
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -30,38 +68,91 @@ func main() {
 	}
 	addr := ":" + port
 	log.Printf("listening on %s", addr)
-	if err := http.ListenAndServe(addr, withCORS(mux)); err != nil {
+	if err := http.ListenAndServe(addr, withCORS(cors, api.Authenticate(mux))); err != nil {
 		log.Fatal(err)
 	}
 }
 
-// withCORS disables CORS entirely by returning permissive headers for every request.
-func withCORS(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		const defaultMethods = "GET, POST, PUT, DELETE, OPTIONS, PATCH"
-
-		// Always allow every origin.
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Expose-Headers", "*")
-		w.Header().Set("Access-Control-Max-Age", "86400")
+// devMode reports whether APP_ENV is "development". Only then may settings
+// that production must provide, such as AUTH_SECRET, fall back to local defaults.
+func devMode() bool {
+	return os.Getenv("APP_ENV") == "development"
+}
 
-		// Mirror the requested headers/methods when present so browsers never block.
-		if reqHeaders := r.Header.Get("Access-Control-Request-Headers"); reqHeaders != "" {
-			w.Header().Set("Access-Control-Allow-Headers", reqHeaders)
-		} else {
-			w.Header().Set("Access-Control-Allow-Headers", "*")
+// tokenSignerFromEnv reads AUTH_SECRET and AUTH_TOKEN_TTL. The secret is
+// required: a generated one would differ between restarts and replicas and
+// log everyone out. Only dev mode falls back to a random secret.
+func tokenSignerFromEnv() (*TokenSigner, error) {
+	ttl := 12 * time.Hour
+	if v := os.Getenv("AUTH_TOKEN_TTL"); v != "" {
+		parsed, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, err
 		}
-
-		if reqMethod := r.Header.Get("Access-Control-Request-Method"); reqMethod != "" {
-			w.Header().Set("Access-Control-Allow-Methods", reqMethod)
-		} else {
-			w.Header().Set("Access-Control-Allow-Methods", defaultMethods)
+		ttl = parsed
+	}
+	secret := []byte(os.Getenv("AUTH_SECRET"))
+	if len(secret) == 0 {
+		if !devMode() {
+			return nil, errors.New("AUTH_SECRET is required; set APP_ENV=development to use a random one locally")
 		}
+		log.Printf("AUTH_SECRET not set; generating a random secret, login tokens will not survive restarts")
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+	}
+	return NewTokenSigner(secret, ttl), nil
+}
+
+// bootstrapAdmin creates the first user from AUTH_BOOTSTRAP_EMAIL and
+// AUTH_BOOTSTRAP_PASSWORD when the users table is still empty.
+func bootstrapAdmin(store *Store) error {
+	email := os.Getenv("AUTH_BOOTSTRAP_EMAIL")
+	password := os.Getenv("AUTH_BOOTSTRAP_PASSWORD")
+	if email == "" || password == "" {
+		return nil
+	}
+	count, err := store.CountUsers()
+	if err != nil || count > 0 {
+		return err
+	}
+	user, err := store.CreateUser(UserInput{Email: email, Name: "Administrator", Password: password, Role: RoleAdmin})
+	if err != nil {
+		return err
+	}
+	log.Printf("created bootstrap user %s", user.Email)
+	return nil
+}
 
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusNoContent)
-			return
+// corsPolicyFromEnv reads CORS_ALLOWED_ORIGINS (comma separated),
+// CORS_ALLOW_CREDENTIALS and CORS_MAX_AGE. The origins are required: every
+// cross-origin request from an unlisted origin is refused, so a missing value
+// would lock the deployed frontend out. Only dev mode defaults to the local
+// frontend.
+func corsPolicyFromEnv() (*CORSPolicy, error) {
+	origins, ok := os.LookupEnv("CORS_ALLOWED_ORIGINS")
+	if !ok {
+		if !devMode() {
+			return nil, errors.New("CORS_ALLOWED_ORIGINS is required; set APP_ENV=development to allow http://localhost:3000 locally")
+		}
+		origins = "http://localhost:3000"
+	}
+	credentials := true
+	if v := os.Getenv("CORS_ALLOW_CREDENTIALS"); v != "" {
+		parsed, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, err
 		}
-		next.ServeHTTP(w, r)
-	})
+		credentials = parsed
+	}
+	maxAge := 10 * time.Minute
+	if v := os.Getenv("CORS_MAX_AGE"); v != "" {
+		parsed, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, err
+		}
+		maxAge = parsed
+	}
+	return NewCORSPolicy(strings.Split(origins, ","), credentials, maxAge)
 }
@@ -0,0 +1,625 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// migration is one forward-only schema change. Each migration runs in its own
+// transaction together with its schema_version row, so a failure leaves the
+// database at the previous version.
+type migration struct {
+	version int
+	name    string
+	up      func(tx *sql.Tx) error
+}
+
+// migrations must stay ordered by version and must never be edited once
+// released; add a new entry instead.
+var migrations = []migration{
+	{1, "initial schema", migrateInitialSchema},
+	{2, "employee profile", migrateEmployeeProfile},
+	{3, "employee offboarding", migrateEmployeeOffboarding},
+	{4, "payroll voids and corrections", migratePayrollCorrections},
+	{5, "payroll amounts in cents", migratePayrollCents},
+	{6, "users and api tokens", migrateUsers},
+	{7, "roles and reporting lines", migrateRoles},
+	{8, "audit log", migrateAuditLog},
+	{9, "review transition history", migrateReviewTransitions},
+	{10, "row versions for optimistic concurrency", migrateRowVersions},
+	{11, "one review and one active payslip per period", migratePeriodUniqueness},
+	{12, "idempotency keys", migrateIdempotencyKeys},
+	{13, "employee name search index", migrateEmployeeSearch},
+	{14, "structured periods", migrateStructuredPeriods},
+	{15, "payroll line items", migratePayrollLineItems},
+}
+
+// Migrate brings the database schema up to the latest version.
+func (s *Store) Migrate() error {
+	return s.migrate(migrations)
+}
+
+// SchemaVersion returns the highest applied migration, or 0 for an empty database.
+func (s *Store) SchemaVersion() (int, error) {
+	if err := s.ensureSchemaVersionTable(); err != nil {
+		return 0, err
+	}
+	var version int
+	err := s.db.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_version").Scan(&version)
+	return version, err
+}
+
+func (s *Store) migrate(list []migration) error {
+	current, err := s.SchemaVersion()
+	if err != nil {
+		return err
+	}
+	if latest := latestVersion(list); current > latest {
+		return fmt.Errorf("database schema version %d is newer than this build supports (%d)", current, latest)
+	}
+	for _, m := range list {
+		if m.version <= current {
+			continue
+		}
+		if err := s.applyMigration(m); err != nil {
+			return fmt.Errorf("migration %d (%s): %w", m.version, m.name, err)
+		}
+	}
+	return nil
+}
+
+func (s *Store) applyMigration(m migration) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := m.up(tx); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("INSERT INTO schema_version(version, name, applied_at) VALUES(?, ?, ?)",
+		m.version, m.name, time.Now().UTC().Format(time.RFC3339)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *Store) ensureSchemaVersionTable() error {
+	_, err := s.db.Exec(`CREATE TABLE IF NOT EXISTS schema_version (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TEXT NOT NULL
+	)`)
+	return err
+}
+
+func latestVersion(list []migration) int {
+	latest := 0
+	for _, m := range list {
+		if m.version > latest {
+			latest = m.version
+		}
+	}
+	return latest
+}
+
+func addColumnIfMissing(tx *sql.Tx, table, column, definition string) error {
+	exists, err := columnExists(tx, table, column)
+	if err != nil || exists {
+		return err
+	}
+	_, err = tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
+
+func columnExists(tx *sql.Tx, table, column string) (bool, error) {
+	var count int
+	err := tx.QueryRow("SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?", table, column).Scan(&count)
+	return count > 0, err
+}
+
+// Databases created before versioned migrations already contain some of the
+// columns below, so column additions are written to be idempotent.
+
+func migrateInitialSchema(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+		CREATE TABLE IF NOT EXISTS employees (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL
+		);
+
+		CREATE TABLE IF NOT EXISTS performance_reviews (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			employee_id INTEGER NOT NULL,
+			period TEXT NOT NULL,
+			reviewer TEXT NOT NULL,
+			rating INTEGER NOT NULL CHECK (rating BETWEEN 1 AND 5),
+			strengths TEXT,
+			opportunities TEXT,
+			state TEXT NOT NULL,
+			FOREIGN KEY(employee_id) REFERENCES employees(id) ON DELETE CASCADE
+		);
+		CREATE INDEX IF NOT EXISTS idx_reviews_employee_period ON performance_reviews(employee_id, period);
+
+		CREATE TABLE IF NOT EXISTS payroll_records (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			employee_id INTEGER NOT NULL,
+			period TEXT NOT NULL,
+			base_salary REAL NOT NULL,
+			overtime_hours REAL NOT NULL DEFAULT 0,
+			overtime_rate REAL NOT NULL DEFAULT 0,
+			bonuses REAL NOT NULL DEFAULT 0,
+			deductions REAL NOT NULL DEFAULT 0,
+			net_pay REAL NOT NULL,
+			FOREIGN KEY(employee_id) REFERENCES employees(id) ON DELETE CASCADE
+		);
+		CREATE INDEX IF NOT EXISTS idx_payroll_employee_period ON payroll_records(employee_id, period);
+	`)
+	return err
+}
+
+func migrateEmployeeProfile(tx *sql.Tx) error {
+	columns := []struct{ name, definition string }{
+		{"email", "TEXT"},
+		{"department", "TEXT NOT NULL DEFAULT ''"},
+		{"job_title", "TEXT NOT NULL DEFAULT ''"},
+		{"hire_date", "TEXT NOT NULL DEFAULT ''"},
+		{"status", "TEXT NOT NULL DEFAULT 'active'"},
+		{"national_id", "TEXT"},
+	}
+	for _, col := range columns {
+		if err := addColumnIfMissing(tx, "employees", col.name, col.definition); err != nil {
+			return err
+		}
+	}
+	_, err := tx.Exec(`
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_employees_email ON employees(email);
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_employees_national_id ON employees(national_id);
+	`)
+	return err
+}
+
+func migrateEmployeeOffboarding(tx *sql.Tx) error {
+	if err := addColumnIfMissing(tx, "employees", "termination_date", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	return addColumnIfMissing(tx, "employees", "archived_at", "TEXT")
+}
+
+func migratePayrollCorrections(tx *sql.Tx) error {
+	columns := []struct{ name, definition string }{
+		{"status", "TEXT NOT NULL DEFAULT 'active'"},
+		{"void_reason", "TEXT NOT NULL DEFAULT ''"},
+		{"voided_at", "TEXT NOT NULL DEFAULT ''"},
+		{"corrects_id", "INTEGER REFERENCES payroll_records(id)"},
+		{"created_at", "TEXT NOT NULL DEFAULT ''"},
+	}
+	for _, col := range columns {
+		if err := addColumnIfMissing(tx, "payroll_records", col.name, col.definition); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migratePayrollCents copies REAL payroll amounts into integer cent columns and
+// drops the REAL columns. Values are converted from their shortest decimal form
+// so 0.285 becomes 29 cents instead of drifting to 28.
+func migratePayrollCents(tx *sql.Tx) error {
+	columns := []struct{ legacy, cents string }{
+		{"base_salary", "base_salary_cents"},
+		{"overtime_rate", "overtime_rate_cents"},
+		{"bonuses", "bonuses_cents"},
+		{"deductions", "deductions_cents"},
+		{"net_pay", "net_pay_cents"},
+	}
+	for _, col := range columns {
+		if err := addColumnIfMissing(tx, "payroll_records", col.cents, "INTEGER NOT NULL DEFAULT 0"); err != nil {
+			return err
+		}
+	}
+	legacy, err := columnExists(tx, "payroll_records", "base_salary")
+	if err != nil || !legacy {
+		return err
+	}
+
+	rows, err := tx.Query(`SELECT id, base_salary, overtime_rate, bonuses, deductions, net_pay FROM payroll_records`)
+	if err != nil {
+		return err
+	}
+	type legacyRow struct {
+		id     int64
+		values [5]float64
+	}
+	pending := make([]legacyRow, 0)
+	for rows.Next() {
+		var row legacyRow
+		if err := rows.Scan(&row.id, &row.values[0], &row.values[1], &row.values[2], &row.values[3], &row.values[4]); err != nil {
+			rows.Close()
+			return err
+		}
+		pending = append(pending, row)
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return err
+	}
+	rows.Close()
+
+	for _, row := range pending {
+		cents := make([]any, 0, len(row.values)+1)
+		for _, v := range row.values {
+			m, err := MoneyFromFloat(v)
+			if err != nil {
+				return fmt.Errorf("payroll record %d: %w", row.id, err)
+			}
+			cents = append(cents, m)
+		}
+		cents = append(cents, row.id)
+		if _, err := tx.Exec(`UPDATE payroll_records SET base_salary_cents = ?, overtime_rate_cents = ?,
+			bonuses_cents = ?, deductions_cents = ?, net_pay_cents = ? WHERE id = ?`, cents...); err != nil {
+			return err
+		}
+	}
+	for _, col := range columns {
+		if _, err := tx.Exec("ALTER TABLE payroll_records DROP COLUMN " + col.legacy); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func migrateUsers(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+		CREATE TABLE users (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			email TEXT NOT NULL UNIQUE,
+			name TEXT NOT NULL,
+			password_hash TEXT NOT NULL,
+			created_at TEXT NOT NULL
+		);
+
+		CREATE TABLE api_tokens (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			name TEXT NOT NULL,
+			token_hash TEXT NOT NULL UNIQUE,
+			created_at TEXT NOT NULL,
+			last_used_at TEXT NOT NULL DEFAULT '',
+			revoked_at TEXT NOT NULL DEFAULT ''
+		);
+		CREATE INDEX idx_api_tokens_user ON api_tokens(user_id);
+	`)
+	return err
+}
+
+// migrateRoles links users to employees and employees to their managers.
+// Accounts created before roles existed had full access, so they become admins.
+func migrateRoles(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+		ALTER TABLE users ADD COLUMN role TEXT NOT NULL DEFAULT 'employee';
+		ALTER TABLE users ADD COLUMN employee_id INTEGER REFERENCES employees(id) ON DELETE SET NULL;
+		UPDATE users SET role = 'admin';
+
+		ALTER TABLE employees ADD COLUMN manager_id INTEGER REFERENCES employees(id) ON DELETE SET NULL;
+		CREATE INDEX idx_employees_manager ON employees(manager_id);
+	`)
+	return err
+}
+
+// migrateAuditLog has no foreign keys on purpose: entries must outlive the
+// users and records they describe.
+func migrateAuditLog(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+		CREATE TABLE audit_log (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			occurred_at TEXT NOT NULL,
+			actor_user_id INTEGER,
+			actor_email TEXT NOT NULL,
+			entity TEXT NOT NULL,
+			entity_id INTEGER NOT NULL,
+			action TEXT NOT NULL,
+			before_json TEXT,
+			after_json TEXT
+		);
+		CREATE INDEX idx_audit_log_entity ON audit_log(entity, entity_id);
+		CREATE INDEX idx_audit_log_actor ON audit_log(actor_user_id);
+	`)
+	return err
+}
+
+func migrateReviewTransitions(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+		CREATE TABLE review_transitions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			review_id INTEGER NOT NULL REFERENCES performance_reviews(id) ON DELETE CASCADE,
+			from_state TEXT NOT NULL,
+			to_state TEXT NOT NULL,
+			actor_user_id INTEGER,
+			actor_email TEXT NOT NULL,
+			occurred_at TEXT NOT NULL,
+			comment TEXT NOT NULL DEFAULT ''
+		);
+		CREATE INDEX idx_review_transitions_review ON review_transitions(review_id);
+	`)
+	return err
+}
+
+func migrateRowVersions(tx *sql.Tx) error {
+	for _, table := range []string{"employees", "performance_reviews", "payroll_records"} {
+		if _, err := tx.Exec(`ALTER TABLE ` + table + ` ADD COLUMN version INTEGER NOT NULL DEFAULT 1`); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migratePeriodUniqueness keeps the newest payslip and review per employee
+// and period. Older active payslips are voided, so totals stop double-counting.
+// Older reviews by the same reviewer move to archived_performance_reviews with
+// their transition history, pointing at the review that superseded them.
+// Both changes are logged.
+func migratePeriodUniqueness(tx *sql.Tx) error {
+	if _, err := tx.Exec(`
+		CREATE TABLE archived_performance_reviews (
+			id INTEGER PRIMARY KEY,
+			employee_id INTEGER NOT NULL,
+			period TEXT NOT NULL,
+			reviewer TEXT NOT NULL,
+			rating INTEGER NOT NULL,
+			strengths TEXT,
+			opportunities TEXT,
+			state TEXT NOT NULL,
+			version INTEGER NOT NULL,
+			superseded_by INTEGER NOT NULL,
+			transitions_json TEXT NOT NULL,
+			archived_at TEXT NOT NULL
+		);
+	`); err != nil {
+		return err
+	}
+	return resolvePeriodDuplicates(tx, "duplicates")
+}
+
+// resolvePeriodDuplicates archives all but the newest review and voids all but
+// the newest active payslip per employee and period, logs what it changed
+// under label, and creates the indexes that keep periods unique. Migrations
+// 11 and 14 both run it.
+func resolvePeriodDuplicates(tx *sql.Tx, label string) error {
+	archived, err := queryIDs(tx, `
+		INSERT INTO archived_performance_reviews(id, employee_id, period, reviewer, rating, strengths, opportunities,
+			state, version, superseded_by, transitions_json, archived_at)
+		SELECT r.id, r.employee_id, r.period, r.reviewer, r.rating, r.strengths, r.opportunities, r.state, r.version,
+			(SELECT MAX(newer.id) FROM performance_reviews newer
+				WHERE newer.employee_id = r.employee_id AND newer.period = r.period
+					AND newer.reviewer = r.reviewer COLLATE NOCASE),
+			(SELECT json_group_array(json_object('from', t.from_state, 'to', t.to_state, 'actor', t.actor_email,
+					'occurredAt', t.occurred_at, 'comment', t.comment))
+				FROM review_transitions t WHERE t.review_id = r.id),
+			strftime('%Y-%m-%dT%H:%M:%SZ', 'now')
+		FROM performance_reviews r
+		WHERE EXISTS (
+			SELECT 1 FROM performance_reviews newer
+			WHERE newer.employee_id = r.employee_id AND newer.period = r.period
+				AND newer.reviewer = r.reviewer COLLATE NOCASE AND newer.id > r.id
+		)
+		RETURNING id`)
+	if err != nil {
+		return err
+	}
+	if len(archived) > 0 {
+		if _, err := tx.Exec(`DELETE FROM performance_reviews WHERE id IN (SELECT id FROM archived_performance_reviews)`); err != nil {
+			return err
+		}
+		log.Printf("migration: moved performance reviews %v (%s) to archived_performance_reviews", archived, label)
+	}
+
+	voided, err := queryIDs(tx, `
+		UPDATE payroll_records
+		SET status = 'voided', void_reason = 'duplicate for period, superseded by a newer record',
+			voided_at = strftime('%Y-%m-%dT%H:%M:%SZ', 'now'), version = version + 1
+		WHERE status = 'active' AND EXISTS (
+			SELECT 1 FROM payroll_records newer
+			WHERE newer.employee_id = payroll_records.employee_id AND newer.period = payroll_records.period
+				AND newer.status = 'active' AND newer.id > payroll_records.id
+		)
+		RETURNING id`)
+	if err != nil {
+		return err
+	}
+	if len(voided) > 0 {
+		log.Printf("migration: voided payslips %v (%s)", voided, label)
+	}
+	_, err = tx.Exec(`
+		CREATE UNIQUE INDEX ux_payroll_active_period ON payroll_records(employee_id, period) WHERE status = 'active';
+		CREATE UNIQUE INDEX ux_reviews_employee_period_reviewer ON performance_reviews(employee_id, period, reviewer COLLATE NOCASE);
+	`)
+	return err
+}
+
+// queryIDs runs a statement returning one id column.
+func queryIDs(tx *sql.Tx, query string, args ...any) ([]int64, error) {
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func migrateIdempotencyKeys(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+		CREATE TABLE idempotency_keys (
+			user_id INTEGER NOT NULL,
+			key TEXT NOT NULL,
+			fingerprint TEXT NOT NULL,
+			status INTEGER NOT NULL,
+			header_json TEXT,
+			body BLOB,
+			created_at TEXT NOT NULL,
+			PRIMARY KEY (user_id, key)
+		);
+		CREATE INDEX idx_idempotency_keys_created ON idempotency_keys(created_at);
+	`)
+	return err
+}
+
+// migrateEmployeeSearch indexes employee names for GET /employees?q=. The
+// unicode61 tokenizer folds case and, with remove_diacritics 2, accents, so
+// "jose" finds "José". Triggers keep the index in step with the table.
+func migrateEmployeeSearch(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+		CREATE VIRTUAL TABLE employees_fts USING fts5(
+			name,
+			content = 'employees',
+			content_rowid = 'id',
+			tokenize = 'unicode61 remove_diacritics 2'
+		);
+		CREATE TRIGGER employees_fts_insert AFTER INSERT ON employees BEGIN
+			INSERT INTO employees_fts(rowid, name) VALUES (new.id, new.name);
+		END;
+		CREATE TRIGGER employees_fts_delete AFTER DELETE ON employees BEGIN
+			INSERT INTO employees_fts(employees_fts, rowid, name) VALUES ('delete', old.id, old.name);
+		END;
+		CREATE TRIGGER employees_fts_update AFTER UPDATE OF name ON employees BEGIN
+			INSERT INTO employees_fts(employees_fts, rowid, name) VALUES ('delete', old.id, old.name);
+			INSERT INTO employees_fts(rowid, name) VALUES (new.id, new.name);
+		END;
+		INSERT INTO employees_fts(employees_fts) VALUES ('rebuild');
+	`)
+	return err
+}
+
+// migrateStructuredPeriods rewrites review and payroll periods in canonical
+// form and stores their first and last month for ordering and range queries.
+// Rows whose period cannot be read keep it as is, without bounds, and are
+// listed in unreadable_periods and the log for someone to fix. Spellings that
+// turn out to name the same period, such as 2024-1 and 2024-01, are then
+// resolved by resolvePeriodDuplicates.
+func migrateStructuredPeriods(tx *sql.Tx) error {
+	if _, err := tx.Exec(`
+		DROP INDEX IF EXISTS ux_payroll_active_period;
+		DROP INDEX IF EXISTS ux_reviews_employee_period_reviewer;
+		CREATE TABLE unreadable_periods (
+			table_name TEXT NOT NULL,
+			row_id INTEGER NOT NULL,
+			period TEXT NOT NULL,
+			found_at TEXT NOT NULL,
+			PRIMARY KEY (table_name, row_id)
+		);
+	`); err != nil {
+		return err
+	}
+	for _, table := range []string{"performance_reviews", "payroll_records"} {
+		for _, col := range []string{"period_start", "period_end"} {
+			if err := addColumnIfMissing(tx, table, col, "TEXT NOT NULL DEFAULT ''"); err != nil {
+				return err
+			}
+		}
+		if err := canonicalizePeriods(tx, table); err != nil {
+			return err
+		}
+	}
+	if err := resolvePeriodDuplicates(tx, "duplicates once periods were canonical"); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`
+		CREATE INDEX idx_reviews_period_range ON performance_reviews(period_start, period_end);
+		CREATE INDEX idx_payroll_period_range ON payroll_records(period_start, period_end);
+	`)
+	return err
+}
+
+func canonicalizePeriods(tx *sql.Tx, table string) error {
+	rows, err := tx.Query(fmt.Sprintf("SELECT id, period FROM %s", table))
+	if err != nil {
+		return err
+	}
+	type row struct {
+		id     int64
+		period string
+	}
+	var all []row
+	for rows.Next() {
+		var r row
+		if err := rows.Scan(&r.id, &r.period); err != nil {
+			rows.Close()
+			return err
+		}
+		all = append(all, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	var unreadable []string
+	for _, r := range all {
+		period, err := ParsePeriod(r.period)
+		if err != nil {
+			if _, err := tx.Exec(`INSERT INTO unreadable_periods(table_name, row_id, period, found_at)
+				VALUES (?, ?, ?, strftime('%Y-%m-%dT%H:%M:%SZ', 'now'))`, table, r.id, r.period); err != nil {
+				return err
+			}
+			unreadable = append(unreadable, fmt.Sprintf("%d (%q)", r.id, r.period))
+			continue
+		}
+		start, end := period.bounds()
+		if _, err := tx.Exec(fmt.Sprintf("UPDATE %s SET period = ?, period_start = ?, period_end = ? WHERE id = ?", table),
+			period, start, end, r.id); err != nil {
+			return err
+		}
+	}
+	if len(unreadable) > 0 {
+		log.Printf("migration: %s %s have periods that are not a month, quarter, half-year or year; they are listed in unreadable_periods and left out of period filters until fixed",
+			table, strings.Join(unreadable, ", "))
+	}
+	return nil
+}
+
+// migratePayrollLineItems moves each record's single bonus and deduction
+// amounts into one generic line item each, matching genericLineItems. The
+// amount columns stay as the totals of the lines; they are recomputed so a
+// negative bonus, now a deduction line, is counted on the right side.
+func migratePayrollLineItems(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+		CREATE TABLE payroll_line_items (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			payroll_id INTEGER NOT NULL REFERENCES payroll_records(id) ON DELETE CASCADE,
+			position INTEGER NOT NULL,
+			type TEXT NOT NULL CHECK (type IN ('earning', 'deduction')),
+			code TEXT NOT NULL,
+			description TEXT NOT NULL DEFAULT '',
+			amount_cents INTEGER NOT NULL CHECK (amount_cents >= 0)
+		);
+		CREATE INDEX idx_payroll_line_items_payroll ON payroll_line_items(payroll_id, position);
+
+		INSERT INTO payroll_line_items(payroll_id, position, type, code, description, amount_cents)
+		SELECT id, 1, CASE WHEN bonuses_cents > 0 THEN 'earning' ELSE 'deduction' END, 'BONUS', 'Bonuses', ABS(bonuses_cents)
+		FROM payroll_records WHERE bonuses_cents != 0;
+
+		INSERT INTO payroll_line_items(payroll_id, position, type, code, description, amount_cents)
+		SELECT id, 2, CASE WHEN deductions_cents > 0 THEN 'deduction' ELSE 'earning' END, 'DEDUCTION', 'Deductions', ABS(deductions_cents)
+		FROM payroll_records WHERE deductions_cents != 0;
+
+		UPDATE payroll_records SET
+			bonuses_cents = (SELECT COALESCE(SUM(amount_cents), 0) FROM payroll_line_items
+				WHERE payroll_id = payroll_records.id AND type = 'earning'),
+			deductions_cents = (SELECT COALESCE(SUM(amount_cents), 0) FROM payroll_line_items
+				WHERE payroll_id = payroll_records.id AND type = 'deduction')
+		WHERE bonuses_cents < 0 OR deductions_cents < 0;
+	`)
+	return err
+}
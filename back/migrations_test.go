@@ -0,0 +1,462 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestMigrateUpgradesLegacyEmployeesTable(t *testing.T) {
+	store, err := NewStore(":memory:")
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	defer store.Close()
+	if _, err := store.db.Exec(`CREATE TABLE employees (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT NOT NULL);
+		INSERT INTO employees(name) VALUES ('Legacy');`); err != nil {
+		t.Fatalf("seed legacy schema: %v", err)
+	}
+	if err := store.Migrate(); err != nil {
+		t.Fatalf("migrate store: %v", err)
+	}
+	list, err := store.ListEmployees(EmployeeFilter{})
+	if err != nil {
+		t.Fatalf("list employees: %v", err)
+	}
+	if len(list) != 1 || list[0].Name != "Legacy" || list[0].Status != EmploymentStatusActive {
+		t.Fatalf("unexpected employees after upgrade: %+v", list)
+	}
+}
+
+func TestMigrateConvertsLegacyMoneyColumns(t *testing.T) {
+	store, err := NewStore(":memory:")
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	defer store.Close()
+	if _, err := store.db.Exec(`
+		CREATE TABLE employees (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT NOT NULL);
+		CREATE TABLE payroll_records (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			employee_id INTEGER NOT NULL,
+			period TEXT NOT NULL,
+			base_salary REAL NOT NULL,
+			overtime_hours REAL NOT NULL DEFAULT 0,
+			overtime_rate REAL NOT NULL DEFAULT 0,
+			bonuses REAL NOT NULL DEFAULT 0,
+			deductions REAL NOT NULL DEFAULT 0,
+			net_pay REAL NOT NULL
+		);
+		INSERT INTO employees(name) VALUES ('Legacy');
+		INSERT INTO payroll_records(employee_id, period, base_salary, overtime_hours, overtime_rate, bonuses, deductions, net_pay)
+			VALUES (1, '2024-11', 1000.1, 2, 0.285, 0.2, 0.1, 1000.77);`); err != nil {
+		t.Fatalf("seed legacy schema: %v", err)
+	}
+	if err := store.Migrate(); err != nil {
+		t.Fatalf("migrate store: %v", err)
+	}
+	rec, err := store.GetPayrollRecord(1)
+	if err != nil {
+		t.Fatalf("get payroll: %v", err)
+	}
+	if rec.BaseSalary != 100010 || rec.OvertimeRate != 29 || rec.Bonuses != 20 || rec.Deductions != 10 || rec.NetPay != 100077 {
+		t.Fatalf("unexpected migrated record: %+v", rec)
+	}
+	var legacy int
+	err = store.db.QueryRow("SELECT COUNT(*) FROM pragma_table_info('payroll_records') WHERE name = 'base_salary'").Scan(&legacy)
+	if err != nil || legacy != 0 {
+		t.Fatalf("expected legacy REAL columns dropped, got %v %v", legacy, err)
+	}
+	// Running the migrations again must be a no-op.
+	if err := store.Migrate(); err != nil {
+		t.Fatalf("re-migrate store: %v", err)
+	}
+}
+
+func TestMigrateFreshDatabase(t *testing.T) {
+	store := newMemoryStore(t)
+	defer store.Close()
+
+	version, err := store.SchemaVersion()
+	if err != nil {
+		t.Fatalf("schema version: %v", err)
+	}
+	if version != latestVersion(migrations) {
+		t.Fatalf("expected version %d, got %d", latestVersion(migrations), version)
+	}
+	if err := store.Migrate(); err != nil {
+		t.Fatalf("second migrate should be a no-op: %v", err)
+	}
+}
+
+// copyBaselineDB copies the committed baseline database so tests never touch the original.
+func copyBaselineDB(t *testing.T) string {
+	t.Helper()
+	src, err := os.Open(filepath.Join("testdata", "employees_baseline.sqlite"))
+	if err != nil {
+		t.Fatalf("open baseline: %v", err)
+	}
+	defer src.Close()
+	path := filepath.Join(t.TempDir(), "employees.db")
+	dst, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("create copy: %v", err)
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		t.Fatalf("copy baseline: %v", err)
+	}
+	if err := dst.Close(); err != nil {
+		t.Fatalf("close copy: %v", err)
+	}
+	return path
+}
+
+func TestMigrateBaselineDatabase(t *testing.T) {
+	store, err := NewStore(copyBaselineDB(t))
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	defer store.Close()
+
+	counts := func() [3]int {
+		var c [3]int
+		for i, table := range []string{"employees", "performance_reviews", "payroll_records"} {
+			if err := store.db.QueryRow("SELECT COUNT(*) FROM " + table).Scan(&c[i]); err != nil {
+				t.Fatalf("count %s: %v", table, err)
+			}
+		}
+		return c
+	}
+	before := counts()
+
+	if err := store.Migrate(); err != nil {
+		t.Fatalf("migrate baseline: %v", err)
+	}
+	if after := counts(); after != before {
+		t.Fatalf("row counts changed during migration: before %v after %v", before, after)
+	}
+	version, err := store.SchemaVersion()
+	if err != nil || version != latestVersion(migrations) {
+		t.Fatalf("expected latest version, got %d %v", version, err)
+	}
+
+	records, err := store.ListPayrollRecords(PayrollFilter{})
+	if err != nil {
+		t.Fatalf("list payroll: %v", err)
+	}
+	for _, rec := range records {
+		if rec.Status != PayrollStatusActive || rec.BaseSalary <= 0 {
+			t.Fatalf("unexpected migrated payroll record: %+v", rec)
+		}
+	}
+	if _, err := store.CreateEmployee(EmployeeInput{Name: "After Migration", Email: "after@example.com"}); err != nil {
+		t.Fatalf("create employee on migrated db: %v", err)
+	}
+}
+
+func TestMigrateRollsBackFailedMigration(t *testing.T) {
+	store := newMemoryStore(t)
+	defer store.Close()
+
+	current := latestVersion(migrations)
+	broken := append(append([]migration{}, migrations...), migration{
+		version: current + 1,
+		name:    "broken",
+		up: func(tx *sql.Tx) error {
+			if _, err := tx.Exec("CREATE TABLE half_done (id INTEGER)"); err != nil {
+				return err
+			}
+			return errors.New("boom")
+		},
+	})
+	if err := store.migrate(broken); err == nil {
+		t.Fatalf("expected migration error")
+	}
+	version, err := store.SchemaVersion()
+	if err != nil || version != current {
+		t.Fatalf("expected version to stay at %d, got %d %v", current, version, err)
+	}
+	var tables int
+	if err := store.db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name = 'half_done'").Scan(&tables); err != nil {
+		t.Fatalf("inspect schema: %v", err)
+	}
+	if tables != 0 {
+		t.Fatalf("expected partial migration to be rolled back")
+	}
+}
+
+func TestMigrateRejectsNewerDatabase(t *testing.T) {
+	store := newMemoryStore(t)
+	defer store.Close()
+
+	if _, err := store.db.Exec("INSERT INTO schema_version(version, name, applied_at) VALUES(999, 'future', '')"); err != nil {
+		t.Fatalf("seed version: %v", err)
+	}
+	if err := store.Migrate(); err == nil {
+		t.Fatalf("expected error for a database newer than the build")
+	}
+}
+
+func TestMigrateExistingUsersBecomeAdmins(t *testing.T) {
+	store, err := NewStore(":memory:")
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	defer store.Close()
+	if err := store.migrate(migrations[:6]); err != nil {
+		t.Fatalf("migrate to v6: %v", err)
+	}
+	if _, err := store.db.Exec(`INSERT INTO users(email, name, password_hash, created_at)
+		VALUES ('old@example.com', 'Old', 'x', '2024-01-01T00:00:00Z')`); err != nil {
+		t.Fatalf("seed user: %v", err)
+	}
+	if err := store.Migrate(); err != nil {
+		t.Fatalf("migrate store: %v", err)
+	}
+	user, err := store.GetUser(1)
+	if err != nil {
+		t.Fatalf("get user: %v", err)
+	}
+	if user.Role != RoleAdmin {
+		t.Fatalf("expected pre-existing user to be admin, got %q", user.Role)
+	}
+}
+
+func TestMigratePeriodUniqueness(t *testing.T) {
+	store, err := NewStore(":memory:")
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	defer store.Close()
+	if err := store.migrate(migrations[:10]); err != nil {
+		t.Fatalf("migrate to v10: %v", err)
+	}
+	emp := mustCreateEmployee(t, store, "Alice")
+	for i := 0; i < 3; i++ {
+		if _, err := store.db.Exec(`INSERT INTO payroll_records(employee_id, period, base_salary_cents, net_pay_cents, status, created_at)
+			VALUES (?, '2024-11', 1000, 1000, 'active', '2024-11-30T00:00:00Z')`, emp.ID); err != nil {
+			t.Fatalf("seed payroll: %v", err)
+		}
+	}
+	if err := store.Migrate(); err != nil {
+		t.Fatalf("migrate store: %v", err)
+	}
+	_, grand, err := store.PayrollTotals(PayrollFilter{EmployeeIDs: []int64{emp.ID}})
+	if err != nil {
+		t.Fatalf("totals: %v", err)
+	}
+	if grand != 1000 {
+		t.Fatalf("expected duplicates to stop counting, got %d", grand)
+	}
+	kept, err := store.GetPayrollRecord(3)
+	if err != nil {
+		t.Fatalf("get payroll: %v", err)
+	}
+	if kept.Status != PayrollStatusActive {
+		t.Fatalf("expected the newest payslip to stay active, got %+v", kept)
+	}
+}
+
+func TestMigratePeriodUniquenessArchivesDuplicateReviews(t *testing.T) {
+	store, err := NewStore(":memory:")
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	defer store.Close()
+	if err := store.migrate(migrations[:10]); err != nil {
+		t.Fatalf("migrate to v10: %v", err)
+	}
+	emp := mustCreateEmployee(t, store, "Alice")
+	for _, reviewer := range []string{"Boss", "boss"} {
+		if _, err := store.db.Exec(`INSERT INTO performance_reviews(employee_id, period, reviewer, rating, strengths, opportunities, state)
+			VALUES (?, '2024-Q4', ?, 3, '', '', 'draft')`, emp.ID, reviewer); err != nil {
+			t.Fatalf("seed review: %v", err)
+		}
+	}
+	if _, err := store.db.Exec(`INSERT INTO review_transitions(review_id, from_state, to_state, actor_email, occurred_at)
+		VALUES (1, 'draft', 'submitted', 'boss@example.com', '2024-12-01T00:00:00Z')`); err != nil {
+		t.Fatalf("seed transition: %v", err)
+	}
+	if err := store.Migrate(); err != nil {
+		t.Fatalf("expected duplicate reviews not to stop the migration, got %v", err)
+	}
+
+	if _, err := store.GetPerformanceReview(1); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected the older review to be moved out, got %v", err)
+	}
+	if _, err := store.GetPerformanceReview(2); err != nil {
+		t.Fatalf("expected the newest review to stay, got %v", err)
+	}
+	var supersededBy int64
+	var transitions string
+	if err := store.db.QueryRow("SELECT superseded_by, transitions_json FROM archived_performance_reviews WHERE id = 1").Scan(&supersededBy, &transitions); err != nil {
+		t.Fatalf("read archive: %v", err)
+	}
+	if supersededBy != 2 || !strings.Contains(transitions, `"to":"submitted"`) {
+		t.Fatalf("expected review 1 archived with its history and pointing at 2, got %d %s", supersededBy, transitions)
+	}
+}
+
+func TestMigrateEmployeeSearchIndexesExistingRows(t *testing.T) {
+	store, err := NewStore(":memory:")
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	defer store.Close()
+	if err := store.migrate(migrations[:12]); err != nil {
+		t.Fatalf("migrate to v12: %v", err)
+	}
+	mustCreateEmployee(t, store, "Íñigo Álvarez")
+	if err := store.Migrate(); err != nil {
+		t.Fatalf("migrate store: %v", err)
+	}
+	list, err := store.ListEmployees(EmployeeFilter{Search: "inigo alv"})
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if len(list) != 1 {
+		t.Fatalf("expected the existing employee to be indexed, got %+v", list)
+	}
+}
+
+func TestMigrateStructuredPeriods(t *testing.T) {
+	store, err := NewStore(":memory:")
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	defer store.Close()
+	if err := store.migrate(migrations[:13]); err != nil {
+		t.Fatalf("migrate to v13: %v", err)
+	}
+	emp := mustCreateEmployee(t, store, "Alice")
+	for _, period := range []string{"2024-1", "Ene 2024", "2024-02", "2023-12"} {
+		if _, err := store.db.Exec(`INSERT INTO payroll_records(employee_id, period, base_salary_cents, net_pay_cents, status, created_at)
+			VALUES (?, ?, 1000, 1000, 'active', '2024-01-31T00:00:00Z')`, emp.ID, period); err != nil {
+			t.Fatalf("seed payroll %q: %v", period, err)
+		}
+	}
+	for _, period := range []string{"q4 2024", "2024-Q4"} {
+		if _, err := store.db.Exec(`INSERT INTO performance_reviews(employee_id, period, reviewer, rating, strengths, opportunities, state)
+			VALUES (?, ?, 'Boss', 3, '', '', 'draft')`, emp.ID, period); err != nil {
+			t.Fatalf("seed review %q: %v", period, err)
+		}
+	}
+	if err := store.Migrate(); err != nil {
+		t.Fatalf("migrate store: %v", err)
+	}
+
+	records, err := store.ListPayrollRecords(PayrollFilter{})
+	if err != nil {
+		t.Fatalf("list payroll: %v", err)
+	}
+	var got []Period
+	for _, rec := range records {
+		got = append(got, rec.Period)
+	}
+	if want := []Period{"2024-02", "2024-01", "2023-12"}; !slices.Equal(got, want) {
+		t.Fatalf("expected canonical periods newest first with the duplicate voided, got %v", got)
+	}
+	review, err := store.GetPerformanceReview(2)
+	if err != nil {
+		t.Fatalf("get review: %v", err)
+	}
+	if review.Period != "2024-Q4" {
+		t.Fatalf("expected the review period to be rewritten, got %q", review.Period)
+	}
+	var archived int64
+	if err := store.db.QueryRow("SELECT superseded_by FROM archived_performance_reviews WHERE id = 1").Scan(&archived); err != nil || archived != 2 {
+		t.Fatalf("expected the other spelling to be archived in favour of review 2, got %d %v", archived, err)
+	}
+}
+
+func TestMigrateStructuredPeriodsQuarantinesUnreadablePeriods(t *testing.T) {
+	store, err := NewStore(":memory:")
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	defer store.Close()
+	if err := store.migrate(migrations[:13]); err != nil {
+		t.Fatalf("migrate to v13: %v", err)
+	}
+	emp := mustCreateEmployee(t, store, "Alice")
+	for _, period := range []string{"fin de año", "2024-12"} {
+		if _, err := store.db.Exec(`INSERT INTO payroll_records(employee_id, period, base_salary_cents, net_pay_cents, status, created_at)
+			VALUES (?, ?, 1000, 1000, 'active', '2024-12-31T00:00:00Z')`, emp.ID, period); err != nil {
+			t.Fatalf("seed payroll %q: %v", period, err)
+		}
+	}
+	if err := store.Migrate(); err != nil {
+		t.Fatalf("expected unreadable periods not to stop the migration, got %v", err)
+	}
+
+	var table, period string
+	var rowID int64
+	if err := store.db.QueryRow("SELECT table_name, row_id, period FROM unreadable_periods").Scan(&table, &rowID, &period); err != nil {
+		t.Fatalf("read quarantine: %v", err)
+	}
+	if table != "payroll_records" || rowID != 1 || period != "fin de año" {
+		t.Fatalf("expected payslip 1 to be listed, got %s %d %q", table, rowID, period)
+	}
+	kept, err := store.GetPayrollRecord(1)
+	if err != nil || kept.Period != "fin de año" {
+		t.Fatalf("expected the payslip to keep its period, got %+v %v", kept, err)
+	}
+	year := Period("2024")
+	filtered, err := store.ListPayrollRecords(PayrollFilter{Period: Range[Period]{Max: &year}})
+	if err != nil {
+		t.Fatalf("list payroll: %v", err)
+	}
+	if len(filtered) != 1 || filtered[0].ID != 2 {
+		t.Fatalf("expected only the readable payslip to match a period filter, got %+v", filtered)
+	}
+}
+
+func TestMigratePayrollLineItems(t *testing.T) {
+	store, err := NewStore(":memory:")
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	defer store.Close()
+	if err := store.migrate(migrations[:14]); err != nil {
+		t.Fatalf("migrate to v14: %v", err)
+	}
+	emp := mustCreateEmployee(t, store, "Alice")
+	for _, amounts := range [][2]int64{{2000, 500}, {0, 0}, {-300, 0}} {
+		if _, err := store.db.Exec(`INSERT INTO payroll_records(employee_id, period, period_start, period_end, base_salary_cents, bonuses_cents, deductions_cents, net_pay_cents, status, created_at)
+			VALUES (?, '2024-11', '2024-11', '2024-11', 10000, ?, ?, 0, 'voided', '2024-11-30T00:00:00Z')`, emp.ID, amounts[0], amounts[1]); err != nil {
+			t.Fatalf("seed payroll: %v", err)
+		}
+	}
+	if err := store.Migrate(); err != nil {
+		t.Fatalf("migrate store: %v", err)
+	}
+
+	want := map[int64][]PayrollLineItem{
+		1: {
+			{Type: PayrollLineEarning, Code: genericBonusCode, Description: "Bonuses", Amount: 2000},
+			{Type: PayrollLineDeduction, Code: genericDeductionCode, Description: "Deductions", Amount: 500},
+		},
+		2: {},
+		3: {{Type: PayrollLineDeduction, Code: genericBonusCode, Description: "Bonuses", Amount: 300}},
+	}
+	for id, lines := range want {
+		rec, err := store.GetPayrollRecord(id)
+		if err != nil {
+			t.Fatalf("get payroll %d: %v", id, err)
+		}
+		if !reflect.DeepEqual(rec.LineItems, lines) {
+			t.Errorf("record %d: expected %+v, got %+v", id, lines, rec.LineItems)
+		}
+		earnings, deductions := lineItemTotals(rec.LineItems)
+		if rec.Bonuses != earnings || rec.Deductions != deductions {
+			t.Errorf("record %d: expected totals to match the lines, got %+v", id, rec)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Money is an exact monetary amount expressed in cents.
+//
+// Rounding rule: whenever a value carries more precision than a cent (inputs
+// with more than two decimals, overtime hours multiplied by a rate, legacy REAL
+// values) it is rounded once, half away from zero, to the nearest cent.
+//
+// On the wire Money stays a plain JSON number with two decimals, so existing
+// clients keep sending and receiving numbers such as 1250.5.
+type Money int64
+
+// maxMoney bounds accepted amounts so sums of many records cannot overflow int64.
+const maxMoney Money = 100_000_000_000_000 // one trillion, in cents
+
+// ParseMoney parses a decimal string such as "1250.50", "-3" or "1.5e3".
+func ParseMoney(s string) (Money, error) {
+	s = strings.TrimSpace(s)
+	// big.Rat also accepts fractions like "1/3", which are not amounts.
+	if s == "" || strings.Contains(s, "/") {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	return roundToCents(r.Mul(r, big.NewRat(100, 1)))
+}
+
+// MoneyFromFloat converts a float using its shortest decimal representation, so
+// 0.285 becomes 29 cents rather than the 28 that naive float math would give.
+func MoneyFromFloat(f float64) (Money, error) {
+	return ParseMoney(strconv.FormatFloat(f, 'g', -1, 64))
+}
+
+// MulQuantity multiplies the amount by a quantity such as hours worked.
+func (m Money) MulQuantity(q float64) Money {
+	qty, ok := new(big.Rat).SetString(strconv.FormatFloat(q, 'g', -1, 64))
+	if !ok {
+		return 0
+	}
+	product := qty.Mul(qty, new(big.Rat).SetInt64(int64(m)))
+	res, err := roundToCents(product)
+	if err != nil {
+		return 0
+	}
+	return res
+}
+
+// roundToCents rounds a value already expressed in cents half away from zero.
+func roundToCents(cents *big.Rat) (Money, error) {
+	num, den := cents.Num(), cents.Denom()
+	quo, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+	if rem.Abs(rem).Lsh(rem, 1).Cmp(den) >= 0 {
+		if num.Sign() < 0 {
+			quo.Sub(quo, big.NewInt(1))
+		} else {
+			quo.Add(quo, big.NewInt(1))
+		}
+	}
+	if !quo.IsInt64() {
+		return 0, fmt.Errorf("amount out of range")
+	}
+	return Money(quo.Int64()), nil
+}
+
+func (m Money) String() string {
+	sign := ""
+	abs := uint64(m)
+	if m < 0 {
+		sign = "-"
+		abs = uint64(-m)
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, abs/100, abs%100)
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON accepts JSON numbers and, for clients that want to avoid
+// floating point entirely, decimal strings.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	raw := string(data)
+	if strings.HasPrefix(raw, `"`) {
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return err
+		}
+	}
+	parsed, err := ParseMoney(raw)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	cases := []struct {
+		in   string
+		want Money
+	}{
+		{"0", 0},
+		{"1250.5", 125050},
+		{"-3", -300},
+		{"0.285", 29},
+		{"0.284", 28},
+		{"-0.005", -1},
+		{"1.5e3", 150000},
+		{" 10.10 ", 1010},
+	}
+	for _, tc := range cases {
+		got, err := ParseMoney(tc.in)
+		if err != nil {
+			t.Fatalf("parse %q: %v", tc.in, err)
+		}
+		if got != tc.want {
+			t.Fatalf("parse %q: expected %d, got %d", tc.in, tc.want, got)
+		}
+	}
+
+	for _, bad := range []string{"", "abc", "1/3", "1e40"} {
+		if _, err := ParseMoney(bad); err == nil {
+			t.Fatalf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestMoneyFromFloat(t *testing.T) {
+	got, err := MoneyFromFloat(0.285)
+	if err != nil || got != 29 {
+		t.Fatalf("expected 29 cents, got %d %v", got, err)
+	}
+}
+
+func TestMoneyMulQuantity(t *testing.T) {
+	rate := Money(3333)
+	if got := rate.MulQuantity(1.5); got != 5000 {
+		t.Fatalf("expected half-cent to round up to 5000, got %d", got)
+	}
+	if got := rate.MulQuantity(0.1); got != 333 {
+		t.Fatalf("expected 333, got %d", got)
+	}
+}
+
+func TestMoneyJSON(t *testing.T) {
+	b, err := json.Marshal(struct {
+		A Money `json:"a"`
+		B Money `json:"b"`
+	}{A: 125050, B: -5})
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if string(b) != `{"a":1250.50,"b":-0.05}` {
+		t.Fatalf("unexpected json %s", b)
+	}
+
+	var in struct {
+		A Money `json:"a"`
+		B Money `json:"b"`
+		C Money `json:"c"`
+	}
+	if err := json.Unmarshal([]byte(`{"a":0.1,"b":"19.99","c":null}`), &in); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if in.A != 10 || in.B != 1999 || in.C != 0 {
+		t.Fatalf("unexpected values %+v", in)
+	}
+	if err := json.Unmarshal([]byte(`{"a":true}`), &in); err == nil {
+		t.Fatalf("expected error for non-numeric amount")
+	}
+}
+
+func TestCalculateNetPayIsExact(t *testing.T) {
+	var total Money
+	for i := 0; i < 1000; i++ {
+		total += calculateNetPay(10, 0, 0, genericLineItems(20, 0))
+	}
+	if total != 30000 {
+		t.Fatalf("expected exactly 300.00, got %s", total)
+	}
+	if got := calculateNetPay(100000, 10, 5000, genericLineItems(20000, 10000)); got != 160000 {
+		t.Fatalf("expected 1600.00, got %s", got)
+	}
+}
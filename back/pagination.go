@@ -0,0 +1,260 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 200
+)
+
+// PageRequest asks for one page of a list. The zero value is the first page
+// in the list's default order. All returns every row as a single page, for
+// lists that were unpaged before and whose clients do not follow links.
+type PageRequest struct {
+	Limit  int
+	Cursor string
+	Sort   string
+	All    bool
+}
+
+// PageInfo describes where a page sits in the whole list. Total counts every
+// row matching the filter, not just the rows left after the cursor.
+type PageInfo struct {
+	Total      int64  `json:"total"`
+	Limit      int    `json:"limit"`
+	NextCursor string `json:"nextCursor,omitempty"`
+	PrevCursor string `json:"prevCursor,omitempty"`
+}
+
+type Page[T any] struct {
+	Items []T
+	PageInfo
+}
+
+// sortKey is one whitelisted order. column is trusted SQL and never comes
+// from the request; value reads the same column back from a row so a cursor
+// can resume right after it.
+type sortKey[T any] struct {
+	column column
+	desc   bool
+	value  func(T) any
+}
+
+// pageQuery is a list query that can be cut into pages. where and args come
+// from a where builder.
+type pageQuery[T any] struct {
+	selectSQL   string
+	where       string
+	args        []any
+	idColumn    column
+	sorts       map[string]sortKey[T]
+	defaultSort string
+	id          func(T) int64
+	scan        func(rowScanner) (T, error)
+}
+
+// pageCursor is where a page ends. Rows are ordered by the sort column and
+// then by id, so the pair is unique even when sort values repeat.
+type pageCursor struct {
+	Sort     string `json:"s"`
+	Value    any    `json:"v"`
+	ID       int64  `json:"id"`
+	Backward bool   `json:"b,omitempty"`
+}
+
+func encodeCursor(c pageCursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s string) (pageCursor, error) {
+	var c pageCursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, err
+	}
+	dec := json.NewDecoder(strings.NewReader(string(b)))
+	dec.UseNumber()
+	if err := dec.Decode(&c); err != nil {
+		return c, err
+	}
+	// Sort values are either text or integers; json.Number would bind as text.
+	if n, ok := c.Value.(json.Number); ok {
+		if c.Value, err = n.Int64(); err != nil {
+			return c, err
+		}
+	}
+	return c, nil
+}
+
+// queryPage runs q for the page req asks for. It uses keyset pagination, so
+// pages stay stable while rows are added and deep pages cost the same as the
+// first one.
+func queryPage[T any](db dbtx, q pageQuery[T], req PageRequest) (Page[T], error) {
+	limit := req.Limit
+	if limit <= 0 {
+		limit = defaultPageLimit
+	}
+	limit = min(limit, maxPageLimit)
+	sortName := req.Sort
+	if sortName == "" {
+		sortName = q.defaultSort
+	}
+	key, ok := q.sorts[sortName]
+	if !ok {
+		names := make([]string, 0, len(q.sorts))
+		for name := range q.sorts {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		return Page[T]{}, fieldError("sort", FieldInvalidChoice, fmt.Sprintf("sort must be one of %s", strings.Join(names, ", ")))
+	}
+	var cur *pageCursor
+	if req.Cursor != "" {
+		c, err := decodeCursor(req.Cursor)
+		if err != nil || c.Sort != sortName {
+			return Page[T]{}, fieldError("cursor", FieldInvalidFormat, "cursor is invalid or belongs to a different sort")
+		}
+		cur = &c
+	}
+
+	page := Page[T]{Items: make([]T, 0)}
+	if err := db.QueryRow("SELECT COUNT(*) FROM ("+q.selectSQL+q.where+")", q.args...).Scan(&page.Total); err != nil {
+		return Page[T]{}, err
+	}
+	if req.All {
+		limit = int(page.Total)
+	}
+	page.Limit = limit
+
+	where, args := q.where, append([]any{}, q.args...)
+	// Walking backwards flips the order; the page is reversed again below.
+	desc := key.desc
+	if cur != nil {
+		if cur.Backward {
+			desc = !desc
+		}
+		op := ">"
+		if desc {
+			op = "<"
+		}
+		keyset := fmt.Sprintf("(%[1]s %[2]s ? OR (%[1]s = ? AND %[3]s %[2]s ?))", key.column, op, q.idColumn)
+		if where == "" {
+			where = " WHERE " + keyset
+		} else {
+			where += " AND " + keyset
+		}
+		args = append(args, cur.Value, cur.Value, cur.ID)
+	}
+	dir := "ASC"
+	if desc {
+		dir = "DESC"
+	}
+	query := fmt.Sprintf("%s%s ORDER BY %s %s, %s %s LIMIT ?", q.selectSQL, where, key.column, dir, q.idColumn, dir)
+	rows, err := db.Query(query, append(args, limit+1)...)
+	if err != nil {
+		return Page[T]{}, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		item, err := q.scan(rows)
+		if err != nil {
+			return Page[T]{}, err
+		}
+		page.Items = append(page.Items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return Page[T]{}, err
+	}
+
+	// One extra row was fetched to learn whether anything lies past this page.
+	more := len(page.Items) > limit
+	if more {
+		page.Items = page.Items[:limit]
+	}
+	backward := cur != nil && cur.Backward
+	if backward {
+		slices.Reverse(page.Items)
+	}
+	if len(page.Items) == 0 {
+		return page, nil
+	}
+	hasNext, hasPrev := more, cur != nil
+	if backward {
+		hasNext, hasPrev = true, more
+	}
+	if hasNext {
+		last := page.Items[len(page.Items)-1]
+		page.NextCursor = encodeCursor(pageCursor{Sort: sortName, Value: key.value(last), ID: q.id(last)})
+	}
+	if hasPrev {
+		first := page.Items[0]
+		page.PrevCursor = encodeCursor(pageCursor{Sort: sortName, Value: key.value(first), ID: q.id(first), Backward: true})
+	}
+	return page, nil
+}
+
+// parsePageRequest reads limit, cursor and sort from the query string.
+// Without limit or cursor the whole list is asked for, as before paging,
+// since clients that never sent either would not notice a cut-off.
+func parsePageRequest(q url.Values) (PageRequest, error) {
+	req := PageRequest{Cursor: q.Get("cursor"), Sort: q.Get("sort")}
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxPageLimit {
+			return req, fieldError("limit", FieldOutOfRange, fmt.Sprintf("limit must be between 1 and %d", maxPageLimit))
+		}
+		req.Limit = limit
+	}
+	req.All = req.Limit == 0 && req.Cursor == ""
+	return req, nil
+}
+
+// pageLinks is PageInfo plus ready-made links to the neighbouring pages.
+type pageLinks struct {
+	PageInfo
+	Next string `json:"next,omitempty"`
+	Prev string `json:"prev,omitempty"`
+}
+
+// writePageHeaders advertises the page through X-Total-Count and an RFC 8288
+// Link header, which also serves list responses that are bare arrays, and
+// returns the same links for list bodies.
+func writePageHeaders(w http.ResponseWriter, r *http.Request, info PageInfo) pageLinks {
+	links := pageLinks{PageInfo: info}
+	link := func(cursor string) string {
+		// The raw terms are kept as sent, since filters such as period>=2024-01
+		// do not survive a round trip through url.Values.
+		terms := []string{}
+		for _, term := range strings.Split(r.URL.RawQuery, "&") {
+			if term != "" && !strings.HasPrefix(term, "cursor=") && !strings.HasPrefix(term, "limit=") {
+				terms = append(terms, term)
+			}
+		}
+		terms = append(terms, "cursor="+url.QueryEscape(cursor), "limit="+strconv.Itoa(info.Limit))
+		return r.URL.Path + "?" + strings.Join(terms, "&")
+	}
+	var header []string
+	if info.NextCursor != "" {
+		links.Next = link(info.NextCursor)
+		header = append(header, fmt.Sprintf(`<%s>; rel="next"`, links.Next))
+	}
+	if info.PrevCursor != "" {
+		links.Prev = link(info.PrevCursor)
+		header = append(header, fmt.Sprintf(`<%s>; rel="prev"`, links.Prev))
+	}
+	if len(header) > 0 {
+		w.Header().Set("Link", strings.Join(header, ", "))
+	}
+	w.Header().Set("X-Total-Count", strconv.FormatInt(info.Total, 10))
+	return links
+}
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestListEmployeesPage_WalksForwardAndBack(t *testing.T) {
+	store := newMemoryStore(t)
+	defer store.Close()
+	for _, name := range []string{"Eve", "Bob", "Dan", "Alice", "Carl"} {
+		mustCreateEmployee(t, store, name)
+	}
+
+	names := func(page Page[Employee]) string {
+		out := make([]string, 0, len(page.Items))
+		for _, e := range page.Items {
+			out = append(out, e.Name)
+		}
+		return strings.Join(out, ",")
+	}
+
+	first, err := store.ListEmployeesPage(EmployeeFilter{}, PageRequest{Limit: 2, Sort: "name"})
+	if err != nil {
+		t.Fatalf("first page: %v", err)
+	}
+	if names(first) != "Alice,Bob" || first.Total != 5 || first.PrevCursor != "" || first.NextCursor == "" {
+		t.Fatalf("unexpected first page: %s %+v", names(first), first.PageInfo)
+	}
+	second, err := store.ListEmployeesPage(EmployeeFilter{}, PageRequest{Limit: 2, Sort: "name", Cursor: first.NextCursor})
+	if err != nil {
+		t.Fatalf("second page: %v", err)
+	}
+	if names(second) != "Carl,Dan" || second.PrevCursor == "" || second.NextCursor == "" {
+		t.Fatalf("unexpected second page: %s %+v", names(second), second.PageInfo)
+	}
+	last, err := store.ListEmployeesPage(EmployeeFilter{}, PageRequest{Limit: 2, Sort: "name", Cursor: second.NextCursor})
+	if err != nil {
+		t.Fatalf("last page: %v", err)
+	}
+	if names(last) != "Eve" || last.NextCursor != "" {
+		t.Fatalf("unexpected last page: %s %+v", names(last), last.PageInfo)
+	}
+	back, err := store.ListEmployeesPage(EmployeeFilter{}, PageRequest{Limit: 2, Sort: "name", Cursor: last.PrevCursor})
+	if err != nil {
+		t.Fatalf("previous page: %v", err)
+	}
+	if names(back) != "Carl,Dan" || back.NextCursor == "" || back.PrevCursor == "" {
+		t.Fatalf("unexpected previous page: %s %+v", names(back), back.PageInfo)
+	}
+	start, err := store.ListEmployeesPage(EmployeeFilter{}, PageRequest{Limit: 2, Sort: "name", Cursor: back.PrevCursor})
+	if err != nil {
+		t.Fatalf("first page again: %v", err)
+	}
+	if names(start) != "Alice,Bob" || start.PrevCursor != "" {
+		t.Fatalf("unexpected first page again: %s %+v", names(start), start.PageInfo)
+	}
+}
+
+func TestListPayrollRecordsPage_TiesKeepTheirOrder(t *testing.T) {
+	store := newMemoryStore(t)
+	defer store.Close()
+	for i := 0; i < 5; i++ {
+		emp := mustCreateEmployee(t, store, fmt.Sprintf("Emp %d", i))
+		if _, err := store.CreatePayrollRecord(PayrollRecordInput{EmployeeID: emp.ID, Period: "2024-11", BaseSalary: 1000}); err != nil {
+			t.Fatalf("seed payroll: %v", err)
+		}
+	}
+	seen := map[int64]bool{}
+	req := PageRequest{Limit: 2, Sort: "-netPay"}
+	for {
+		page, err := store.ListPayrollRecordsPage(PayrollFilter{}, req)
+		if err != nil {
+			t.Fatalf("page: %v", err)
+		}
+		for _, rec := range page.Items {
+			if seen[rec.ID] {
+				t.Fatalf("record %d returned twice", rec.ID)
+			}
+			seen[rec.ID] = true
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		req.Cursor = page.NextCursor
+	}
+	if len(seen) != 5 {
+		t.Fatalf("expected every record once, got %d", len(seen))
+	}
+}
+
+func TestListPages_RejectBadParameters(t *testing.T) {
+	store, mux := setupTestServer(t)
+	defer store.Close()
+	mustCreateEmployee(t, store, "Alice")
+	mustCreateEmployee(t, store, "Bob")
+
+	first, err := store.ListEmployeesPage(EmployeeFilter{}, PageRequest{Limit: 1})
+	if err != nil {
+		t.Fatalf("page: %v", err)
+	}
+	cases := []struct {
+		path  string
+		field string
+	}{
+		{"/employees?limit=0", "limit"},
+		{"/employees?limit=1000", "limit"},
+		{"/employees?sort=salary", "sort"},
+		{"/payroll?cursor=not-a-cursor", "cursor"},
+		{"/reviews?sort=-rating&cursor=" + first.NextCursor, "cursor"},
+	}
+	for _, tc := range cases {
+		resp := doJSON(t, mux, http.MethodGet, tc.path, nil)
+		if resp.Code != http.StatusUnprocessableEntity {
+			t.Fatalf("%s: expected 422, got %d", tc.path, resp.Code)
+		}
+		if p := decodeProblem(t, resp.Body.Bytes()); len(p.Errors) != 1 || p.Errors[0].Field != tc.field {
+			t.Fatalf("%s: expected a %s error, got %+v", tc.path, tc.field, p)
+		}
+	}
+}
+
+func TestListPayroll_PageLinks(t *testing.T) {
+	store, mux := setupTestServer(t)
+	defer store.Close()
+	emp := mustCreateEmployee(t, store, "Alice")
+	for _, period := range []Period{"2024-10", "2024-11", "2024-12"} {
+		if _, err := store.CreatePayrollRecord(PayrollRecordInput{EmployeeID: emp.ID, Period: period, BaseSalary: 1000}); err != nil {
+			t.Fatalf("seed payroll: %v", err)
+		}
+	}
+
+	resp := doJSON(t, mux, http.MethodGet, "/payroll?limit=2", nil)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.Code)
+	}
+	var body struct {
+		Items []PayrollRecord `json:"items"`
+		Page  pageLinks       `json:"page"`
+	}
+	if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil {
+		t.Fatalf("json: %v", err)
+	}
+	if len(body.Items) != 2 || body.Items[0].Period != "2024-12" || body.Page.Total != 3 || body.Page.Prev != "" {
+		t.Fatalf("unexpected first page: %+v", body)
+	}
+	if resp.Header().Get("X-Total-Count") != "3" || !strings.Contains(resp.Header().Get("Link"), `rel="next"`) {
+		t.Fatalf("unexpected paging headers: %v", resp.Header())
+	}
+
+	resp = doJSON(t, mux, http.MethodGet, body.Page.Next, nil)
+	body.Items, body.Page = nil, pageLinks{}
+	if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil {
+		t.Fatalf("json: %v", err)
+	}
+	if len(body.Items) != 1 || body.Items[0].Period != "2024-10" || body.Page.Next != "" || body.Page.Prev == "" {
+		t.Fatalf("unexpected second page: %+v", body)
+	}
+	// Totals cover the whole filter, not only the page.
+	var totals struct {
+		Aggregates payrollAggregatesResponse `json:"aggregates"`
+	}
+	if err := json.Unmarshal(resp.Body.Bytes(), &totals); err != nil {
+		t.Fatalf("json: %v", err)
+	}
+	if len(totals.Aggregates.TotalsByPeriod) != 3 {
+		t.Fatalf("expected totals for every period, got %+v", totals.Aggregates)
+	}
+}
+
+func TestListEmployees_BareArrayWithPagingHeaders(t *testing.T) {
+	store, mux := setupTestServer(t)
+	defer store.Close()
+	mustCreateEmployee(t, store, "Alice")
+	mustCreateEmployee(t, store, "Bob")
+
+	resp := doJSON(t, mux, http.MethodGet, "/employees?limit=1&sort=-name", nil)
+	var list []Employee
+	if err := json.Unmarshal(resp.Body.Bytes(), &list); err != nil {
+		t.Fatalf("expected a bare array: %v", err)
+	}
+	if len(list) != 1 || list[0].Name != "Bob" {
+		t.Fatalf("unexpected page: %+v", list)
+	}
+	link := resp.Header().Get("Link")
+	if !strings.Contains(link, "sort=-name") || !strings.Contains(link, "limit=1") || resp.Header().Get("X-Total-Count") != "2" {
+		t.Fatalf("unexpected paging headers: %v", resp.Header())
+	}
+}
+
+func TestListEmployees_WithoutLimitReturnsEveryRow(t *testing.T) {
+	store, mux := setupTestServer(t)
+	defer store.Close()
+	for i := 0; i <= defaultPageLimit; i++ {
+		mustCreateEmployee(t, store, fmt.Sprintf("Employee %03d", i))
+	}
+
+	resp := doJSON(t, mux, http.MethodGet, "/employees", nil)
+	var list []Employee
+	if err := json.Unmarshal(resp.Body.Bytes(), &list); err != nil {
+		t.Fatalf("expected a bare array: %v", err)
+	}
+	if len(list) != defaultPageLimit+1 || resp.Header().Get("Link") != "" {
+		t.Fatalf("expected all %d employees on one page, got %d with Link %q", defaultPageLimit+1, len(list), resp.Header().Get("Link"))
+	}
+}
+
+func TestListReviewsAndPayroll_WithoutLimitReturnEveryRow(t *testing.T) {
+	store, mux := setupTestServer(t)
+	defer store.Close()
+	emp := mustCreateEmployee(t, store, "Alice")
+	for i := 0; i <= defaultPageLimit; i++ {
+		period := Period(fmt.Sprintf("%d-%02d", 2020+i/12, i%12+1))
+		if _, err := store.CreatePayrollRecord(PayrollRecordInput{EmployeeID: emp.ID, Period: period, BaseSalary: 1000}); err != nil {
+			t.Fatalf("seed payroll: %v", err)
+		}
+		if _, err := store.CreatePerformanceReview(PerformanceReviewInput{EmployeeID: emp.ID, Period: period, Reviewer: "Manager", Rating: 3}); err != nil {
+			t.Fatalf("seed review: %v", err)
+		}
+	}
+
+	for _, path := range []string{"/reviews", "/payroll"} {
+		resp := doJSON(t, mux, http.MethodGet, path, nil)
+		var body struct {
+			Items []json.RawMessage `json:"items"`
+			Page  pageLinks         `json:"page"`
+		}
+		if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil {
+			t.Fatalf("%s: json: %v", path, err)
+		}
+		if len(body.Items) != defaultPageLimit+1 || body.Page.Next != "" || resp.Header().Get("Link") != "" {
+			t.Fatalf("%s: expected all %d rows on one page, got %d with next %q", path, defaultPageLimit+1, len(body.Items), body.Page.Next)
+		}
+	}
+
+	// An explicit limit still pages.
+	resp := doJSON(t, mux, http.MethodGet, "/reviews?limit=10", nil)
+	var page reviewList
+	if err := json.Unmarshal(resp.Body.Bytes(), &page); err != nil {
+		t.Fatalf("json: %v", err)
+	}
+	if len(page.Items) != 10 || !strings.Contains(resp.Header().Get("Link"), `rel="next"`) {
+		t.Fatalf("expected a first page of 10, got %d with Link %q", len(page.Items), resp.Header().Get("Link"))
+	}
+}
+
+func TestListPages_WalkPastUnreadablePeriods(t *testing.T) {
+	store, err := NewStore(":memory:")
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	defer store.Close()
+	if err := store.migrate(migrations[:13]); err != nil {
+		t.Fatalf("migrate to v13: %v", err)
+	}
+	emp := mustCreateEmployee(t, store, "Alice")
+	// Migration 14 leaves the first two without bounds.
+	for _, period := range []string{"fin de año", "cierre", "2024-11", "2024-12"} {
+		if _, err := store.db.Exec(`INSERT INTO payroll_records(employee_id, period, base_salary_cents, net_pay_cents, status, created_at)
+			VALUES (?, ?, 1000, 1000, 'active', '2024-12-31T00:00:00Z')`, emp.ID, period); err != nil {
+			t.Fatalf("seed payroll %q: %v", period, err)
+		}
+		if _, err := store.db.Exec(`INSERT INTO performance_reviews(employee_id, period, reviewer, rating, strengths, opportunities, state)
+			VALUES (?, ?, 'Boss', 3, '', '', 'draft')`, emp.ID, period); err != nil {
+			t.Fatalf("seed review %q: %v", period, err)
+		}
+	}
+	if err := store.Migrate(); err != nil {
+		t.Fatalf("migrate store: %v", err)
+	}
+
+	for _, sort := range []string{"period", "-period"} {
+		payroll := map[int64]bool{}
+		req := PageRequest{Limit: 1, Sort: sort}
+		for i := 0; ; i++ {
+			if i > 4 {
+				t.Fatalf("payroll sort=%s: paging does not end", sort)
+			}
+			page, err := store.ListPayrollRecordsPage(PayrollFilter{}, req)
+			if err != nil {
+				t.Fatalf("payroll page: %v", err)
+			}
+			for _, rec := range page.Items {
+				payroll[rec.ID] = true
+			}
+			if page.NextCursor == "" {
+				break
+			}
+			req.Cursor = page.NextCursor
+		}
+		if len(payroll) != 4 {
+			t.Fatalf("payroll sort=%s: expected every record once, got %v", sort, payroll)
+		}
+
+		reviews := map[int64]bool{}
+		req = PageRequest{Limit: 1, Sort: sort}
+		for i := 0; ; i++ {
+			if i > 4 {
+				t.Fatalf("reviews sort=%s: paging does not end", sort)
+			}
+			page, err := store.ListPerformanceReviewsPage(PerformanceReviewFilter{}, req)
+			if err != nil {
+				t.Fatalf("reviews page: %v", err)
+			}
+			for _, r := range page.Items {
+				reviews[r.ID] = true
+			}
+			if page.NextCursor == "" {
+				break
+			}
+			req.Cursor = page.NextCursor
+		}
+		if len(reviews) != 4 {
+			t.Fatalf("reviews sort=%s: expected every review once, got %v", sort, reviews)
+		}
+	}
+}
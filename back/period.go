@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Period is a review or pay period in canonical form:
+//
+//	2024-03   month
+//	2024-Q1   quarter
+//	2024-H1   half-year
+//	2024      year
+//
+// Periods of one granularity sort correctly as text, but mixed ones do not:
+// "2024" would come before its months and "2024-Q1" after all of them. Rows
+// therefore also store the first and last month of their period, and ordering
+// and range queries use those instead.
+type Period string
+
+var periodMonths = map[string]int{
+	"jan": 1, "january": 1, "ene": 1, "enero": 1,
+	"feb": 2, "february": 2, "febrero": 2,
+	"mar": 3, "march": 3, "marzo": 3,
+	"apr": 4, "april": 4, "abr": 4, "abril": 4,
+	"may": 5, "mayo": 5,
+	"jun": 6, "june": 6, "junio": 6,
+	"jul": 7, "july": 7, "julio": 7,
+	"aug": 8, "august": 8, "ago": 8, "agosto": 8,
+	"sep": 9, "sept": 9, "september": 9, "set": 9, "septiembre": 9, "setiembre": 9,
+	"oct": 10, "october": 10, "octubre": 10,
+	"nov": 11, "november": 11, "noviembre": 11,
+	"dec": 12, "december": 12, "dic": 12, "diciembre": 12,
+}
+
+var (
+	periodSeparators = regexp.MustCompile(`[\s\-/.,_]+`)
+	// periodYearSuffix splits spellings such as 2024Q1 into year and part.
+	periodYearSuffix = regexp.MustCompile(`^(\d{4})([a-z])`)
+	periodYear       = regexp.MustCompile(`^\d{4}$`)
+)
+
+// ParsePeriod reads the spellings found in older data and typed by users,
+// such as "2024-1", "01/2024", "Ene 2024", "Q1 2024" or "2024h2", and returns
+// the canonical period.
+func ParsePeriod(s string) (Period, error) {
+	invalid := fmt.Errorf("%q is not a period; use 2024-03, 2024-Q1, 2024-H1 or 2024", s)
+	normalized := periodYearSuffix.ReplaceAllString(strings.ToLower(strings.TrimSpace(s)), "$1 $2")
+	var fields []string
+	for _, f := range periodSeparators.Split(normalized, -1) {
+		if f != "" {
+			fields = append(fields, f)
+		}
+	}
+	var year, part string
+	switch len(fields) {
+	case 1:
+		year = fields[0]
+	case 2:
+		year, part = fields[0], fields[1]
+		if !periodYear.MatchString(year) {
+			year, part = part, year
+		}
+	default:
+		return "", invalid
+	}
+	if !periodYear.MatchString(year) || year == "0000" {
+		return "", invalid
+	}
+
+	if part == "" {
+		return Period(year), nil
+	}
+	if month, ok := periodMonths[part]; ok {
+		return Period(fmt.Sprintf("%s-%02d", year, month)), nil
+	}
+	if len(part) <= 2 {
+		if month, err := strconv.Atoi(part); err == nil && month >= 1 && month <= 12 {
+			return Period(fmt.Sprintf("%s-%02d", year, month)), nil
+		}
+	}
+	if len(part) == 2 {
+		switch n := part[1]; {
+		case part[0] == 'q' && n >= '1' && n <= '4':
+			return Period(year + "-Q" + string(n)), nil
+		case part[0] == 'h' && (n == '1' || n == '2'):
+			return Period(year + "-H" + string(n)), nil
+		}
+	}
+	return "", invalid
+}
+
+// valid reports whether p is a period in canonical form.
+func (p Period) valid() bool {
+	parsed, err := ParsePeriod(string(p))
+	return err == nil && parsed == p
+}
+
+// bounds returns the first and last month of p as YYYY-MM, which sort as text
+// across granularities. p must be canonical.
+func (p Period) bounds() (first, last string) {
+	s := string(p)
+	if len(s) < 4 {
+		return s, s
+	}
+	year := s[:4]
+	months := func(from, to int) (string, string) {
+		return fmt.Sprintf("%s-%02d", year, from), fmt.Sprintf("%s-%02d", year, to)
+	}
+	switch {
+	case len(s) == 4:
+		return months(1, 12)
+	case len(s) == 7 && s[5] == 'Q':
+		n := int(s[6] - '0')
+		return months(n*3-2, n*3)
+	case len(s) == 7 && s[5] == 'H':
+		n := int(s[6] - '0')
+		return months(n*6-5, n*6)
+	default:
+		return s, s
+	}
+}
+
+// sortKey orders periods by their first month and then their last, so a
+// quarter follows its first month and precedes the half-year it starts. It
+// matches the period_start || period_end sort column, which is empty for
+// periods migration 14 could not read, so those get an empty key too.
+func (p Period) sortKey() string {
+	if !p.valid() {
+		return ""
+	}
+	first, last := p.bounds()
+	return first + last
+}
+
+// UnmarshalJSON stores any spelling ParsePeriod understands in canonical form.
+// Anything else is kept as sent, so validation can reject it with a field
+// error instead of failing the whole payload.
+func (p *Period) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	if parsed, err := ParsePeriod(s); err == nil {
+		*p = parsed
+		return nil
+	}
+	*p = Period(strings.TrimSpace(s))
+	return nil
+}
+
+// periodWithin keeps rows whose period lies inside r: starting no earlier
+// than Min and ending no later than Max. period=2024 therefore matches the
+// year and every month, quarter and half inside it. Rows whose period could
+// not be read during migration have no bounds and never match.
+func periodWithin(w *where, r Range[Period], start, end column) {
+	if r.Min != nil {
+		first, _ := r.Min.bounds()
+		w.add(string(start)+" >= ?", first)
+	}
+	if r.Max != nil {
+		if r.Min == nil {
+			w.add(string(start) + " <> ''")
+		}
+		_, last := r.Max.bounds()
+		w.add(string(end)+" <= ?", last)
+	}
+}
+
+func validatePeriod(v *ValidationError, p Period) {
+	switch {
+	case strings.TrimSpace(string(p)) == "":
+		v.add("period", FieldRequired, "period is required")
+	case !p.valid():
+		v.add("period", FieldInvalidFormat, "period must be a month (2024-03), quarter (2024-Q1), half-year (2024-H1) or year (2024)")
+	}
+}
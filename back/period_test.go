@@ -0,0 +1,135 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"testing"
+)
+
+func TestParsePeriod(t *testing.T) {
+	cases := map[string]Period{
+		"2024-01":    "2024-01",
+		"2024-1":     "2024-01",
+		"2024/1":     "2024-01",
+		"01/2024":    "2024-01",
+		"Ene 2024":   "2024-01",
+		"enero-2024": "2024-01",
+		"Sep. 2024":  "2024-09",
+		"2024-Q1":    "2024-Q1",
+		"2024q1":     "2024-Q1",
+		"Q4 2024":    "2024-Q4",
+		"2024-h2":    "2024-H2",
+		" 2024 ":     "2024",
+	}
+	for in, want := range cases {
+		got, err := ParsePeriod(in)
+		if err != nil || got != want {
+			t.Errorf("%q: expected %q, got %q (%v)", in, want, got, err)
+		}
+	}
+	for _, in := range []string{"", "2024-13", "2024-Q5", "2024-H3", "24-01", "2024-2025", "Foo 2024", "2024-01-15", "0000"} {
+		if got, err := ParsePeriod(in); err == nil {
+			t.Errorf("%q: expected an error, got %q", in, got)
+		}
+	}
+}
+
+func TestPeriodBoundsAndOrder(t *testing.T) {
+	cases := map[Period][2]string{
+		"2024-03": {"2024-03", "2024-03"},
+		"2024-Q2": {"2024-04", "2024-06"},
+		"2024-H2": {"2024-07", "2024-12"},
+		"2024":    {"2024-01", "2024-12"},
+	}
+	for p, want := range cases {
+		if first, last := p.bounds(); first != want[0] || last != want[1] {
+			t.Errorf("%s: expected %v, got %s..%s", p, want, first, last)
+		}
+	}
+
+	periods := []Period{"2024", "2024-Q1", "2023-12", "2024-H1", "2024-01", "2024-02"}
+	slices.SortFunc(periods, func(a, b Period) int { return cmp.Compare(a.sortKey(), b.sortKey()) })
+	want := []Period{"2023-12", "2024-01", "2024-Q1", "2024-H1", "2024", "2024-02"}
+	if !slices.Equal(periods, want) {
+		t.Fatalf("expected %v, got %v", want, periods)
+	}
+}
+
+func TestCreatePayroll_NormalizesPeriod(t *testing.T) {
+	store, mux := setupTestServer(t)
+	defer store.Close()
+	emp := mustCreateEmployee(t, store, "Alice")
+
+	resp := doJSON(t, mux, http.MethodPost, "/payroll", map[string]any{"employeeId": emp.ID, "period": "Ene 2024", "baseSalary": 1000})
+	if resp.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d body %s", resp.Code, resp.Body.String())
+	}
+	var created PayrollRecord
+	if err := json.Unmarshal(resp.Body.Bytes(), &created); err != nil {
+		t.Fatalf("json: %v", err)
+	}
+	if created.Period != "2024-01" {
+		t.Fatalf("expected the canonical period, got %q", created.Period)
+	}
+
+	resp = doJSON(t, mux, http.MethodPost, "/payroll", map[string]any{"employeeId": emp.ID, "period": "2024-1", "baseSalary": 1000})
+	if resp.Code != http.StatusConflict {
+		t.Fatalf("expected another spelling of the same period to conflict, got %d", resp.Code)
+	}
+
+	resp = doJSON(t, mux, http.MethodPost, "/payroll", map[string]any{"employeeId": emp.ID, "period": "2024-13", "baseSalary": 1000})
+	if resp.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d", resp.Code)
+	}
+	if p := decodeProblem(t, resp.Body.Bytes()); len(p.Errors) != 1 || p.Errors[0].Field != "period" || p.Errors[0].Code != FieldInvalidFormat {
+		t.Fatalf("expected a period format error, got %+v", p)
+	}
+}
+
+func TestListReviews_PeriodContainmentAndOrder(t *testing.T) {
+	store, mux := setupTestServer(t)
+	defer store.Close()
+	emp := mustCreateEmployee(t, store, "Alice")
+	for _, period := range []Period{"2024", "2024-Q2", "2024-03", "2023-H2", "2024-H1"} {
+		if _, err := store.CreatePerformanceReview(PerformanceReviewInput{EmployeeID: emp.ID, Period: period, Reviewer: "Boss", Rating: 3}); err != nil {
+			t.Fatalf("seed review: %v", err)
+		}
+	}
+
+	periods := func(query string) []Period {
+		t.Helper()
+		resp := doJSON(t, mux, http.MethodGet, "/reviews?"+query, nil)
+		if resp.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d body %s", query, resp.Code, resp.Body.String())
+		}
+		var body reviewListResponse
+		if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil {
+			t.Fatalf("json: %v", err)
+		}
+		out := make([]Period, 0, len(body.Items))
+		for _, r := range body.Items {
+			out = append(out, r.Period)
+		}
+		return out
+	}
+
+	cases := map[string][]Period{
+		"sort=period":                     {"2023-H2", "2024-H1", "2024", "2024-03", "2024-Q2"},
+		"sort=-period":                    {"2024-Q2", "2024-03", "2024", "2024-H1", "2023-H2"},
+		"period=2024&sort=period":         {"2024-H1", "2024", "2024-03", "2024-Q2"},
+		"period=2024-H1&sort=period":      {"2024-H1", "2024-03", "2024-Q2"},
+		"period>=Q2+2024":                 {"2024-Q2"},
+		"period<=2024-Q1":                 {"2023-H2", "2024-03"},
+		"period>=2024-01&period<=2024-03": {"2024-03"},
+	}
+	for query, want := range cases {
+		if got := periods(query); !slices.Equal(got, want) {
+			t.Errorf("%s: expected %v, got %v", query, want, got)
+		}
+	}
+	if resp := doJSON(t, mux, http.MethodGet, "/reviews?period=last-year", nil); resp.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 for an unreadable period, got %d", resp.Code)
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// Problem is an RFC 7807 error body. Code is stable and meant for programs;
+// Detail is English text meant for people and may change.
+type Problem struct {
+	Type   string       `json:"type"`
+	Title  string       `json:"title"`
+	Status int          `json:"status"`
+	Code   string       `json:"code"`
+	Detail string       `json:"detail"`
+	Errors []FieldError `json:"errors,omitempty"`
+	// ExistingID points at the record a create collided with.
+	ExistingID int64 `json:"existingId,omitempty"`
+	// Error repeats Detail for clients written against the old {"error": "..."} body.
+	Error string `json:"error"`
+}
+
+// Codes used when a response has no more specific code.
+var statusCodes = map[int]string{
+	http.StatusBadRequest:            "bad_request",
+	http.StatusUnauthorized:          "unauthorized",
+	http.StatusForbidden:             "forbidden",
+	http.StatusNotFound:              "not_found",
+	http.StatusConflict:              "conflict",
+	http.StatusPreconditionFailed:    "precondition_failed",
+	http.StatusRequestEntityTooLarge: "payload_too_large",
+	http.StatusUnsupportedMediaType:  "unsupported_media_type",
+	http.StatusUnprocessableEntity:   "invalid_request",
+	http.StatusInternalServerError:   "internal_error",
+}
+
+// errorCodes gives each domain error its stable code.
+var errorCodes = []struct {
+	err  error
+	code string
+}{
+	{ErrNotFound, "not_found"},
+	{ErrEmployeeNotFound, "unknown_employee"},
+	{ErrManagerNotFound, "unknown_manager"},
+	{ErrInvalidManager, "invalid_manager"},
+	{ErrDuplicateEmail, "duplicate_email"},
+	{ErrDuplicateNationalID, "duplicate_national_id"},
+	{ErrDuplicateUserEmail, "duplicate_user_email"},
+	{ErrEmployeeArchived, "employee_archived"},
+	{ErrEmployeeNotArchived, "employee_not_archived"},
+	{ErrInvalidTerminationDate, "invalid_termination_date"},
+	{ErrPayrollVoided, "payroll_voided"},
+	{ErrInvalidTransition, "invalid_transition"},
+	{ErrTransitionConflict, "transition_conflict"},
+	{ErrTransitionReasonRequired, "reason_required"},
+	{ErrReviewLocked, "review_locked"},
+	{ErrReviewCommentsOnly, "review_comments_only"},
+	{ErrVersionMismatch, "version_mismatch"},
+	{ErrInvalidCredentials, "invalid_credentials"},
+	{ErrIdempotencyKeyReused, "idempotency_key_reused"},
+	{ErrIdempotencyKeyInProgress, "idempotency_key_in_progress"},
+}
+
+const (
+	codeValidationFailed = "validation_failed"
+	codeInvalidPayload   = "invalid_payload"
+	codeDuplicateRecord  = "duplicate_record"
+)
+
+// Field error codes describe why a single field was rejected.
+const (
+	FieldRequired      = "required"
+	FieldOutOfRange    = "out_of_range"
+	FieldInvalidFormat = "invalid_format"
+	FieldInvalidChoice = "invalid_choice"
+	FieldTooShort      = "too_short"
+	FieldImmutable     = "immutable"
+)
+
+// FieldError is one rejected input field, named as in the JSON payload.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ValidationError collects every rejected field of an input, so clients can
+// show all problems at once instead of one per round trip.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		messages = append(messages, f.Message)
+	}
+	return strings.Join(messages, "; ")
+}
+
+func (e *ValidationError) add(field, code, message string) {
+	e.Fields = append(e.Fields, FieldError{Field: field, Code: code, Message: message})
+}
+
+// fieldError is a ValidationError for a single field.
+func fieldError(field, code, message string) error {
+	return &ValidationError{Fields: []FieldError{{Field: field, Code: code, Message: message}}}
+}
+
+// orNil returns the error only if a field was rejected.
+func (e *ValidationError) orNil() error {
+	if len(e.Fields) == 0 {
+		return nil
+	}
+	return e
+}
+
+func writeProblem(w http.ResponseWriter, p Problem) {
+	p.Type = "about:blank"
+	p.Title = http.StatusText(p.Status)
+	p.Error = p.Detail
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(p.Status)
+	_ = json.NewEncoder(w).Encode(p)
+}
+
+// writeError answers with the generic code for the status.
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeErrorCode(w, status, statusCodes[status], msg)
+}
+
+func writeErrorCode(w http.ResponseWriter, status int, code, msg string) {
+	writeProblem(w, Problem{Status: status, Code: code, Detail: msg})
+}
+
+// writeErrorFor derives code and detail from err. Validation errors always
+// answer 422 with their field list, whatever status is passed.
+func writeErrorFor(w http.ResponseWriter, status int, err error) {
+	var validation *ValidationError
+	if errors.As(err, &validation) {
+		writeProblem(w, Problem{Status: http.StatusUnprocessableEntity, Code: codeValidationFailed, Detail: err.Error(), Errors: validation.Fields})
+		return
+	}
+	var dup *DuplicateError
+	if errors.As(err, &dup) {
+		writeProblem(w, Problem{Status: status, Code: codeDuplicateRecord, Detail: err.Error(), ExistingID: dup.ExistingID})
+		return
+	}
+	code := statusCodes[status]
+	for _, c := range errorCodes {
+		if errors.Is(err, c.err) {
+			code = c.code
+			break
+		}
+	}
+	writeErrorCode(w, status, code, err.Error())
+}
+
+func writeInvalidPayload(w http.ResponseWriter) {
+	writeErrorCode(w, http.StatusUnprocessableEntity, codeInvalidPayload, "invalid payload")
+}
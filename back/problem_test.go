@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+func decodeProblem(t *testing.T, body []byte) Problem {
+	t.Helper()
+	var p Problem
+	if err := json.Unmarshal(body, &p); err != nil {
+		t.Fatalf("json: %v", err)
+	}
+	return p
+}
+
+func TestProblem_ValidationListsEveryField(t *testing.T) {
+	store, mux := setupTestServer(t)
+	defer store.Close()
+
+	resp := doJSON(t, mux, http.MethodPost, "/payroll", map[string]any{"baseSalary": -1, "overtimeHours": 1000})
+	if resp.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d", resp.Code)
+	}
+	if ct := resp.Header().Get("Content-Type"); ct != "application/problem+json" {
+		t.Fatalf("expected problem+json, got %q", ct)
+	}
+	p := decodeProblem(t, resp.Body.Bytes())
+	if p.Code != codeValidationFailed || p.Status != http.StatusUnprocessableEntity || p.Error != p.Detail {
+		t.Fatalf("unexpected problem: %+v", p)
+	}
+	got := map[string]string{}
+	for _, f := range p.Errors {
+		got[f.Field] = f.Code
+	}
+	want := map[string]string{
+		"employeeId":    FieldRequired,
+		"period":        FieldRequired,
+		"baseSalary":    FieldOutOfRange,
+		"overtimeHours": FieldOutOfRange,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected fields %v, got %+v", want, p.Errors)
+	}
+	for field, code := range want {
+		if got[field] != code {
+			t.Fatalf("expected %s=%s, got %+v", field, code, p.Errors)
+		}
+	}
+}
+
+func TestProblem_ReviewAndStoreValidatorsAgree(t *testing.T) {
+	store, mux := setupTestServer(t)
+	defer store.Close()
+
+	resp := doJSON(t, mux, http.MethodPost, "/reviews", map[string]any{"rating": 9})
+	p := decodeProblem(t, resp.Body.Bytes())
+
+	err := validateReviewInput(PerformanceReviewInput{Rating: 9})
+	v, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("expected a ValidationError, got %v", err)
+	}
+	if len(p.Errors) != len(v.Fields) {
+		t.Fatalf("handler and store disagree: %+v vs %+v", p.Errors, v.Fields)
+	}
+	for i := range v.Fields {
+		if p.Errors[i] != v.Fields[i] {
+			t.Fatalf("handler and store disagree: %+v vs %+v", p.Errors, v.Fields)
+		}
+	}
+}
+
+func TestProblem_BlankReviewerOnUpdateMatchesCreate(t *testing.T) {
+	store, mux := setupTestServer(t)
+	defer store.Close()
+
+	emp := mustCreateEmployee(t, store, "Alice")
+	review := mustCreateReview(t, store, emp.ID)
+
+	created := decodeProblem(t, doJSON(t, mux, http.MethodPost, "/reviews", map[string]any{"employeeId": emp.ID, "period": "2025-Q1", "reviewer": "  ", "rating": 3}).Body.Bytes())
+	resp := doJSON(t, mux, http.MethodPut, "/reviews/"+strconv.FormatInt(review.ID, 10), map[string]any{"reviewer": "  "})
+	if resp.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d body %s", resp.Code, resp.Body.String())
+	}
+	updated := decodeProblem(t, resp.Body.Bytes())
+	if len(created.Errors) != 1 || len(updated.Errors) != 1 || created.Errors[0] != updated.Errors[0] {
+		t.Fatalf("create and update disagree: %+v vs %+v", created.Errors, updated.Errors)
+	}
+	if updated.Errors[0].Field != "reviewer" || updated.Errors[0].Code != FieldRequired {
+		t.Fatalf("unexpected field error %+v", updated.Errors[0])
+	}
+
+	blank := "  "
+	if _, err := store.UpdatePerformanceReview(review.ID, PerformanceReviewUpdate{Reviewer: &blank}); err == nil {
+		t.Fatal("expected the store to reject a blank reviewer")
+	}
+	got, err := store.GetPerformanceReview(review.ID)
+	if err != nil {
+		t.Fatalf("get review: %v", err)
+	}
+	if got.Reviewer != review.Reviewer {
+		t.Fatalf("reviewer changed to %q", got.Reviewer)
+	}
+}
+
+func TestProblem_DomainErrorsHaveStableCodes(t *testing.T) {
+	store, mux := setupTestServer(t)
+	defer store.Close()
+
+	emp := mustCreateEmployee(t, store, "Alice")
+	review := mustCreateReview(t, store, emp.ID)
+
+	cases := []struct {
+		name   string
+		method string
+		path   string
+		body   any
+		status int
+		code   string
+	}{
+		{"not found", http.MethodGet, "/employees/999", nil, http.StatusNotFound, "not_found"},
+		{"invalid payload", http.MethodPost, "/employees", "not an object", http.StatusUnprocessableEntity, codeInvalidPayload},
+		{"unknown employee", http.MethodPost, "/payroll", map[string]any{"employeeId": 999, "period": "2024-11"}, http.StatusUnprocessableEntity, "unknown_employee"},
+		{"invalid transition", http.MethodPut, "/reviews/" + strconv.FormatInt(review.ID, 10) + "/status", map[string]string{"state": ReviewStateApproved}, http.StatusUnprocessableEntity, "invalid_transition"},
+		{"duplicate review", http.MethodPost, "/reviews", map[string]any{"employeeId": emp.ID, "period": "2024-Q4", "reviewer": "manager", "rating": 3}, http.StatusConflict, codeDuplicateRecord},
+	}
+	for _, tc := range cases {
+		resp := doJSON(t, mux, tc.method, tc.path, tc.body)
+		if resp.Code != tc.status {
+			t.Fatalf("%s: expected %d, got %d body %s", tc.name, tc.status, resp.Code, resp.Body.String())
+		}
+		if p := decodeProblem(t, resp.Body.Bytes()); p.Code != tc.code {
+			t.Fatalf("%s: expected code %q, got %+v", tc.name, tc.code, p)
+		}
+	}
+}
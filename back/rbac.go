@@ -0,0 +1,155 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+)
+
+// permission is an action a role may perform. Routes declare the permission
+// each HTTP method needs; row-level rules live in the scope helpers below.
+type permission string
+
+const (
+	permManageUsers    permission = "users:manage"
+	permReadEmployees  permission = "employees:read"
+	permWriteEmployees permission = "employees:write"
+	permPurgeEmployees permission = "employees:purge"
+	permReadReviews    permission = "reviews:read"
+	permWriteReviews   permission = "reviews:write"
+	permReadPayroll    permission = "payroll:read"
+	permWritePayroll   permission = "payroll:write"
+	permViewBaseSalary permission = "payroll:base-salary"
+	permReadAudit      permission = "audit:read"
+)
+
+var rolePermissions = map[string][]permission{
+	RoleAdmin: {
+		permManageUsers, permReadEmployees, permWriteEmployees, permPurgeEmployees,
+		permReadReviews, permWriteReviews, permReadPayroll, permReadAudit,
+	},
+	RoleHR: {
+		permReadEmployees, permWriteEmployees, permReadReviews, permWriteReviews,
+		permReadPayroll, permWritePayroll, permViewBaseSalary, permReadAudit,
+	},
+	RoleFinance: {
+		permReadEmployees, permReadPayroll, permWritePayroll, permViewBaseSalary,
+	},
+	RoleManager: {
+		permReadEmployees, permReadReviews, permWriteReviews,
+	},
+	RoleEmployee: {
+		permReadEmployees, permReadReviews, permReadPayroll,
+	},
+}
+
+func (p Principal) can(perm permission) bool {
+	return slices.Contains(rolePermissions[p.User.Role], perm)
+}
+
+// employeeScope limits managers to themselves and their reports, and employees to themselves.
+func (p Principal) employeeScope() AccessScope {
+	switch p.User.Role {
+	case RoleAdmin, RoleHR, RoleFinance:
+		return AccessScope{}
+	case RoleManager:
+		return AccessScope{Restricted: true, SelfID: p.User.EmployeeID, ManagerID: p.User.EmployeeID}
+	default:
+		return AccessScope{Restricted: true, SelfID: p.User.EmployeeID}
+	}
+}
+
+// reviewScope limits managers to their reports' reviews and employees to their own.
+func (p Principal) reviewScope() AccessScope {
+	switch p.User.Role {
+	case RoleAdmin, RoleHR:
+		return AccessScope{}
+	case RoleManager:
+		return AccessScope{Restricted: true, ManagerID: p.User.EmployeeID}
+	default:
+		return AccessScope{Restricted: true, SelfID: p.User.EmployeeID}
+	}
+}
+
+// payrollScope limits everyone outside HR, finance and admin to their own payslips.
+func (p Principal) payrollScope() AccessScope {
+	switch p.User.Role {
+	case RoleAdmin, RoleHR, RoleFinance:
+		return AccessScope{}
+	default:
+		return AccessScope{Restricted: true, SelfID: p.User.EmployeeID}
+	}
+}
+
+// methodPermissions maps an HTTP method to the permission it requires.
+type methodPermissions map[string]permission
+
+// guard answers 403 when the caller lacks the permission for the request
+// method. Methods without an entry fall through so the handler can answer 405.
+func (a *API) guard(perms methodPermissions, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if perm, ok := perms[r.Method]; ok {
+			principal, _ := principalFrom(r.Context())
+			if !principal.can(perm) {
+				setJSON(w)
+				writeError(w, http.StatusForbidden, "forbidden")
+				return
+			}
+		}
+		next(w, r)
+	}
+}
+
+// requireInScope answers 403 unless the employee falls within the scope.
+func (a *API) requireInScope(w http.ResponseWriter, scope AccessScope, employeeID int64) bool {
+	ok, err := a.store.EmployeeInScope(employeeID, scope)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, internalErrorMsg)
+		return false
+	}
+	if !ok {
+		writeError(w, http.StatusForbidden, "forbidden")
+		return false
+	}
+	return true
+}
+
+// writeNotFoundInScope answers a missing record. Restricted callers get the
+// 403 requireInScope gives for records out of scope, so they cannot tell which
+// ids exist.
+func writeNotFoundInScope(w http.ResponseWriter, scope AccessScope) {
+	if scope.Restricted {
+		writeError(w, http.StatusForbidden, "forbidden")
+		return
+	}
+	writeError(w, http.StatusNotFound, "not found")
+}
+
+// payComponentFields are the payroll fields left out for roles that may not
+// see base salary. Net pay stays, but without the other amounts it no longer
+// gives base salary away.
+var payComponentFields = []string{"baseSalary", "overtimeHours", "overtimeRate", "bonuses", "deductions", "lineItems"}
+
+// payrollRecordView shadows the pay components so they can be left out
+// together; see payComponentFields.
+type payrollRecordView struct {
+	PayrollRecord
+	BaseSalary    *Money             `json:"baseSalary,omitempty"`
+	OvertimeHours *float64           `json:"overtimeHours,omitempty"`
+	OvertimeRate  *Money             `json:"overtimeRate,omitempty"`
+	Bonuses       *Money             `json:"bonuses,omitempty"`
+	Deductions    *Money             `json:"deductions,omitempty"`
+	LineItems     *[]PayrollLineItem `json:"lineItems,omitempty"`
+}
+
+func payrollView(p Principal, record PayrollRecord) payrollRecordView {
+	view := payrollRecordView{PayrollRecord: record}
+	if p.can(permViewBaseSalary) {
+		view.BaseSalary = &record.BaseSalary
+		view.OvertimeHours = &record.OvertimeHours
+		view.OvertimeRate = &record.OvertimeRate
+		view.Bonuses = &record.Bonuses
+		view.Deductions = &record.Deductions
+		view.LineItems = &record.LineItems
+	}
+	return view
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// rbacFixture holds a manager with one direct report plus an unrelated
+// employee, each with a review and a payslip.
+type rbacFixture struct {
+	store   *Store
+	mux     *http.ServeMux
+	manager Employee
+	report  Employee
+	other   Employee
+	reviews map[int64]PerformanceReview
+	payroll map[int64]PayrollRecord
+}
+
+func setupRBACFixture(t *testing.T) rbacFixture {
+	t.Helper()
+	store, mux := newTestMux(t)
+	t.Cleanup(func() { store.Close() })
+
+	f := rbacFixture{store: store, mux: mux, reviews: map[int64]PerformanceReview{}, payroll: map[int64]PayrollRecord{}}
+	f.manager = mustCreateEmployee(t, store, "Manager")
+	report, err := store.CreateEmployee(EmployeeInput{Name: "Report", ManagerID: f.manager.ID})
+	if err != nil {
+		t.Fatalf("create report: %v", err)
+	}
+	f.report = report
+	f.other = mustCreateEmployee(t, store, "Other")
+
+	for _, emp := range []Employee{f.manager, f.report, f.other} {
+		f.reviews[emp.ID] = mustCreateReview(t, store, emp.ID)
+		record, err := store.CreatePayrollRecord(PayrollRecordInput{EmployeeID: emp.ID, Period: "2024-01", BaseSalary: 100000})
+		if err != nil {
+			t.Fatalf("create payroll: %v", err)
+		}
+		f.payroll[emp.ID] = record
+	}
+	return f
+}
+
+func (f rbacFixture) as(role string, employeeID int64) http.Handler {
+	return withTestPrincipal(f.mux, Principal{User: User{ID: 99, Role: role, EmployeeID: employeeID}})
+}
+
+func reviewEmployeeIDs(t *testing.T, body []byte) []int64 {
+	t.Helper()
+	var list reviewList
+	if err := json.Unmarshal(body, &list); err != nil {
+		t.Fatalf("json: %v", err)
+	}
+	ids := make([]int64, 0, len(list.Items))
+	for _, item := range list.Items {
+		ids = append(ids, item.EmployeeID)
+	}
+	return ids
+}
+
+func TestRBAC_forbiddenRoutes(t *testing.T) {
+	f := setupRBACFixture(t)
+
+	cases := []struct {
+		role         string
+		method, path string
+		body         any
+	}{
+		{"", http.MethodGet, "/employees", nil},
+		{RoleHR, http.MethodGet, "/users", nil},
+		{RoleHR, http.MethodDelete, "/admin/employees/1", nil},
+		{RoleFinance, http.MethodGet, "/reviews", nil},
+		{RoleFinance, http.MethodPost, "/employees", map[string]string{"name": "X"}},
+		{RoleManager, http.MethodGet, "/payroll", nil},
+		{RoleManager, http.MethodPut, "/employees/2", map[string]string{"name": "X"}},
+		{RoleEmployee, http.MethodPost, "/payroll", map[string]any{"employeeId": 2, "period": "2024-02"}},
+		{RoleEmployee, http.MethodPut, "/reviews/2/status", map[string]string{"state": "submitted"}},
+		{RoleAdmin, http.MethodPost, "/payroll/1/void", map[string]string{"reason": "x"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.role+" "+tc.method+" "+tc.path, func(t *testing.T) {
+			rr := doJSON(t, f.as(tc.role, f.report.ID), tc.method, tc.path, tc.body)
+			if rr.Code != http.StatusForbidden {
+				t.Fatalf("expected 403, got %d body %s", rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestRBAC_managerSeesOnlyReportsReviews(t *testing.T) {
+	f := setupRBACFixture(t)
+	h := f.as(RoleManager, f.manager.ID)
+
+	rr := doJSON(t, h, http.MethodGet, "/reviews", nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	if ids := reviewEmployeeIDs(t, rr.Body.Bytes()); len(ids) != 1 || ids[0] != f.report.ID {
+		t.Fatalf("expected only the report's review, got employees %v", ids)
+	}
+
+	otherReview := strconv.FormatInt(f.reviews[f.other.ID].ID, 10)
+	if rr := doJSON(t, h, http.MethodGet, "/reviews/"+otherReview, nil); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for another team's review, got %d", rr.Code)
+	}
+	if rr := doJSON(t, h, http.MethodPut, "/reviews/"+otherReview+"/status", map[string]string{"state": "submitted"}); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 transitioning another team's review, got %d", rr.Code)
+	}
+	if rr := doJSON(t, h, http.MethodGet, "/reviews/999999", nil); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for a missing review too, got %d", rr.Code)
+	}
+	if rr := doJSON(t, h, http.MethodPut, "/reviews/999999", map[string]int{"rating": 5}); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 editing a missing review too, got %d", rr.Code)
+	}
+	ownReview := strconv.FormatInt(f.reviews[f.manager.ID].ID, 10)
+	if rr := doJSON(t, h, http.MethodGet, "/reviews/"+ownReview, nil); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for the manager's own review, got %d", rr.Code)
+	}
+
+	reportReview := strconv.FormatInt(f.reviews[f.report.ID].ID, 10)
+	if rr := doJSON(t, h, http.MethodPut, "/reviews/"+reportReview, map[string]int{"rating": 5}); rr.Code != http.StatusOK {
+		t.Fatalf("expected manager to edit a report's review, got %d", rr.Code)
+	}
+	review := map[string]any{"employeeId": f.other.ID, "period": "2025-Q1", "reviewer": "M", "rating": 3}
+	if rr := doJSON(t, h, http.MethodPost, "/reviews", review); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 reviewing another team, got %d", rr.Code)
+	}
+	review["employeeId"] = f.report.ID
+	if rr := doJSON(t, h, http.MethodPost, "/reviews", review); rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201 reviewing a report, got %d", rr.Code)
+	}
+
+	rr = doJSON(t, h, http.MethodGet, "/employees", nil)
+	var employees []Employee
+	if err := json.Unmarshal(rr.Body.Bytes(), &employees); err != nil {
+		t.Fatalf("json: %v", err)
+	}
+	if len(employees) != 2 || employees[0].ID != f.manager.ID || employees[1].ID != f.report.ID {
+		t.Fatalf("expected manager and report, got %+v", employees)
+	}
+	if rr := doJSON(t, h, http.MethodGet, "/employees/"+strconv.FormatInt(f.other.ID, 10), nil); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for an unrelated employee, got %d", rr.Code)
+	}
+	if rr := doJSON(t, h, http.MethodGet, "/employees/999999", nil); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for a missing employee too, got %d", rr.Code)
+	}
+	rr = doJSON(t, h, http.MethodGet, "/employees/"+strconv.FormatInt(f.report.ID, 10), nil)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "reviewSummary") || strings.Contains(rr.Body.String(), "payrollSummary") {
+		t.Fatalf("expected review summary without payroll, got %d %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestRBAC_employeeSeesOnlyOwnPayslips(t *testing.T) {
+	f := setupRBACFixture(t)
+	h := f.as(RoleEmployee, f.report.ID)
+
+	rr := doJSON(t, h, http.MethodGet, "/payroll", nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	var list payrollList
+	if err := json.Unmarshal(rr.Body.Bytes(), &list); err != nil {
+		t.Fatalf("json: %v", err)
+	}
+	if len(list.Items) != 1 || list.Items[0].EmployeeID != f.report.ID {
+		t.Fatalf("expected only own payslip, got %+v", list.Items)
+	}
+	if list.Aggregates.GrandTotalNet != f.payroll[f.report.ID].NetPay {
+		t.Fatalf("expected totals limited to own payslip, got %s", list.Aggregates.GrandTotalNet)
+	}
+	if strings.Contains(rr.Body.String(), "baseSalary") {
+		t.Fatalf("employees must not see baseSalary: %s", rr.Body.String())
+	}
+
+	if rr := doJSON(t, h, http.MethodGet, "/payroll?employeeId="+strconv.FormatInt(f.other.ID, 10), nil); !strings.Contains(rr.Body.String(), `"items":[]`) {
+		t.Fatalf("expected filtering by another employee to return nothing, got %s", rr.Body.String())
+	}
+	otherSlip := strconv.FormatInt(f.payroll[f.other.ID].ID, 10)
+	if rr := doJSON(t, h, http.MethodGet, "/payroll/"+otherSlip, nil); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for another payslip, got %d", rr.Code)
+	}
+	if rr := doJSON(t, h, http.MethodGet, "/payroll/999999", nil); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for a missing payslip too, got %d", rr.Code)
+	}
+	for _, path := range []string{"/payroll/999999", "/reviews/999999"} {
+		if rr := doJSON(t, f.as(RoleHR, 0), http.MethodGet, path, nil); rr.Code != http.StatusNotFound {
+			t.Fatalf("expected 404 for %s without a restricted scope, got %d", path, rr.Code)
+		}
+	}
+	ownSlip := strconv.FormatInt(f.payroll[f.report.ID].ID, 10)
+	rr = doJSON(t, h, http.MethodGet, "/payroll/"+ownSlip, nil)
+	if rr.Code != http.StatusOK || strings.Contains(rr.Body.String(), "baseSalary") {
+		t.Fatalf("expected own payslip without baseSalary, got %d %s", rr.Code, rr.Body.String())
+	}
+
+	rr = doJSON(t, h, http.MethodGet, "/reviews", nil)
+	if ids := reviewEmployeeIDs(t, rr.Body.Bytes()); len(ids) != 1 || ids[0] != f.report.ID {
+		t.Fatalf("expected only own review, got employees %v", ids)
+	}
+}
+
+func TestRBAC_baseSalaryVisibility(t *testing.T) {
+	f := setupRBACFixture(t)
+	slip := "/payroll/" + strconv.FormatInt(f.payroll[f.other.ID].ID, 10)
+
+	for role, visible := range map[string]bool{RoleHR: true, RoleFinance: true, RoleAdmin: false} {
+		h := f.as(role, 0)
+		for _, path := range []string{"/payroll", slip} {
+			rr := doJSON(t, h, http.MethodGet, path, nil)
+			if rr.Code != http.StatusOK {
+				t.Fatalf("%s %s: expected 200, got %d", role, path, rr.Code)
+			}
+			if got := strings.Contains(rr.Body.String(), `"baseSalary":1000.00`); got != visible {
+				t.Fatalf("%s %s: baseSalary visible=%v, want %v: %s", role, path, got, visible, rr.Body.String())
+			}
+			// The other components would let base salary be worked out from net pay.
+			for _, field := range payComponentFields {
+				if got := strings.Contains(rr.Body.String(), `"`+field+`":`); got != visible {
+					t.Fatalf("%s %s: %s visible=%v, want %v: %s", role, path, field, got, visible, rr.Body.String())
+				}
+			}
+			if !strings.Contains(rr.Body.String(), `"netPay":`) {
+				t.Fatalf("%s %s: expected netPay to stay visible: %s", role, path, rr.Body.String())
+			}
+		}
+	}
+}
+
+func TestRBAC_unlinkedUserSeesNothing(t *testing.T) {
+	f := setupRBACFixture(t)
+	h := f.as(RoleEmployee, 0)
+
+	rr := doJSON(t, h, http.MethodGet, "/employees", nil)
+	if rr.Code != http.StatusOK || strings.TrimSpace(rr.Body.String()) != "[]" {
+		t.Fatalf("expected an empty list, got %d %s", rr.Code, rr.Body.String())
+	}
+	rr = doJSON(t, h, http.MethodGet, "/payroll", nil)
+	if !strings.Contains(rr.Body.String(), `"items":[]`) {
+		t.Fatalf("expected no payslips, got %s", rr.Body.String())
+	}
+}
+
+func TestEmployeeManagerValidation(t *testing.T) {
+	store, mux := setupTestServer(t)
+	defer store.Close()
+
+	if rr := doJSON(t, mux, http.MethodPost, "/employees", map[string]any{"name": "A", "managerId": 42}); rr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 for unknown manager, got %d", rr.Code)
+	}
+	mustCreateEmployee(t, store, "Boss")
+	mustCreateEmployee(t, store, "Worker")
+	rr := doJSON(t, mux, http.MethodPut, "/employees/2", map[string]any{"managerId": 1})
+	var updated Employee
+	if err := json.Unmarshal(rr.Body.Bytes(), &updated); err != nil || updated.ManagerID != 1 {
+		t.Fatalf("expected manager to be set, got %d %s", rr.Code, rr.Body.String())
+	}
+	if rr := doJSON(t, mux, http.MethodPut, "/employees/2", map[string]any{"managerId": 2}); rr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 for self manager, got %d", rr.Code)
+	}
+	rr = doJSON(t, mux, http.MethodPut, "/employees/2", map[string]any{"managerId": 0})
+	if rr.Code != http.StatusOK || strings.Contains(rr.Body.String(), "managerId") {
+		t.Fatalf("expected manager to be cleared, got %d %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestCreateUser_roleValidation(t *testing.T) {
+	store, mux := newTestMux(t)
+	defer store.Close()
+	h := withTestPrincipal(mux, Principal{User: User{ID: 1, Role: RoleAdmin}})
+	mustCreateEmployee(t, store, "Linked")
+
+	base := map[string]any{"email": "u@example.com", "name": "U", "password": "long enough"}
+	for role, want := range map[string]int{"owner": http.StatusUnprocessableEntity, RoleEmployee: http.StatusUnprocessableEntity} {
+		body := map[string]any{"role": role}
+		for k, v := range base {
+			body[k] = v
+		}
+		if rr := doJSON(t, h, http.MethodPost, "/users", body); rr.Code != want {
+			t.Fatalf("role %q: expected %d, got %d", role, want, rr.Code)
+		}
+	}
+	base["role"] = RoleEmployee
+	base["employeeId"] = 7
+	if rr := doJSON(t, h, http.MethodPost, "/users", base); rr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 for unknown employee, got %d", rr.Code)
+	}
+	base["employeeId"] = 1
+	rr := doJSON(t, h, http.MethodPost, "/users", base)
+	var created User
+	if err := json.Unmarshal(rr.Body.Bytes(), &created); err != nil || rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d %s", rr.Code, rr.Body.String())
+	}
+	if created.Role != RoleEmployee || created.EmployeeID != 1 {
+		t.Fatalf("unexpected user %+v", created)
+	}
+}
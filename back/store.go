@@ -4,26 +4,104 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"net/mail"
 	"strings"
+	"time"
+	"unicode"
 
 	_ "modernc.org/sqlite"
 )
 
 type Employee struct {
-	ID   int64  `json:"id"`
-	Name string `json:"name"`
+	ID         int64  `json:"id"`
+	Name       string `json:"name"`
+	Email      string `json:"email"`
+	Department string `json:"department"`
+	JobTitle   string `json:"jobTitle"`
+	HireDate   string `json:"hireDate"`
+	Status     string `json:"status"`
+	NationalID string `json:"nationalId"`
+	// TerminationDate and ArchivedAt are only set once the employee has been offboarded.
+	TerminationDate string `json:"terminationDate"`
+	ArchivedAt      string `json:"archivedAt"`
+	ManagerID       int64  `json:"managerId,omitempty"`
+	// Version increases with every change and backs the ETag used for optimistic concurrency.
+	Version int64 `json:"version"`
+}
+
+type EmployeeFilter struct {
+	IDs  []int64
+	Name string // case-insensitive substring
+	// Search matches every word as the start of a word in the name, ignoring
+	// case and accents.
+	Search          string
+	Departments     []string
+	JobTitle        string // case-insensitive substring
+	Statuses        []string
+	ManagerIDs      []int64
+	HireDate        Range[string]
+	IncludeArchived bool
+	Scope           AccessScope
+}
+
+// AccessScope narrows queries to the employees a caller may see. The zero value
+// is unrestricted; a restricted scope only matches the employee SelfID and the
+// direct reports of ManagerID, so a restricted scope with neither set matches nothing.
+type AccessScope struct {
+	Restricted bool
+	SelfID     int64
+	ManagerID  int64
+}
+
+type EmployeeInput struct {
+	Name       string
+	Email      string
+	Department string
+	JobTitle   string
+	HireDate   string
+	Status     string
+	NationalID string
+	ManagerID  int64
+}
+
+type EmployeeUpdate struct {
+	Name       *string
+	Email      *string
+	Department *string
+	JobTitle   *string
+	HireDate   *string
+	Status     *string
+	NationalID *string
+	// ManagerID set to 0 clears the reporting line.
+	ManagerID *int64
+	// IfVersion, when set, only applies the update if the employee is still at that version.
+	IfVersion int64
 }
 
 type PerformanceReview struct {
 	ID            int64  `json:"id"`
 	EmployeeID    int64  `json:"employeeId"`
 	EmployeeName  string `json:"employeeName"`
-	Period        string `json:"period"`
+	Period        Period `json:"period"`
 	Reviewer      string `json:"reviewer"`
 	Rating        int    `json:"rating"`
 	Strengths     string `json:"strengths"`
 	Opportunities string `json:"opportunities"`
 	State         string `json:"state"`
+	Version       int64  `json:"version"`
+}
+
+// ReviewTransition is one step in a review's timeline. The first entry has an
+// empty FromState and records the review's creation.
+type ReviewTransition struct {
+	ID         int64  `json:"id"`
+	ReviewID   int64  `json:"reviewId"`
+	FromState  string `json:"fromState"`
+	ToState    string `json:"toState"`
+	ActorID    int64  `json:"actorId,omitempty"`
+	ActorEmail string `json:"actorEmail"`
+	OccurredAt string `json:"occurredAt"`
+	Comment    string `json:"comment"`
 }
 
 type ReviewEmployeeAggregate struct {
@@ -35,14 +113,17 @@ type ReviewEmployeeAggregate struct {
 }
 
 type PerformanceReviewFilter struct {
-	EmployeeID int64
-	Period     string
-	State      string
+	EmployeeIDs  []int64
+	EmployeeName string // case-insensitive substring
+	Period       Range[Period]
+	States       []string
+	Rating       Range[int]
+	Scope        AccessScope
 }
 
 type PerformanceReviewInput struct {
 	EmployeeID    int64
-	Period        string
+	Period        Period
 	Reviewer      string
 	Rating        int
 	Strengths     string
@@ -54,36 +135,90 @@ type PerformanceReviewUpdate struct {
 	Rating        *int
 	Strengths     *string
 	Opportunities *string
+	// IfVersion, when set, only applies the update if the review is still at that version.
+	IfVersion int64
 }
 
 type PayrollRecord struct {
 	ID            int64   `json:"id"`
 	EmployeeID    int64   `json:"employeeId"`
 	EmployeeName  string  `json:"employeeName"`
-	Period        string  `json:"period"`
-	BaseSalary    float64 `json:"baseSalary"`
+	Period        Period  `json:"period"`
+	BaseSalary    Money   `json:"baseSalary"`
 	OvertimeHours float64 `json:"overtimeHours"`
-	OvertimeRate  float64 `json:"overtimeRate"`
-	Bonuses       float64 `json:"bonuses"`
-	Deductions    float64 `json:"deductions"`
-	NetPay        float64 `json:"netPay"`
+	OvertimeRate  Money   `json:"overtimeRate"`
+	// Bonuses and Deductions total the earning and deduction line items.
+	Bonuses    Money             `json:"bonuses"`
+	Deductions Money             `json:"deductions"`
+	LineItems  []PayrollLineItem `json:"lineItems"`
+	NetPay     Money             `json:"netPay"`
+	Status     string            `json:"status"`
+	CreatedAt  string            `json:"createdAt"`
+	// Void and correction details link records together so every change stays traceable.
+	VoidReason    string `json:"voidReason,omitempty"`
+	VoidedAt      string `json:"voidedAt,omitempty"`
+	CorrectsID    int64  `json:"correctsId,omitempty"`
+	CorrectedByID int64  `json:"correctedById,omitempty"`
+	Version       int64  `json:"version"`
+}
+
+// PayrollLineItem is one itemized earning or deduction on a payslip, such as
+// a pension contribution or union dues. Amount is never negative; Type decides
+// whether it adds to or subtracts from net pay.
+type PayrollLineItem struct {
+	Type        string `json:"type"`
+	Code        string `json:"code"`
+	Description string `json:"description"`
+	Amount      Money  `json:"amount"`
 }
 
 type PayrollFilter struct {
-	EmployeeID int64
-	Period     string
+	EmployeeIDs   []int64
+	EmployeeName  string // case-insensitive substring
+	Period        Range[Period]
+	NetPay        Range[Money]
+	IncludeVoided bool
+	Scope         AccessScope
 }
 
 type PayrollPeriodTotal struct {
-	Period string  `json:"period"`
-	Total  float64 `json:"totalNet"`
+	Period Period `json:"period"`
+	Total  Money  `json:"totalNet"`
 }
 
 var ErrNotFound = errors.New("not found")
 var ErrInvalidTransition = errors.New("invalid transition")
+var ErrTransitionConflict = errors.New("review state changed concurrently")
+var ErrVersionMismatch = errors.New("resource was modified by someone else")
+var ErrDuplicateEmail = errors.New("email already in use")
+var ErrDuplicateNationalID = errors.New("national id already in use")
+var ErrEmployeeArchived = errors.New("employee is archived")
+var ErrEmployeeNotArchived = errors.New("employee is not archived")
+var ErrInvalidTerminationDate = errors.New("invalid terminationDate")
+var ErrPayrollVoided = errors.New("payroll record is voided")
+var ErrEmployeeNotFound = errors.New("employee not found")
+var ErrManagerNotFound = errors.New("managerId does not match an existing employee")
+var ErrInvalidManager = errors.New("an employee cannot be their own manager")
+
+// DuplicateError reports a create that collides with an existing record:
+// a review for the same employee, reviewer and period, or an active payroll
+// record for the same employee and period.
+type DuplicateError struct {
+	Entity     string
+	ExistingID int64
+}
+
+func (e *DuplicateError) Error() string {
+	if e.Entity == AuditEntityPayroll {
+		return "an active payroll record already exists for this employee and period"
+	}
+	return "a review already exists for this employee, reviewer and period"
+}
 
 type Store struct {
-	db *sql.DB
+	db       *sql.DB
+	actor    Actor
+	workflow *ReviewWorkflow
 }
 
 const (
@@ -92,12 +227,78 @@ const (
 	ReviewStateApproved  = "approved"
 )
 
+const (
+	PayrollStatusActive = "active"
+	PayrollStatusVoided = "voided"
+)
+
+const (
+	PayrollLineEarning   = "earning"
+	PayrollLineDeduction = "deduction"
+)
+
+const (
+	EmploymentStatusActive     = "active"
+	EmploymentStatusOnLeave    = "on_leave"
+	EmploymentStatusTerminated = "terminated"
+)
+
+// hireDateLayout is the calendar date format accepted for hire dates.
+const hireDateLayout = "2006-01-02"
+
 func NewStore(dsn string) (*Store, error) {
-	db, err := sql.Open("sqlite", dsn)
+	db, err := sql.Open("sqlite", withConnectionOptions(dsn))
 	if err != nil {
 		return nil, err
 	}
-	return &Store{db: db}, nil
+	return &Store{db: db, workflow: DefaultReviewWorkflow()}, nil
+}
+
+// SetReviewWorkflow replaces the default review workflow. Call it before
+// serving requests; reviews already in states the new workflow does not know
+// become final.
+func (s *Store) SetReviewWorkflow(w *ReviewWorkflow) {
+	s.workflow = w
+}
+
+// ReviewWorkflow returns the workflow reviews follow.
+func (s *Store) ReviewWorkflow() *ReviewWorkflow {
+	return s.workflow
+}
+
+// withConnectionOptions adds the options every pooled connection needs, not
+// just the first one: foreign key enforcement, a busy timeout so concurrent
+// writers wait for each other instead of failing, and BEGIN IMMEDIATE so a
+// transaction takes the write lock before its first read and never acts on
+// state another writer is about to change.
+func withConnectionOptions(dsn string) string {
+	sep := "?"
+	if strings.Contains(dsn, "?") {
+		sep = "&"
+	}
+	return dsn + sep + "_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_txlock=immediate"
+}
+
+// dbtx is satisfied by both *sql.DB and *sql.Tx, so helpers can run either
+// on their own or inside a transaction.
+type dbtx interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// inTx runs fn in a transaction and commits only if fn succeeds. Inside fn all
+// reads must go through tx: an in-memory database is private to one connection.
+func (s *Store) inTx(fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 func (s *Store) Close() error {
@@ -107,45 +308,24 @@ func (s *Store) Close() error {
 	return s.db.Close()
 }
 
-func (s *Store) Init() error {
-	_, err := s.db.Exec(`
-		CREATE TABLE IF NOT EXISTS employees (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			name TEXT NOT NULL
-		);
-
-		CREATE TABLE IF NOT EXISTS performance_reviews (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			employee_id INTEGER NOT NULL,
-			period TEXT NOT NULL,
-			reviewer TEXT NOT NULL,
-			rating INTEGER NOT NULL CHECK (rating BETWEEN 1 AND 5),
-			strengths TEXT,
-			opportunities TEXT,
-			state TEXT NOT NULL,
-			FOREIGN KEY(employee_id) REFERENCES employees(id) ON DELETE CASCADE
-		);
-		CREATE INDEX IF NOT EXISTS idx_reviews_employee_period ON performance_reviews(employee_id, period);
-
-		CREATE TABLE IF NOT EXISTS payroll_records (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			employee_id INTEGER NOT NULL,
-			period TEXT NOT NULL,
-			base_salary REAL NOT NULL,
-			overtime_hours REAL NOT NULL DEFAULT 0,
-			overtime_rate REAL NOT NULL DEFAULT 0,
-			bonuses REAL NOT NULL DEFAULT 0,
-			deductions REAL NOT NULL DEFAULT 0,
-			net_pay REAL NOT NULL,
-			FOREIGN KEY(employee_id) REFERENCES employees(id) ON DELETE CASCADE
-		);
-		CREATE INDEX IF NOT EXISTS idx_payroll_employee_period ON payroll_records(employee_id, period);
-	`)
-	return err
+const employeeColumns = `id, name, COALESCE(email, ''), department, job_title, hire_date, status, COALESCE(national_id, ''),
+	termination_date, COALESCE(archived_at, ''), COALESCE(manager_id, 0), version`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanEmployee(row rowScanner) (Employee, error) {
+	var e Employee
+	err := row.Scan(&e.ID, &e.Name, &e.Email, &e.Department, &e.JobTitle, &e.HireDate, &e.Status, &e.NationalID,
+		&e.TerminationDate, &e.ArchivedAt, &e.ManagerID, &e.Version)
+	return e, err
 }
 
-func (s *Store) ListEmployees() ([]Employee, error) {
-	rows, err := s.db.Query("SELECT id, name FROM employees ORDER BY id ASC")
+// ListEmployees returns active employees; offboarded ones are only included on request.
+func (s *Store) ListEmployees(filter EmployeeFilter) ([]Employee, error) {
+	w := buildEmployeeFilter(filter)
+	rows, err := s.db.Query("SELECT "+employeeColumns+" FROM employees"+w.String()+" ORDER BY id ASC", w.args...)
 	if err != nil {
 		return nil, err
 	}
@@ -154,8 +334,8 @@ func (s *Store) ListEmployees() ([]Employee, error) {
 	// Ensure non-nil slice so JSON encodes as [] instead of null
 	result := make([]Employee, 0)
 	for rows.Next() {
-		var e Employee
-		if err := rows.Scan(&e.ID, &e.Name); err != nil {
+		e, err := scanEmployee(rows)
+		if err != nil {
 			return nil, err
 		}
 		result = append(result, e)
@@ -163,69 +343,598 @@ func (s *Store) ListEmployees() ([]Employee, error) {
 	return result, rows.Err()
 }
 
-func (s *Store) CreateEmployee(name string) (Employee, error) {
-	res, err := s.db.Exec("INSERT INTO employees(name) VALUES(?)", name)
-	if err != nil {
+// employeeSorts are the orders GET /employees accepts; a leading "-" sorts descending.
+var employeeSorts = map[string]sortKey[Employee]{
+	"id":        {column: "id", value: func(e Employee) any { return e.ID }},
+	"-id":       {column: "id", desc: true, value: func(e Employee) any { return e.ID }},
+	"name":      {column: "name", value: func(e Employee) any { return e.Name }},
+	"-name":     {column: "name", desc: true, value: func(e Employee) any { return e.Name }},
+	"hireDate":  {column: "hire_date", value: func(e Employee) any { return e.HireDate }},
+	"-hireDate": {column: "hire_date", desc: true, value: func(e Employee) any { return e.HireDate }},
+}
+
+// ListEmployeesPage is ListEmployees cut into pages.
+func (s *Store) ListEmployeesPage(filter EmployeeFilter, req PageRequest) (Page[Employee], error) {
+	q := pageQuery[Employee]{
+		selectSQL:   "SELECT " + employeeColumns + " FROM employees",
+		idColumn:    "id",
+		sorts:       employeeSorts,
+		defaultSort: "id",
+		id:          func(e Employee) int64 { return e.ID },
+		scan:        scanEmployee,
+	}
+	w := buildEmployeeFilter(filter)
+	q.where, q.args = w.String(), w.args
+	return queryPage(s.db, q, req)
+}
+
+func buildEmployeeFilter(filter EmployeeFilter) where {
+	var w where
+	in(&w, "id", filter.IDs)
+	w.contains("name", filter.Name)
+	if q := employeeSearchQuery(filter.Search); q != "" {
+		w.add("id IN (SELECT rowid FROM employees_fts WHERE employees_fts MATCH ?)", q)
+	}
+	in(&w, "department COLLATE NOCASE", filter.Departments)
+	w.contains("job_title", filter.JobTitle)
+	in(&w, "status", filter.Statuses)
+	in(&w, "manager_id", filter.ManagerIDs)
+	filter.HireDate.apply(&w, "hire_date")
+	if !filter.IncludeArchived {
+		w.add("archived_at IS NULL")
+	}
+	if filter.Scope.Restricted {
+		w.add("(id = ? OR manager_id = ?)", filter.Scope.SelfID, filter.Scope.ManagerID)
+	}
+	return w
+}
+
+// employeeSearchQuery turns free text into an FTS5 query where every word must
+// start a word of the name. Only letters and digits are kept, so FTS syntax in
+// the text is never interpreted.
+func employeeSearchQuery(text string) string {
+	words := strings.FieldsFunc(text, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+	terms := make([]string, 0, len(words))
+	for _, word := range words {
+		terms = append(terms, `"`+word+`"*`)
+	}
+	return strings.Join(terms, " ")
+}
+
+func (s *Store) CreateEmployee(input EmployeeInput) (Employee, error) {
+	input = normalizeEmployeeInput(input)
+	if err := validateEmployeeInput(input); err != nil {
 		return Employee{}, err
 	}
-	id, err := res.LastInsertId()
+	var created Employee
+	err := s.inTx(func(tx *sql.Tx) error {
+		res, err := tx.Exec(`INSERT INTO employees
+			(name, email, department, job_title, hire_date, status, national_id, manager_id)
+			VALUES(?, ?, ?, ?, ?, ?, ?, ?)`,
+			input.Name, nullIfEmpty(input.Email), input.Department, input.JobTitle, input.HireDate, input.Status, nullIfEmpty(input.NationalID),
+			nullIfZero(input.ManagerID))
+		if err != nil {
+			return mapEmployeeConstraintError(err)
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+		if created, err = getEmployeeByID(tx, id); err != nil {
+			return err
+		}
+		return s.audit(tx, AuditEntityEmployee, id, AuditActionCreate, nil, created)
+	})
 	if err != nil {
 		return Employee{}, err
 	}
-	return Employee{ID: id, Name: name}, nil
+	return created, nil
+}
+
+var allowedEmployeeUpdateClauses = map[string]struct{}{
+	"name = ?":        {},
+	"email = ?":       {},
+	"department = ?":  {},
+	"job_title = ?":   {},
+	"hire_date = ?":   {},
+	"status = ?":      {},
+	"national_id = ?": {},
+	"manager_id = ?":  {},
 }
 
-func (s *Store) UpdateEmployee(id int64, name string) (Employee, error) {
-	res, err := s.db.Exec("UPDATE employees SET name=? WHERE id=?", name, id)
+func (s *Store) UpdateEmployee(id int64, update EmployeeUpdate) (Employee, error) {
+	update = normalizeEmployeeUpdate(update)
+	if err := validateEmployeeUpdate(update); err != nil {
+		return Employee{}, err
+	}
+	if update.ManagerID != nil && *update.ManagerID == id {
+		return Employee{}, ErrInvalidManager
+	}
+	setClauses := make([]string, 0)
+	args := make([]any, 0)
+	if update.Name != nil {
+		setClauses = append(setClauses, "name = ?")
+		args = append(args, *update.Name)
+	}
+	if update.Email != nil {
+		setClauses = append(setClauses, "email = ?")
+		args = append(args, nullIfEmpty(*update.Email))
+	}
+	if update.Department != nil {
+		setClauses = append(setClauses, "department = ?")
+		args = append(args, *update.Department)
+	}
+	if update.JobTitle != nil {
+		setClauses = append(setClauses, "job_title = ?")
+		args = append(args, *update.JobTitle)
+	}
+	if update.HireDate != nil {
+		setClauses = append(setClauses, "hire_date = ?")
+		args = append(args, *update.HireDate)
+	}
+	if update.Status != nil {
+		setClauses = append(setClauses, "status = ?")
+		args = append(args, *update.Status)
+	}
+	if update.NationalID != nil {
+		setClauses = append(setClauses, "national_id = ?")
+		args = append(args, nullIfEmpty(*update.NationalID))
+	}
+	if update.ManagerID != nil {
+		setClauses = append(setClauses, "manager_id = ?")
+		args = append(args, nullIfZero(*update.ManagerID))
+	}
+	if len(setClauses) == 0 {
+		current, err := getEmployeeByID(s.db, id)
+		if err == nil && update.IfVersion != 0 && current.Version != update.IfVersion {
+			return Employee{}, ErrVersionMismatch
+		}
+		return current, err
+	}
+	setClauseString, err := joinAllowedClauses(setClauses, allowedEmployeeUpdateClauses, ", ")
 	if err != nil {
 		return Employee{}, err
 	}
-	affected, err := res.RowsAffected()
+	where := "id = ?"
+	args = append(args, id)
+	if update.IfVersion != 0 {
+		where += " AND version = ?"
+		args = append(args, update.IfVersion)
+	}
+	var updated Employee
+	err = s.inTx(func(tx *sql.Tx) error {
+		before, err := getEmployeeByID(tx, id)
+		if err != nil {
+			return err
+		}
+		// Archived employees are read-only until restored.
+		if before.ArchivedAt != "" {
+			return ErrEmployeeArchived
+		}
+		res, err := tx.Exec(`UPDATE employees SET `+setClauseString+`, version = version + 1 WHERE `+where, args...)
+		if err != nil {
+			return mapEmployeeConstraintError(err)
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			return ErrVersionMismatch
+		}
+		if updated, err = getEmployeeByID(tx, id); err != nil {
+			return err
+		}
+		return s.audit(tx, AuditEntityEmployee, id, AuditActionUpdate, before, updated)
+	})
 	if err != nil {
 		return Employee{}, err
 	}
-	if affected == 0 {
-		return Employee{}, ErrNotFound
+	return updated, nil
+}
+
+// OffboardEmployee terminates and archives an employee. Reviews and payroll
+// records are kept untouched so the history stays available.
+func (s *Store) OffboardEmployee(id int64, terminationDate string) (Employee, error) {
+	terminationDate = strings.TrimSpace(terminationDate)
+	if terminationDate == "" {
+		terminationDate = time.Now().UTC().Format(hireDateLayout)
+	}
+	if _, err := time.Parse(hireDateLayout, terminationDate); err != nil {
+		return Employee{}, fmt.Errorf("%w: must be a date in YYYY-MM-DD format", ErrInvalidTerminationDate)
+	}
+	var offboarded Employee
+	err := s.inTx(func(tx *sql.Tx) error {
+		current, err := getEmployeeByID(tx, id)
+		if err != nil {
+			return err
+		}
+		if current.ArchivedAt != "" {
+			return ErrEmployeeArchived
+		}
+		// Both values are YYYY-MM-DD so they compare correctly as strings.
+		if current.HireDate != "" && terminationDate < current.HireDate {
+			return fmt.Errorf("%w: must not be before hireDate", ErrInvalidTerminationDate)
+		}
+		if _, err := tx.Exec(`UPDATE employees SET status = ?, termination_date = ?, archived_at = ?, version = version + 1 WHERE id = ?`,
+			EmploymentStatusTerminated, terminationDate, time.Now().UTC().Format(time.RFC3339), id); err != nil {
+			return err
+		}
+		if offboarded, err = getEmployeeByID(tx, id); err != nil {
+			return err
+		}
+		return s.audit(tx, AuditEntityEmployee, id, AuditActionOffboard, current, offboarded)
+	})
+	if err != nil {
+		return Employee{}, err
+	}
+	return offboarded, nil
+}
+
+// RestoreEmployee reverts an offboarding and makes the employee active again.
+func (s *Store) RestoreEmployee(id int64) (Employee, error) {
+	var restored Employee
+	err := s.inTx(func(tx *sql.Tx) error {
+		before, err := getEmployeeByID(tx, id)
+		if err != nil {
+			return err
+		}
+		if before.ArchivedAt == "" {
+			return ErrEmployeeNotArchived
+		}
+		if _, err := tx.Exec(`UPDATE employees SET status = ?, termination_date = '', archived_at = NULL, version = version + 1 WHERE id = ?`,
+			EmploymentStatusActive, id); err != nil {
+			return err
+		}
+		if restored, err = getEmployeeByID(tx, id); err != nil {
+			return err
+		}
+		return s.audit(tx, AuditEntityEmployee, id, AuditActionRestore, before, restored)
+	})
+	if err != nil {
+		return Employee{}, err
 	}
-	return Employee{ID: id, Name: name}, nil
+	return restored, nil
+}
+
+// PurgeEmployee permanently deletes an archived employee together with their
+// reviews and payroll records. It is meant for explicit admin use only. Every
+// deleted row is written to the audit log with its last known contents, and
+// reports and user accounts that pointed at the employee are unlinked first.
+func (s *Store) PurgeEmployee(id int64) error {
+	return s.inTx(func(tx *sql.Tx) error {
+		employee, err := getEmployeeByID(tx, id)
+		if err != nil {
+			return err
+		}
+		if employee.ArchivedAt == "" {
+			return ErrEmployeeNotArchived
+		}
+		reviews, err := queryPerformanceReviews(tx, reviewSelect+" WHERE r.employee_id = ?", id)
+		if err != nil {
+			return err
+		}
+		payroll, err := queryPayrollRecords(tx, payrollSelect+" WHERE p.employee_id = ?", id)
+		if err != nil {
+			return err
+		}
+		if err := s.unlinkEmployee(tx, id); err != nil {
+			return err
+		}
+		for _, stmt := range []string{
+			"DELETE FROM performance_reviews WHERE employee_id = ?",
+			"DELETE FROM payroll_records WHERE employee_id = ?",
+			"DELETE FROM employees WHERE id = ?",
+		} {
+			if _, err := tx.Exec(stmt, id); err != nil {
+				return err
+			}
+		}
+
+		for _, review := range reviews {
+			if err := s.audit(tx, AuditEntityReview, review.ID, AuditActionDelete, review, nil); err != nil {
+				return err
+			}
+		}
+		for _, record := range payroll {
+			if err := s.audit(tx, AuditEntityPayroll, record.ID, AuditActionDelete, record, nil); err != nil {
+				return err
+			}
+		}
+		return s.audit(tx, AuditEntityEmployee, id, AuditActionPurge, employee, nil)
+	})
 }
 
-func (s *Store) DeleteEmployee(id int64) error {
-	res, err := s.db.Exec("DELETE FROM employees WHERE id=?", id)
+// unlinkEmployee clears the manager of the employee's reports and the
+// employee of linked user accounts. The foreign keys would do the same on
+// delete, but without the version bump and audit entry any other change gets.
+func (s *Store) unlinkEmployee(tx *sql.Tx, id int64) error {
+	reportIDs, err := queryIDs(tx, "SELECT id FROM employees WHERE manager_id = ?", id)
 	if err != nil {
 		return err
 	}
-	affected, err := res.RowsAffected()
+	for _, reportID := range reportIDs {
+		before, err := getEmployeeByID(tx, reportID)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`UPDATE employees SET manager_id = NULL, version = version + 1 WHERE id = ?`, reportID); err != nil {
+			return err
+		}
+		after, err := getEmployeeByID(tx, reportID)
+		if err != nil {
+			return err
+		}
+		if err := s.audit(tx, AuditEntityEmployee, reportID, AuditActionUpdate, before, after); err != nil {
+			return err
+		}
+	}
+	userIDs, err := queryIDs(tx, "SELECT id FROM users WHERE employee_id = ?", id)
 	if err != nil {
 		return err
 	}
-	if affected == 0 {
-		return ErrNotFound
+	for _, userID := range userIDs {
+		before, err := getUserByID(tx, userID)
+		if err != nil {
+			return err
+		}
+		// Users carry no version column.
+		if _, err := tx.Exec(`UPDATE users SET employee_id = NULL WHERE id = ?`, userID); err != nil {
+			return err
+		}
+		after, err := getUserByID(tx, userID)
+		if err != nil {
+			return err
+		}
+		if err := s.audit(tx, AuditEntityUser, userID, AuditActionUpdate, before, after); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
-// Performance Reviews
+// EmployeeInScope reports whether the employee is visible within the scope.
+func (s *Store) EmployeeInScope(id int64, scope AccessScope) (bool, error) {
+	if !scope.Restricted {
+		return true, nil
+	}
+	var count int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM employees WHERE id = ? AND (id = ? OR manager_id = ?)",
+		id, scope.SelfID, scope.ManagerID).Scan(&count)
+	return count > 0, err
+}
 
-func (s *Store) ListPerformanceReviews(filter PerformanceReviewFilter) ([]PerformanceReview, error) {
-	builder := strings.Builder{}
-	builder.WriteString(`SELECT r.id, r.employee_id, e.name, r.period, r.reviewer, r.rating, r.strengths, r.opportunities, r.state
-		FROM performance_reviews r
-		JOIN employees e ON e.id = r.employee_id`)
-	args := make([]any, 0)
-	whereClauses, wArgs := buildReviewFilter(filter)
-	if len(whereClauses) > 0 {
-		where, err := joinAllowedClauses(whereClauses, allowedReviewFilterClauses, " AND ")
-		if err != nil {
-			return nil, err
+// GetEmployee returns one employee, including archived ones.
+func (s *Store) GetEmployee(id int64) (Employee, error) {
+	return getEmployeeByID(s.db, id)
+}
+
+func getEmployeeByID(db dbtx, id int64) (Employee, error) {
+	e, err := scanEmployee(db.QueryRow("SELECT "+employeeColumns+" FROM employees WHERE id = ?", id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Employee{}, ErrNotFound
+		}
+		return Employee{}, err
+	}
+	return e, nil
+}
+
+// mapEmployeeConstraintError translates unique index and manager foreign key
+// violations into typed errors.
+func mapEmployeeConstraintError(err error) error {
+	msg := err.Error()
+	if strings.Contains(msg, "FOREIGN KEY constraint failed") {
+		return ErrManagerNotFound
+	}
+	if !strings.Contains(msg, "UNIQUE constraint failed") {
+		return err
+	}
+	switch {
+	case strings.Contains(msg, "employees.email"):
+		return ErrDuplicateEmail
+	case strings.Contains(msg, "employees.national_id"):
+		return ErrDuplicateNationalID
+	default:
+		return err
+	}
+}
+
+// mapForeignKeyError reports inserts that reference a missing employee. Reviews
+// and payroll records only have foreign keys that can fail on employee_id.
+func mapForeignKeyError(err error) error {
+	if strings.Contains(err.Error(), "FOREIGN KEY constraint failed") {
+		return ErrEmployeeNotFound
+	}
+	return err
+}
+
+func isUniqueViolation(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "UNIQUE constraint failed")
+}
+
+// duplicateReview looks up the review a failed insert or update collided with.
+// The lookup runs on the same transaction, which a failed statement leaves
+// usable.
+func duplicateReview(db dbtx, input PerformanceReviewInput) error {
+	var id int64
+	err := db.QueryRow(`SELECT id FROM performance_reviews WHERE employee_id = ? AND period = ? AND reviewer = ? COLLATE NOCASE`,
+		input.EmployeeID, input.Period, input.Reviewer).Scan(&id)
+	if err != nil {
+		return err
+	}
+	return &DuplicateError{Entity: AuditEntityReview, ExistingID: id}
+}
+
+func duplicatePayroll(db dbtx, input PayrollRecordInput) error {
+	var id int64
+	err := db.QueryRow(`SELECT id FROM payroll_records WHERE employee_id = ? AND period = ? AND status = ?`,
+		input.EmployeeID, input.Period, PayrollStatusActive).Scan(&id)
+	if err != nil {
+		return err
+	}
+	return &DuplicateError{Entity: AuditEntityPayroll, ExistingID: id}
+}
+
+func nullIfEmpty(v string) any {
+	if v == "" {
+		return nil
+	}
+	return v
+}
+
+func nullIfZero(v int64) any {
+	if v == 0 {
+		return nil
+	}
+	return v
+}
+
+// placeholders returns n comma-separated "?" for an IN list.
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+func normalizeEmployeeInput(input EmployeeInput) EmployeeInput {
+	input.Name = strings.TrimSpace(input.Name)
+	input.Email = normalizeEmail(input.Email)
+	input.Department = strings.TrimSpace(input.Department)
+	input.JobTitle = strings.TrimSpace(input.JobTitle)
+	input.HireDate = strings.TrimSpace(input.HireDate)
+	input.Status = strings.TrimSpace(input.Status)
+	if input.Status == "" {
+		input.Status = EmploymentStatusActive
+	}
+	input.NationalID = normalizeNationalID(input.NationalID)
+	return input
+}
+
+func normalizeEmployeeUpdate(update EmployeeUpdate) EmployeeUpdate {
+	trim := func(v *string, fn func(string) string) *string {
+		if v == nil {
+			return nil
 		}
-		builder.WriteString(" WHERE ")
-		builder.WriteString(where)
-		args = append(args, wArgs...)
+		out := fn(*v)
+		return &out
+	}
+	update.Name = trim(update.Name, strings.TrimSpace)
+	update.Email = trim(update.Email, normalizeEmail)
+	update.Department = trim(update.Department, strings.TrimSpace)
+	update.JobTitle = trim(update.JobTitle, strings.TrimSpace)
+	update.HireDate = trim(update.HireDate, strings.TrimSpace)
+	update.Status = trim(update.Status, strings.TrimSpace)
+	update.NationalID = trim(update.NationalID, normalizeNationalID)
+	return update
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// normalizeNationalID strips the separators people usually type (spaces, dots,
+// dashes) so "20-12.345.678-9" and "20123456789" are treated as the same ID.
+func normalizeNationalID(id string) string {
+	return strings.ToUpper(strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '.', '-':
+			return -1
+		}
+		return r
+	}, id))
+}
+
+func validateEmployeeInput(input EmployeeInput) error {
+	var v ValidationError
+	if input.Name == "" {
+		v.add("name", FieldRequired, "name is required")
 	}
-	builder.WriteString(" ORDER BY r.id DESC")
+	if input.ManagerID < 0 {
+		v.add("managerId", FieldOutOfRange, "managerId is invalid")
+	}
+	validateEmployeeProfile(&v, input.Email, input.HireDate, input.Status, input.NationalID)
+	return v.orNil()
+}
+
+func validateEmployeeUpdate(update EmployeeUpdate) error {
+	var v ValidationError
+	if update.Name != nil && *update.Name == "" {
+		v.add("name", FieldRequired, "name is required")
+	}
+	if update.Status != nil && *update.Status == "" {
+		v.add("status", FieldRequired, "status is required")
+	}
+	if update.ManagerID != nil && *update.ManagerID < 0 {
+		v.add("managerId", FieldOutOfRange, "managerId is invalid")
+	}
+	deref := func(v *string) string {
+		if v == nil {
+			return ""
+		}
+		return *v
+	}
+	status := deref(update.Status)
+	if status == "" {
+		status = EmploymentStatusActive
+	}
+	validateEmployeeProfile(&v, deref(update.Email), deref(update.HireDate), status, deref(update.NationalID))
+	return v.orNil()
+}
+
+// validateEmployeeProfile checks the optional profile fields; empty values are allowed.
+func validateEmployeeProfile(v *ValidationError, email, hireDate, status, nationalID string) {
+	if email != "" {
+		addr, err := mail.ParseAddress(email)
+		if err != nil || addr.Address != email {
+			v.add("email", FieldInvalidFormat, "email is invalid")
+		}
+	}
+	if hireDate != "" {
+		if _, err := time.Parse(hireDateLayout, hireDate); err != nil {
+			v.add("hireDate", FieldInvalidFormat, "hireDate must be a date in YYYY-MM-DD format")
+		}
+	}
+	if !isValidEmploymentStatus(status) {
+		v.add("status", FieldInvalidChoice, fmt.Sprintf("status must be one of %s", strings.Join(employmentStatuses, ", ")))
+	}
+	if nationalID != "" {
+		if len(nationalID) < 5 || len(nationalID) > 20 {
+			v.add("nationalId", FieldOutOfRange, "nationalId must be between 5 and 20 characters")
+		} else {
+			for _, r := range nationalID {
+				if (r < '0' || r > '9') && (r < 'A' || r > 'Z') {
+					v.add("nationalId", FieldInvalidFormat, "nationalId may only contain letters and digits")
+					break
+				}
+			}
+		}
+	}
+}
+
+// employmentStatuses lists the statuses that can be set directly; terminated is
+// only reachable through OffboardEmployee.
+var employmentStatuses = []string{EmploymentStatusActive, EmploymentStatusOnLeave}
+
+func isValidEmploymentStatus(status string) bool {
+	for _, s := range employmentStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// Performance Reviews
+
+const reviewSelect = `SELECT r.id, r.employee_id, e.name, r.period, r.reviewer, r.rating, r.strengths, r.opportunities, r.state, r.version
+		FROM performance_reviews r
+		JOIN employees e ON e.id = r.employee_id`
+
+func scanPerformanceReview(row rowScanner) (PerformanceReview, error) {
+	var pr PerformanceReview
+	err := row.Scan(&pr.ID, &pr.EmployeeID, &pr.EmployeeName, &pr.Period, &pr.Reviewer, &pr.Rating, &pr.Strengths, &pr.Opportunities, &pr.State, &pr.Version)
+	return pr, err
+}
 
-	rows, err := s.db.Query(builder.String(), args...)
+func queryPerformanceReviews(db dbtx, query string, args ...any) ([]PerformanceReview, error) {
+	rows, err := db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -233,8 +942,8 @@ func (s *Store) ListPerformanceReviews(filter PerformanceReviewFilter) ([]Perfor
 
 	result := make([]PerformanceReview, 0)
 	for rows.Next() {
-		var pr PerformanceReview
-		if err := rows.Scan(&pr.ID, &pr.EmployeeID, &pr.EmployeeName, &pr.Period, &pr.Reviewer, &pr.Rating, &pr.Strengths, &pr.Opportunities, &pr.State); err != nil {
+		pr, err := scanPerformanceReview(rows)
+		if err != nil {
 			return nil, err
 		}
 		result = append(result, pr)
@@ -242,71 +951,120 @@ func (s *Store) ListPerformanceReviews(filter PerformanceReviewFilter) ([]Perfor
 	return result, rows.Err()
 }
 
-var (
-	allowedReviewFilterClauses = map[string]struct{}{
-		"r.employee_id = ?": {},
-		"r.period = ?":      {},
-		"r.state = ?":       {},
-	}
-	allowedReviewUpdateClauses = map[string]struct{}{
-		"reviewer = ?":      {},
-		"rating = ?":        {},
-		"strengths = ?":     {},
-		"opportunities = ?": {},
-	}
-	allowedPayrollFilterClauses = map[string]struct{}{
-		"p.employee_id = ?": {},
-		"p.period = ?":      {},
-	}
+func (s *Store) ListPerformanceReviews(filter PerformanceReviewFilter) ([]PerformanceReview, error) {
+	w := buildReviewFilter(filter)
+	return queryPerformanceReviews(s.db, reviewSelect+w.String()+" ORDER BY r.id DESC", w.args...)
+}
+
+// Periods sort by their first and then their last month; see Period.sortKey.
+const (
+	reviewPeriodSort  column = "(r.period_start || r.period_end)"
+	payrollPeriodSort column = "(p.period_start || p.period_end)"
 )
 
-func buildReviewFilter(filter PerformanceReviewFilter) ([]string, []any) {
-	clauses := make([]string, 0)
-	args := make([]any, 0)
-	if filter.EmployeeID > 0 {
-		clauses = append(clauses, "r.employee_id = ?")
-		args = append(args, filter.EmployeeID)
-	}
-	if filter.Period != "" {
-		clauses = append(clauses, "r.period = ?")
-		args = append(args, filter.Period)
-	}
-	if filter.State != "" {
-		clauses = append(clauses, "r.state = ?")
-		args = append(args, filter.State)
-	}
-	return clauses, args
+var reviewSorts = map[string]sortKey[PerformanceReview]{
+	"id":      {column: "r.id", value: func(r PerformanceReview) any { return r.ID }},
+	"-id":     {column: "r.id", desc: true, value: func(r PerformanceReview) any { return r.ID }},
+	"period":  {column: reviewPeriodSort, value: func(r PerformanceReview) any { return r.Period.sortKey() }},
+	"-period": {column: reviewPeriodSort, desc: true, value: func(r PerformanceReview) any { return r.Period.sortKey() }},
+	"rating":  {column: "r.rating", value: func(r PerformanceReview) any { return int64(r.Rating) }},
+	"-rating": {column: "r.rating", desc: true, value: func(r PerformanceReview) any { return int64(r.Rating) }},
+}
+
+// ListPerformanceReviewsPage is ListPerformanceReviews cut into pages.
+func (s *Store) ListPerformanceReviewsPage(filter PerformanceReviewFilter, req PageRequest) (Page[PerformanceReview], error) {
+	q := pageQuery[PerformanceReview]{
+		selectSQL:   reviewSelect,
+		idColumn:    "r.id",
+		sorts:       reviewSorts,
+		defaultSort: "-id",
+		id:          func(r PerformanceReview) int64 { return r.ID },
+		scan:        scanPerformanceReview,
+	}
+	w := buildReviewFilter(filter)
+	q.where, q.args = w.String(), w.args
+	return queryPage(s.db, q, req)
+}
+
+var allowedReviewUpdateClauses = map[string]struct{}{
+	"reviewer = ?":      {},
+	"rating = ?":        {},
+	"strengths = ?":     {},
+	"opportunities = ?": {},
+}
+
+// The scope clauses keep rows whose employee is the caller or one of their reports.
+const (
+	reviewScopeClause  = "r.employee_id IN (SELECT id FROM employees WHERE id = ? OR manager_id = ?)"
+	payrollScopeClause = "p.employee_id IN (SELECT id FROM employees WHERE id = ? OR manager_id = ?)"
+)
+
+func buildReviewFilter(filter PerformanceReviewFilter) where {
+	var w where
+	in(&w, "r.employee_id", filter.EmployeeIDs)
+	w.contains("e.name", filter.EmployeeName)
+	periodWithin(&w, filter.Period, "r.period_start", "r.period_end")
+	in(&w, "r.state", filter.States)
+	filter.Rating.apply(&w, "r.rating")
+	if filter.Scope.Restricted {
+		w.add(reviewScopeClause, filter.Scope.SelfID, filter.Scope.ManagerID)
+	}
+	return w
 }
 
 func (s *Store) CreatePerformanceReview(input PerformanceReviewInput) (PerformanceReview, error) {
 	if err := validateReviewInput(input); err != nil {
 		return PerformanceReview{}, err
 	}
-	res, err := s.db.Exec(`INSERT INTO performance_reviews
-		(employee_id, period, reviewer, rating, strengths, opportunities, state)
-		VALUES(?, ?, ?, ?, ?, ?, ?)`,
-		input.EmployeeID, input.Period, input.Reviewer, input.Rating, input.Strengths, input.Opportunities, ReviewStateDraft)
-	if err != nil {
-		return PerformanceReview{}, err
-	}
-	id, err := res.LastInsertId()
+	var created PerformanceReview
+	start, end := input.Period.bounds()
+	err := s.inTx(func(tx *sql.Tx) error {
+		res, err := tx.Exec(`INSERT INTO performance_reviews
+			(employee_id, period, period_start, period_end, reviewer, rating, strengths, opportunities, state)
+			VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			input.EmployeeID, input.Period, start, end, input.Reviewer, input.Rating, input.Strengths, input.Opportunities, s.workflow.Initial())
+		if isUniqueViolation(err) {
+			return duplicateReview(tx, input)
+		}
+		if err != nil {
+			return mapForeignKeyError(err)
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+		if created, err = getPerformanceReviewByID(tx, id); err != nil {
+			return err
+		}
+		if err := s.recordReviewTransition(tx, id, "", s.workflow.Initial(), ""); err != nil {
+			return err
+		}
+		return s.audit(tx, AuditEntityReview, id, AuditActionCreate, nil, created)
+	})
 	if err != nil {
 		return PerformanceReview{}, err
 	}
-	return s.getPerformanceReviewByID(id)
+	return created, nil
 }
 
+// UpdatePerformanceReview applies the update if the review's state allows it.
+// The state check is part of the UPDATE itself, so a concurrent transition
+// cannot slip in between the check and the write.
 func (s *Store) UpdatePerformanceReview(id int64, update PerformanceReviewUpdate) (PerformanceReview, error) {
 	setClauses := make([]string, 0)
 	args := make([]any, 0)
+	scope := EditComments
+	if update.Reviewer != nil || update.Rating != nil {
+		scope = EditAll
+	}
+	if err := validateReviewUpdate(update); err != nil {
+		return PerformanceReview{}, err
+	}
 	if update.Reviewer != nil {
 		setClauses = append(setClauses, "reviewer = ?")
 		args = append(args, strings.TrimSpace(*update.Reviewer))
 	}
 	if update.Rating != nil {
-		if err := validateRating(*update.Rating); err != nil {
-			return PerformanceReview{}, err
-		}
 		setClauses = append(setClauses, "rating = ?")
 		args = append(args, *update.Rating)
 	}
@@ -319,50 +1077,142 @@ func (s *Store) UpdatePerformanceReview(id int64, update PerformanceReviewUpdate
 		args = append(args, strings.TrimSpace(*update.Opportunities))
 	}
 	if len(setClauses) == 0 {
-		return s.getPerformanceReviewByID(id)
+		current, err := getPerformanceReviewByID(s.db, id)
+		if err == nil && update.IfVersion != 0 && current.Version != update.IfVersion {
+			return PerformanceReview{}, ErrVersionMismatch
+		}
+		return current, err
 	}
 	setClauseString, err := joinAllowedClauses(setClauses, allowedReviewUpdateClauses, ", ")
 	if err != nil {
 		return PerformanceReview{}, err
 	}
+	states := s.workflow.StatesAllowing(scope)
+	where := "id = ? AND state IN (" + placeholders(len(states)) + ")"
 	args = append(args, id)
-	res, err := s.db.Exec(`UPDATE performance_reviews SET `+setClauseString+` WHERE id = ?`, args...)
-	if err != nil {
-		return PerformanceReview{}, err
+	for _, state := range states {
+		args = append(args, state)
+	}
+	if update.IfVersion != 0 {
+		where += " AND version = ?"
+		args = append(args, update.IfVersion)
 	}
-	cnt, err := res.RowsAffected()
+	var updated PerformanceReview
+	err = s.inTx(func(tx *sql.Tx) error {
+		before, err := getPerformanceReviewByID(tx, id)
+		if err != nil {
+			return err
+		}
+		res, err := tx.Exec(`UPDATE performance_reviews SET `+setClauseString+`, version = version + 1 WHERE `+where, args...)
+		if isUniqueViolation(err) {
+			return duplicateReview(tx, PerformanceReviewInput{EmployeeID: before.EmployeeID, Period: before.Period, Reviewer: strings.TrimSpace(*update.Reviewer)})
+		}
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			current, err := getPerformanceReviewByID(tx, id)
+			if err != nil {
+				return err
+			}
+			if update.IfVersion != 0 && current.Version != update.IfVersion {
+				return ErrVersionMismatch
+			}
+			return s.workflow.editError(current.State, scope)
+		}
+		if updated, err = getPerformanceReviewByID(tx, id); err != nil {
+			return err
+		}
+		return s.audit(tx, AuditEntityReview, id, AuditActionUpdate, before, updated)
+	})
 	if err != nil {
 		return PerformanceReview{}, err
 	}
-	if cnt == 0 {
-		return PerformanceReview{}, ErrNotFound
-	}
-	return s.getPerformanceReviewByID(id)
+	return updated, nil
 }
 
-func (s *Store) TransitionPerformanceReview(id int64, nextState string) (PerformanceReview, error) {
-	review, err := s.getPerformanceReviewByID(id)
+// TransitionPerformanceReview moves a review to nextState if the workflow allows
+// it and appends the step, with its comment, to the review's history. The
+// comment doubles as the reason for transitions that require one. A non-zero
+// ifVersion makes the transition fail with ErrVersionMismatch once the review
+// has changed since the caller read it.
+func (s *Store) TransitionPerformanceReview(id int64, nextState, comment string, ifVersion int64) (PerformanceReview, error) {
+	comment = strings.TrimSpace(comment)
+	var updated PerformanceReview
+	err := s.inTx(func(tx *sql.Tx) error {
+		review, err := getPerformanceReviewByID(tx, id)
+		if err != nil {
+			return err
+		}
+		if ifVersion != 0 && review.Version != ifVersion {
+			return ErrVersionMismatch
+		}
+		if err := s.workflow.Check(review.State, nextState, comment); err != nil {
+			return err
+		}
+		// Compare-and-set: the update only applies if nobody moved the review since we read it.
+		res, err := tx.Exec(`UPDATE performance_reviews SET state = ?, version = version + 1 WHERE id = ? AND state = ?`, nextState, id, review.State)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			return ErrTransitionConflict
+		}
+		if updated, err = getPerformanceReviewByID(tx, id); err != nil {
+			return err
+		}
+		if err := s.recordReviewTransition(tx, id, review.State, nextState, comment); err != nil {
+			return err
+		}
+		return s.audit(tx, AuditEntityReview, id, AuditActionTransition, review, updated)
+	})
 	if err != nil {
 		return PerformanceReview{}, err
 	}
-	valid := isValidTransition(review.State, nextState)
-	if !valid {
-		return PerformanceReview{}, ErrInvalidTransition
+	return updated, nil
+}
+
+func (s *Store) recordReviewTransition(tx dbtx, reviewID int64, from, to, comment string) error {
+	_, err := tx.Exec(`INSERT INTO review_transitions(review_id, from_state, to_state, actor_user_id, actor_email, occurred_at, comment)
+		VALUES(?, ?, ?, ?, ?, ?, ?)`,
+		reviewID, from, to, nullIfZero(s.actor.UserID), s.actor.label(), time.Now().UTC().Format(time.RFC3339), comment)
+	return err
+}
+
+// ListReviewTransitions returns the review's timeline, oldest step first.
+func (s *Store) ListReviewTransitions(reviewID int64) ([]ReviewTransition, error) {
+	if _, err := getPerformanceReviewByID(s.db, reviewID); err != nil {
+		return nil, err
 	}
-	_, err = s.db.Exec(`UPDATE performance_reviews SET state = ? WHERE id = ?`, nextState, id)
+	rows, err := s.db.Query(`SELECT id, review_id, from_state, to_state, COALESCE(actor_user_id, 0), actor_email, occurred_at, comment
+		FROM review_transitions WHERE review_id = ? ORDER BY id ASC`, reviewID)
 	if err != nil {
-		return PerformanceReview{}, err
+		return nil, err
 	}
-	return s.getPerformanceReviewByID(id)
+	defer rows.Close()
+
+	result := make([]ReviewTransition, 0)
+	for rows.Next() {
+		var t ReviewTransition
+		if err := rows.Scan(&t.ID, &t.ReviewID, &t.FromState, &t.ToState, &t.ActorID, &t.ActorEmail, &t.OccurredAt, &t.Comment); err != nil {
+			return nil, err
+		}
+		result = append(result, t)
+	}
+	return result, rows.Err()
 }
 
-func (s *Store) getPerformanceReviewByID(id int64) (PerformanceReview, error) {
-	row := s.db.QueryRow(`SELECT r.id, r.employee_id, e.name, r.period, r.reviewer, r.rating, r.strengths, r.opportunities, r.state
-		FROM performance_reviews r
-		JOIN employees e ON e.id = r.employee_id
-		WHERE r.id = ?`, id)
-	var pr PerformanceReview
-	if err := row.Scan(&pr.ID, &pr.EmployeeID, &pr.EmployeeName, &pr.Period, &pr.Reviewer, &pr.Rating, &pr.Strengths, &pr.Opportunities, &pr.State); err != nil {
+func (s *Store) GetPerformanceReview(id int64) (PerformanceReview, error) {
+	return getPerformanceReviewByID(s.db, id)
+}
+
+func getPerformanceReviewByID(db dbtx, id int64) (PerformanceReview, error) {
+	pr, err := scanPerformanceReview(db.QueryRow(reviewSelect+" WHERE r.id = ?", id))
+	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return PerformanceReview{}, ErrNotFound
 		}
@@ -372,36 +1222,37 @@ func (s *Store) getPerformanceReviewByID(id int64) (PerformanceReview, error) {
 }
 
 func validateReviewInput(input PerformanceReviewInput) error {
-	if input.EmployeeID == 0 {
-		return fmt.Errorf("employee is required")
-	}
-	if strings.TrimSpace(input.Period) == "" {
-		return fmt.Errorf("period is required")
-	}
-	if strings.TrimSpace(input.Reviewer) == "" {
-		return fmt.Errorf("reviewer is required")
-	}
-	if err := validateRating(input.Rating); err != nil {
-		return err
+	var v ValidationError
+	if input.EmployeeID <= 0 {
+		v.add("employeeId", FieldRequired, "employeeId is required")
+	}
+	validatePeriod(&v, input.Period)
+	validateReviewer(&v, input.Reviewer)
+	validateRating(&v, input.Rating)
+	return v.orNil()
+}
+
+func validateReviewer(v *ValidationError, reviewer string) {
+	if strings.TrimSpace(reviewer) == "" {
+		v.add("reviewer", FieldRequired, "reviewer is required")
 	}
-	return nil
 }
 
-func validateRating(rating int) error {
-	if rating < 1 || rating > 5 {
-		return fmt.Errorf("rating must be between 1 and 5")
+// validateReviewUpdate applies the create rules to the fields an update sets.
+func validateReviewUpdate(update PerformanceReviewUpdate) error {
+	var v ValidationError
+	if update.Reviewer != nil {
+		validateReviewer(&v, *update.Reviewer)
 	}
-	return nil
+	if update.Rating != nil {
+		validateRating(&v, *update.Rating)
+	}
+	return v.orNil()
 }
 
-func isValidTransition(current, next string) bool {
-	switch current {
-	case ReviewStateDraft:
-		return next == ReviewStateSubmitted
-	case ReviewStateSubmitted:
-		return next == ReviewStateApproved
-	default:
-		return false
+func validateRating(v *ValidationError, rating int) {
+	if rating < 1 || rating > 5 {
+		v.add("rating", FieldOutOfRange, "rating must be between 1 and 5")
 	}
 }
 
@@ -412,20 +1263,11 @@ func (s *Store) ListReviewAggregates(filter PerformanceReviewFilter) ([]ReviewEm
 			(SELECT state FROM performance_reviews r2 WHERE r2.employee_id = e.id ORDER BY r2.id DESC LIMIT 1) as latest_state
 		FROM performance_reviews r
 		JOIN employees e ON e.id = r.employee_id`)
-	args := make([]any, 0)
-	whereClauses, wArgs := buildReviewFilter(filter)
-	if len(whereClauses) > 0 {
-		where, err := joinAllowedClauses(whereClauses, allowedReviewFilterClauses, " AND ")
-		if err != nil {
-			return nil, err
-		}
-		builder.WriteString(" WHERE ")
-		builder.WriteString(where)
-		args = append(args, wArgs...)
-	}
+	w := buildReviewFilter(filter)
+	builder.WriteString(w.String())
 	builder.WriteString(" GROUP BY e.id, e.name")
 
-	rows, err := s.db.Query(builder.String(), args...)
+	rows, err := s.db.Query(builder.String(), w.args...)
 	if err != nil {
 		return nil, err
 	}
@@ -444,25 +1286,55 @@ func (s *Store) ListReviewAggregates(filter PerformanceReviewFilter) ([]ReviewEm
 
 // Payroll
 
-func (s *Store) ListPayrollRecords(filter PayrollFilter) ([]PayrollRecord, error) {
-	builder := strings.Builder{}
-	builder.WriteString(`SELECT p.id, p.employee_id, e.name, p.period, p.base_salary, p.overtime_hours, p.overtime_rate, p.bonuses, p.deductions, p.net_pay
+const payrollSelect = `SELECT p.id, p.employee_id, e.name, p.period, p.base_salary_cents, p.overtime_hours, p.overtime_rate_cents, p.bonuses_cents, p.deductions_cents, p.net_pay_cents,
+		p.status, p.created_at, p.void_reason, p.voided_at, COALESCE(p.corrects_id, 0),
+		COALESCE((SELECT c.id FROM payroll_records c WHERE c.corrects_id = p.id), 0), p.version
 		FROM payroll_records p
-		JOIN employees e ON e.id = p.employee_id`)
-	args := make([]any, 0)
-	whereClauses, wArgs := buildPayrollFilter(filter)
-	if len(whereClauses) > 0 {
-		where, err := joinAllowedClauses(whereClauses, allowedPayrollFilterClauses, " AND ")
-		if err != nil {
-			return nil, err
-		}
-		builder.WriteString(" WHERE ")
-		builder.WriteString(where)
-		args = append(args, wArgs...)
+		JOIN employees e ON e.id = p.employee_id`
+
+func scanPayrollRecord(row rowScanner) (PayrollRecord, error) {
+	var pr PayrollRecord
+	err := row.Scan(&pr.ID, &pr.EmployeeID, &pr.EmployeeName, &pr.Period, &pr.BaseSalary, &pr.OvertimeHours, &pr.OvertimeRate, &pr.Bonuses, &pr.Deductions, &pr.NetPay,
+		&pr.Status, &pr.CreatedAt, &pr.VoidReason, &pr.VoidedAt, &pr.CorrectsID, &pr.CorrectedByID, &pr.Version)
+	return pr, err
+}
+
+// ListPayrollRecords hides voided records unless the filter asks for them.
+func (s *Store) ListPayrollRecords(filter PayrollFilter) ([]PayrollRecord, error) {
+	w := buildPayrollFilter(filter)
+	return queryPayrollRecords(s.db, payrollSelect+w.String()+" ORDER BY p.period_start DESC, p.period_end DESC, p.id DESC", w.args...)
+}
+
+var payrollSorts = map[string]sortKey[PayrollRecord]{
+	"id":      {column: "p.id", value: func(p PayrollRecord) any { return p.ID }},
+	"-id":     {column: "p.id", desc: true, value: func(p PayrollRecord) any { return p.ID }},
+	"period":  {column: payrollPeriodSort, value: func(p PayrollRecord) any { return p.Period.sortKey() }},
+	"-period": {column: payrollPeriodSort, desc: true, value: func(p PayrollRecord) any { return p.Period.sortKey() }},
+	"netPay":  {column: "p.net_pay_cents", value: func(p PayrollRecord) any { return int64(p.NetPay) }},
+	"-netPay": {column: "p.net_pay_cents", desc: true, value: func(p PayrollRecord) any { return int64(p.NetPay) }},
+}
+
+// ListPayrollRecordsPage is ListPayrollRecords cut into pages.
+func (s *Store) ListPayrollRecordsPage(filter PayrollFilter, req PageRequest) (Page[PayrollRecord], error) {
+	q := pageQuery[PayrollRecord]{
+		selectSQL:   payrollSelect,
+		idColumn:    "p.id",
+		sorts:       payrollSorts,
+		defaultSort: "-period",
+		id:          func(p PayrollRecord) int64 { return p.ID },
+		scan:        scanPayrollRecord,
+	}
+	w := buildPayrollFilter(filter)
+	q.where, q.args = w.String(), w.args
+	page, err := queryPage(s.db, q, req)
+	if err != nil {
+		return Page[PayrollRecord]{}, err
 	}
-	builder.WriteString(" ORDER BY p.period DESC, p.id DESC")
+	return page, attachLineItems(s.db, page.Items)
+}
 
-	rows, err := s.db.Query(builder.String(), args...)
+func queryPayrollRecords(db dbtx, query string, args ...any) ([]PayrollRecord, error) {
+	rows, err := db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -470,118 +1342,366 @@ func (s *Store) ListPayrollRecords(filter PayrollFilter) ([]PayrollRecord, error
 
 	result := make([]PayrollRecord, 0)
 	for rows.Next() {
-		var pr PayrollRecord
-		if err := rows.Scan(&pr.ID, &pr.EmployeeID, &pr.EmployeeName, &pr.Period, &pr.BaseSalary, &pr.OvertimeHours, &pr.OvertimeRate, &pr.Bonuses, &pr.Deductions, &pr.NetPay); err != nil {
+		pr, err := scanPayrollRecord(rows)
+		if err != nil {
 			return nil, err
 		}
 		result = append(result, pr)
 	}
-	return result, rows.Err()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// Close before the next query; inside a transaction both share one connection.
+	rows.Close()
+	return result, attachLineItems(db, result)
 }
 
-func buildPayrollFilter(filter PayrollFilter) ([]string, []any) {
-	clauses := make([]string, 0)
-	args := make([]any, 0)
-	if filter.EmployeeID > 0 {
-		clauses = append(clauses, "p.employee_id = ?")
-		args = append(args, filter.EmployeeID)
+// attachLineItems loads the line items of every record with one query.
+func attachLineItems(db dbtx, records []PayrollRecord) error {
+	if len(records) == 0 {
+		return nil
 	}
-	if filter.Period != "" {
-		clauses = append(clauses, "p.period = ?")
-		args = append(args, filter.Period)
+	byID := make(map[int64]int, len(records))
+	ids := make([]any, len(records))
+	for i := range records {
+		records[i].LineItems = make([]PayrollLineItem, 0)
+		byID[records[i].ID] = i
+		ids[i] = records[i].ID
 	}
-	return clauses, args
+	rows, err := db.Query(`SELECT payroll_id, type, code, description, amount_cents FROM payroll_line_items
+		WHERE payroll_id IN (`+placeholders(len(ids))+`) ORDER BY payroll_id, position`, ids...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var payrollID int64
+		var item PayrollLineItem
+		if err := rows.Scan(&payrollID, &item.Type, &item.Code, &item.Description, &item.Amount); err != nil {
+			return err
+		}
+		i := byID[payrollID]
+		records[i].LineItems = append(records[i].LineItems, item)
+	}
+	return rows.Err()
+}
+
+func buildPayrollFilter(filter PayrollFilter) where {
+	var w where
+	in(&w, "p.employee_id", filter.EmployeeIDs)
+	w.contains("e.name", filter.EmployeeName)
+	periodWithin(&w, filter.Period, "p.period_start", "p.period_end")
+	filter.NetPay.apply(&w, "p.net_pay_cents")
+	if !filter.IncludeVoided {
+		w.eq("p.status", PayrollStatusActive)
+	}
+	if filter.Scope.Restricted {
+		w.add(payrollScopeClause, filter.Scope.SelfID, filter.Scope.ManagerID)
+	}
+	return w
 }
 
 type PayrollRecordInput struct {
 	EmployeeID    int64
-	Period        string
-	BaseSalary    float64
+	Period        Period
+	BaseSalary    Money
 	OvertimeHours float64
-	OvertimeRate  float64
-	Bonuses       float64
-	Deductions    float64
+	OvertimeRate  Money
+	LineItems     []PayrollLineItem
 }
 
 func (s *Store) CreatePayrollRecord(input PayrollRecordInput) (PayrollRecord, error) {
 	if err := validatePayrollInput(input); err != nil {
 		return PayrollRecord{}, err
 	}
-	net := calculateNetPay(input.BaseSalary, input.OvertimeHours, input.OvertimeRate, input.Bonuses, input.Deductions)
-	res, err := s.db.Exec(`INSERT INTO payroll_records
-		(employee_id, period, base_salary, overtime_hours, overtime_rate, bonuses, deductions, net_pay)
-		VALUES(?, ?, ?, ?, ?, ?, ?, ?)`,
-		input.EmployeeID, input.Period, input.BaseSalary, input.OvertimeHours, input.OvertimeRate, input.Bonuses, input.Deductions, net)
+	var created PayrollRecord
+	err := s.inTx(func(tx *sql.Tx) error {
+		id, err := insertPayrollRecord(tx, input, nil)
+		if isUniqueViolation(err) {
+			return duplicatePayroll(tx, input)
+		}
+		if err != nil {
+			return mapForeignKeyError(err)
+		}
+		if created, err = getPayrollByID(tx, id); err != nil {
+			return err
+		}
+		return s.audit(tx, AuditEntityPayroll, id, AuditActionCreate, nil, created)
+	})
 	if err != nil {
 		return PayrollRecord{}, err
 	}
+	return created, nil
+}
+
+func insertPayrollRecord(db dbtx, input PayrollRecordInput, correctsID any) (int64, error) {
+	earnings, deductions := lineItemTotals(input.LineItems)
+	net := calculateNetPay(input.BaseSalary, input.OvertimeHours, input.OvertimeRate, input.LineItems)
+	start, end := input.Period.bounds()
+	res, err := db.Exec(`INSERT INTO payroll_records
+		(employee_id, period, period_start, period_end, base_salary_cents, overtime_hours, overtime_rate_cents, bonuses_cents, deductions_cents, net_pay_cents, status, corrects_id, created_at)
+		VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		input.EmployeeID, input.Period, start, end, input.BaseSalary, input.OvertimeHours, input.OvertimeRate, earnings, deductions, net,
+		PayrollStatusActive, correctsID, time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return 0, err
+	}
 	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	for i, item := range input.LineItems {
+		if _, err := db.Exec(`INSERT INTO payroll_line_items(payroll_id, position, type, code, description, amount_cents)
+			VALUES(?, ?, ?, ?, ?, ?)`, id, i+1, item.Type, item.Code, item.Description, item.Amount); err != nil {
+			return 0, err
+		}
+	}
+	return id, nil
+}
+
+// VoidPayrollRecord marks a record as voided. The row is kept for the audit trail
+// and no longer counts towards payroll totals.
+func (s *Store) VoidPayrollRecord(id int64, reason string) (PayrollRecord, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return PayrollRecord{}, fmt.Errorf("reason is required")
+	}
+	var voided PayrollRecord
+	err := s.inTx(func(tx *sql.Tx) error {
+		var err error
+		voided, err = s.voidAndAudit(tx, id, reason)
+		return err
+	})
 	if err != nil {
 		return PayrollRecord{}, err
 	}
-	return s.getPayrollByID(id)
+	return voided, nil
 }
 
-func calculateNetPay(base, overtimeHours, overtimeRate, bonuses, deductions float64) float64 {
-	return base + (overtimeHours * overtimeRate) + bonuses - deductions
+// CorrectPayrollRecord voids the original record and issues a replacement that
+// points back to it, both in the same transaction.
+func (s *Store) CorrectPayrollRecord(id int64, input PayrollRecordInput, reason string) (PayrollRecord, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return PayrollRecord{}, fmt.Errorf("reason is required")
+	}
+	if err := validatePayrollInput(input); err != nil {
+		return PayrollRecord{}, err
+	}
+	var corrected PayrollRecord
+	err := s.inTx(func(tx *sql.Tx) error {
+		original, err := getPayrollByID(tx, id)
+		if err != nil {
+			return err
+		}
+		if err := validateCorrectionTarget(original, input); err != nil {
+			return err
+		}
+		if _, err := s.voidAndAudit(tx, id, reason); err != nil {
+			return err
+		}
+		newID, err := insertPayrollRecord(tx, input, id)
+		if isUniqueViolation(err) {
+			return duplicatePayroll(tx, input)
+		}
+		if err != nil {
+			return mapForeignKeyError(err)
+		}
+		if corrected, err = getPayrollByID(tx, newID); err != nil {
+			return err
+		}
+		return s.audit(tx, AuditEntityPayroll, newID, AuditActionCorrect, nil, corrected)
+	})
+	if err != nil {
+		return PayrollRecord{}, err
+	}
+	return corrected, nil
 }
 
-func validatePayrollInput(input PayrollRecordInput) error {
-	if input.EmployeeID == 0 {
-		return fmt.Errorf("employee is required")
+// validateCorrectionTarget keeps a correction on its original's employee and
+// period; moving pay elsewhere is a void plus a new record.
+func validateCorrectionTarget(original PayrollRecord, input PayrollRecordInput) error {
+	var v ValidationError
+	if input.EmployeeID != original.EmployeeID {
+		v.add("employeeId", FieldImmutable, "a correction must keep the original employee")
 	}
-	if strings.TrimSpace(input.Period) == "" {
-		return fmt.Errorf("period is required")
+	if input.Period != original.Period {
+		v.add("period", FieldImmutable, "a correction must keep the original period")
 	}
-	if input.BaseSalary < 0 {
-		return fmt.Errorf("base salary must be >= 0")
+	return v.orNil()
+}
+
+// voidAndAudit voids a record on the transaction and logs the change. Missing
+// records report ErrNotFound, already voided ones ErrPayrollVoided.
+func (s *Store) voidAndAudit(tx *sql.Tx, id int64, reason string) (PayrollRecord, error) {
+	before, err := getPayrollByID(tx, id)
+	if err != nil {
+		return PayrollRecord{}, err
+	}
+	if err := voidPayrollRecord(tx, id, reason); err != nil {
+		return PayrollRecord{}, err
+	}
+	after, err := getPayrollByID(tx, id)
+	if err != nil {
+		return PayrollRecord{}, err
 	}
-	if input.OvertimeHours < 0 || input.OvertimeRate < 0 {
-		return fmt.Errorf("overtime values must be >= 0")
+	return after, s.audit(tx, AuditEntityPayroll, id, AuditActionVoid, before, after)
+}
+
+func voidPayrollRecord(db dbtx, id int64, reason string) error {
+	res, err := db.Exec(`UPDATE payroll_records SET status = ?, void_reason = ?, voided_at = ?, version = version + 1
+		WHERE id = ? AND status = ?`,
+		PayrollStatusVoided, reason, time.Now().UTC().Format(time.RFC3339), id, PayrollStatusActive)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrPayrollVoided
 	}
 	return nil
 }
 
-func (s *Store) getPayrollByID(id int64) (PayrollRecord, error) {
-	row := s.db.QueryRow(`SELECT p.id, p.employee_id, e.name, p.period, p.base_salary, p.overtime_hours, p.overtime_rate, p.bonuses, p.deductions, p.net_pay
-		FROM payroll_records p
-		JOIN employees e ON e.id = p.employee_id
-		WHERE p.id = ?`, id)
-	var pr PayrollRecord
-	if err := row.Scan(&pr.ID, &pr.EmployeeID, &pr.EmployeeName, &pr.Period, &pr.BaseSalary, &pr.OvertimeHours, &pr.OvertimeRate, &pr.Bonuses, &pr.Deductions, &pr.NetPay); err != nil {
+// calculateNetPay works in cents; only the overtime product needs rounding.
+func calculateNetPay(base Money, overtimeHours float64, overtimeRate Money, lines []PayrollLineItem) Money {
+	earnings, deductions := lineItemTotals(lines)
+	return base + overtimeRate.MulQuantity(overtimeHours) + earnings - deductions
+}
+
+func lineItemTotals(lines []PayrollLineItem) (earnings, deductions Money) {
+	for _, item := range lines {
+		if item.Type == PayrollLineDeduction {
+			deductions += item.Amount
+		} else {
+			earnings += item.Amount
+		}
+	}
+	return earnings, deductions
+}
+
+// genericLineItems turns single bonus and deduction amounts, as older clients
+// send them, into one generic line each. A negative amount becomes a line of
+// the opposite type.
+func genericLineItems(bonuses, deductions Money) []PayrollLineItem {
+	var lines []PayrollLineItem
+	add := func(amount Money, typ, opposite, code, description string) {
+		switch {
+		case amount > 0:
+			lines = append(lines, PayrollLineItem{Type: typ, Code: code, Description: description, Amount: amount})
+		case amount < 0:
+			lines = append(lines, PayrollLineItem{Type: opposite, Code: code, Description: description, Amount: -amount})
+		}
+	}
+	add(bonuses, PayrollLineEarning, PayrollLineDeduction, genericBonusCode, "Bonuses")
+	add(deductions, PayrollLineDeduction, PayrollLineEarning, genericDeductionCode, "Deductions")
+	return lines
+}
+
+// Codes of the generic lines that stand in for single bonus and deduction amounts.
+const (
+	genericBonusCode     = "BONUS"
+	genericDeductionCode = "DEDUCTION"
+)
+
+// maxPayrollLineItems keeps payslips readable; real ones have a dozen lines at most.
+const maxPayrollLineItems = 50
+
+func validatePayrollInput(input PayrollRecordInput) error {
+	var v ValidationError
+	if input.EmployeeID <= 0 {
+		v.add("employeeId", FieldRequired, "employeeId is required")
+	}
+	validatePeriod(&v, input.Period)
+	if input.BaseSalary < 0 {
+		v.add("baseSalary", FieldOutOfRange, "baseSalary must be >= 0")
+	}
+	if input.OvertimeHours < 0 {
+		v.add("overtimeHours", FieldOutOfRange, "overtimeHours must be >= 0")
+	} else if input.OvertimeHours > maxOvertimeHours {
+		v.add("overtimeHours", FieldOutOfRange, fmt.Sprintf("overtimeHours must be <= %d", maxOvertimeHours))
+	}
+	if input.OvertimeRate < 0 {
+		v.add("overtimeRate", FieldOutOfRange, "overtimeRate must be >= 0")
+	}
+	// Bounds keep amounts in a range where cent arithmetic cannot overflow.
+	for _, amount := range []struct {
+		field string
+		value Money
+	}{
+		{"baseSalary", input.BaseSalary},
+		{"overtimeRate", input.OvertimeRate},
+	} {
+		if amount.value > maxMoney || amount.value < -maxMoney {
+			v.add(amount.field, FieldOutOfRange, fmt.Sprintf("%s must be between -%s and %s", amount.field, maxMoney, maxMoney))
+		}
+	}
+	validateLineItems(&v, input.LineItems)
+	return v.orNil()
+}
+
+func validateLineItems(v *ValidationError, lines []PayrollLineItem) {
+	if len(lines) > maxPayrollLineItems {
+		v.add("lineItems", FieldOutOfRange, fmt.Sprintf("a payslip can have at most %d line items", maxPayrollLineItems))
+		return
+	}
+	for i, item := range lines {
+		field := fmt.Sprintf("lineItems[%d]", i)
+		if item.Type != PayrollLineEarning && item.Type != PayrollLineDeduction {
+			v.add(field+".type", FieldInvalidChoice, fmt.Sprintf("type must be %s or %s", PayrollLineEarning, PayrollLineDeduction))
+		}
+		if strings.TrimSpace(item.Code) == "" {
+			v.add(field+".code", FieldRequired, "code is required")
+		}
+		if item.Amount < 0 || item.Amount > maxMoney {
+			v.add(field+".amount", FieldOutOfRange, fmt.Sprintf("amount must be between 0 and %s; use a %s line to subtract", maxMoney, PayrollLineDeduction))
+		}
+	}
+}
+
+// maxOvertimeHours is the number of hours in the longest month.
+const maxOvertimeHours = 744
+
+func (s *Store) GetPayrollRecord(id int64) (PayrollRecord, error) {
+	return getPayrollByID(s.db, id)
+}
+
+func getPayrollByID(db dbtx, id int64) (PayrollRecord, error) {
+	pr, err := scanPayrollRecord(db.QueryRow(payrollSelect+` WHERE p.id = ?`, id))
+	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return PayrollRecord{}, ErrNotFound
 		}
 		return PayrollRecord{}, err
 	}
-	return pr, nil
+	records := []PayrollRecord{pr}
+	if err := attachLineItems(db, records); err != nil {
+		return PayrollRecord{}, err
+	}
+	return records[0], nil
 }
 
-func (s *Store) PayrollTotals(filter PayrollFilter) ([]PayrollPeriodTotal, float64, error) {
+// PayrollTotals sums net pay per period. Voided records never count, regardless of the filter.
+func (s *Store) PayrollTotals(filter PayrollFilter) ([]PayrollPeriodTotal, Money, error) {
+	filter.IncludeVoided = false
 	builder := strings.Builder{}
-	builder.WriteString(`SELECT p.period, SUM(p.net_pay) as total
-		FROM payroll_records p`)
-	args := make([]any, 0)
-	whereClauses, wArgs := buildPayrollFilter(filter)
-	if len(whereClauses) > 0 {
-		where, err := joinAllowedClauses(whereClauses, allowedPayrollFilterClauses, " AND ")
-		if err != nil {
-			return nil, 0, err
-		}
-		builder.WriteString(" WHERE ")
-		builder.WriteString(where)
-		args = append(args, wArgs...)
-	}
-	builder.WriteString(" GROUP BY p.period ORDER BY p.period DESC")
+	builder.WriteString(`SELECT p.period, SUM(p.net_pay_cents) as total
+		FROM payroll_records p
+		JOIN employees e ON e.id = p.employee_id`)
+	w := buildPayrollFilter(filter)
+	builder.WriteString(w.String())
+	builder.WriteString(" GROUP BY p.period, p.period_start, p.period_end ORDER BY p.period_start DESC, p.period_end DESC")
 
-	rows, err := s.db.Query(builder.String(), args...)
+	rows, err := s.db.Query(builder.String(), w.args...)
 	if err != nil {
 		return nil, 0, err
 	}
 	defer rows.Close()
 
 	totalList := make([]PayrollPeriodTotal, 0)
-	var grandTotal float64
+	var grandTotal Money
 	for rows.Next() {
 		var rec PayrollPeriodTotal
 		if err := rows.Scan(&rec.Period, &rec.Total); err != nil {
@@ -1,7 +1,11 @@
 package main
 
 import (
+	"context"
+	"database/sql"
 	"errors"
+	"path/filepath"
+	"sync"
 	"testing"
 )
 
@@ -11,16 +15,16 @@ func newMemoryStore(t *testing.T) *Store {
 	if err != nil {
 		t.Fatalf("new store: %v", err)
 	}
-	if err := store.Init(); err != nil {
+	if err := store.Migrate(); err != nil {
 		store.Close()
-		t.Fatalf("init store: %v", err)
+		t.Fatalf("migrate store: %v", err)
 	}
 	return store
 }
 
 func mustCreateEmployee(t *testing.T, store *Store, name string) Employee {
 	t.Helper()
-	emp, err := store.CreateEmployee(name)
+	emp, err := store.CreateEmployee(EmployeeInput{Name: name})
 	if err != nil {
 		t.Fatalf("create employee: %v", err)
 	}
@@ -78,12 +82,124 @@ func TestStoreTransitionReviewNotFound(t *testing.T) {
 	store := newMemoryStore(t)
 	defer store.Close()
 
-	_, err := store.TransitionPerformanceReview(123, ReviewStateSubmitted)
+	_, err := store.TransitionPerformanceReview(123, ReviewStateSubmitted, "", 0)
 	if err == nil || !errors.Is(err, ErrNotFound) {
 		t.Fatalf("expected ErrNotFound, got %v", err)
 	}
 }
 
+func TestStoreFailedTransitionLeavesHistoryUntouched(t *testing.T) {
+	store := newMemoryStore(t)
+	defer store.Close()
+
+	emp := mustCreateEmployee(t, store, "Alice")
+	review := mustCreateReview(t, store, emp.ID)
+	if _, err := store.TransitionPerformanceReview(review.ID, ReviewStateApproved, "skip ahead", 0); !errors.Is(err, ErrInvalidTransition) {
+		t.Fatalf("expected ErrInvalidTransition, got %v", err)
+	}
+
+	history, err := store.ListReviewTransitions(review.ID)
+	if err != nil {
+		t.Fatalf("list transitions: %v", err)
+	}
+	if len(history) != 1 || history[0].ToState != ReviewStateDraft || history[0].ActorEmail != "system" {
+		t.Fatalf("expected only the creation entry, got %+v", history)
+	}
+}
+
+func TestStoreUpdatePerformanceReviewRespectsState(t *testing.T) {
+	store := newMemoryStore(t)
+	defer store.Close()
+
+	emp := mustCreateEmployee(t, store, "Alice")
+	review := mustCreateReview(t, store, emp.ID)
+	if _, err := store.TransitionPerformanceReview(review.ID, ReviewStateSubmitted, "", 0); err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+
+	if _, err := store.UpdatePerformanceReview(review.ID, PerformanceReviewUpdate{Rating: intPtr(2)}); !errors.Is(err, ErrReviewCommentsOnly) {
+		t.Fatalf("expected ErrReviewCommentsOnly, got %v", err)
+	}
+	got, err := store.UpdatePerformanceReview(review.ID, PerformanceReviewUpdate{Strengths: strPtr("Mentoring")})
+	if err != nil {
+		t.Fatalf("update comments: %v", err)
+	}
+	if got.Strengths != "Mentoring" || got.Rating != review.Rating {
+		t.Fatalf("unexpected review %+v", got)
+	}
+
+	if _, err := store.TransitionPerformanceReview(review.ID, ReviewStateApproved, "", 0); err != nil {
+		t.Fatalf("approve: %v", err)
+	}
+	if _, err := store.UpdatePerformanceReview(review.ID, PerformanceReviewUpdate{Opportunities: strPtr("late edit")}); !errors.Is(err, ErrReviewLocked) {
+		t.Fatalf("expected ErrReviewLocked, got %v", err)
+	}
+	after, err := store.GetPerformanceReview(review.ID)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if after.Opportunities == "late edit" {
+		t.Fatalf("approved review was modified: %+v", after)
+	}
+}
+
+func TestStoreConcurrentTransitionsOnlyOneWins(t *testing.T) {
+	// A file database, unlike :memory:, is shared by all pooled connections,
+	// so the goroutines really race each other.
+	store, err := NewStore(filepath.Join(t.TempDir(), "race.db"))
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	defer store.Close()
+	if err := store.Migrate(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	emp := mustCreateEmployee(t, store, "Alice")
+	review := mustCreateReview(t, store, emp.ID)
+
+	const callers = 8
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		wins int
+		errs []error
+	)
+	start := make(chan struct{})
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			_, err := store.TransitionPerformanceReview(review.ID, ReviewStateSubmitted, "", 0)
+			mu.Lock()
+			defer mu.Unlock()
+			if err == nil {
+				wins++
+			} else {
+				errs = append(errs, err)
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	if wins != 1 {
+		t.Fatalf("expected exactly one winner, got %d (errors: %v)", wins, errs)
+	}
+	for _, err := range errs {
+		if !errors.Is(err, ErrInvalidTransition) && !errors.Is(err, ErrTransitionConflict) {
+			t.Fatalf("expected losers to see a rejected transition, got %v", err)
+		}
+	}
+	history, err := store.ListReviewTransitions(review.ID)
+	if err != nil {
+		t.Fatalf("list transitions: %v", err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("expected creation plus one transition, got %+v", history)
+	}
+}
+
 func TestValidateReviewInput(t *testing.T) {
 	valid := PerformanceReviewInput{
 		EmployeeID:    1,
@@ -175,22 +291,254 @@ func TestJoinAllowedClauses(t *testing.T) {
 }
 
 func TestBuildReviewFilter(t *testing.T) {
-	clauses, args := buildReviewFilter(PerformanceReviewFilter{
-		EmployeeID: 99,
-		Period:     "2024-Q4",
-		State:      ReviewStateDraft,
+	w := buildReviewFilter(PerformanceReviewFilter{
+		EmployeeIDs: []int64{99, 100},
+		Period:      Exactly[Period]("2024-Q4"),
+		States:      []string{ReviewStateDraft},
 	})
-	if len(clauses) != 3 || len(args) != 3 {
-		t.Fatalf("expected 3 clauses and args, got %v %v", clauses, args)
+	want := " WHERE r.employee_id IN (?, ?) AND r.period_start >= ? AND r.period_end <= ? AND r.state IN (?)"
+	if w.String() != want || len(w.args) != 5 || w.args[2] != "2024-10" || w.args[3] != "2024-12" {
+		t.Fatalf("unexpected filter %q %v", w.String(), w.args)
 	}
 }
 
 func TestBuildPayrollFilter(t *testing.T) {
-	clauses, args := buildPayrollFilter(PayrollFilter{
-		EmployeeID: 77,
-		Period:     "2024-11",
+	from := Period("2024-01")
+	w := buildPayrollFilter(PayrollFilter{
+		EmployeeName:  "50%_off",
+		Period:        Range[Period]{Min: &from},
+		IncludeVoided: true,
 	})
-	if len(clauses) != 2 || len(args) != 2 {
-		t.Fatalf("expected 2 clauses and args, got %v %v", clauses, args)
+	want := ` WHERE e.name LIKE ? ESCAPE '\' AND p.period_start >= ?`
+	if w.String() != want || len(w.args) != 2 || w.args[0] != `%50\%\_off%` {
+		t.Fatalf("unexpected filter %q %v", w.String(), w.args)
+	}
+
+	w = buildPayrollFilter(PayrollFilter{})
+	if w.String() != " WHERE p.status = ?" || w.args[0] != PayrollStatusActive {
+		t.Fatalf("expected voided records to be excluded by default, got %q %v", w.String(), w.args)
+	}
+}
+
+func TestStoreCreateEmployeeDuplicates(t *testing.T) {
+	store := newMemoryStore(t)
+	defer store.Close()
+
+	if _, err := store.CreateEmployee(EmployeeInput{Name: "Ana", Email: "ana@example.com", NationalID: "20-12345678-9"}); err != nil {
+		t.Fatalf("create employee: %v", err)
+	}
+	_, err := store.CreateEmployee(EmployeeInput{Name: "Ana B", Email: "ANA@example.com "})
+	if !errors.Is(err, ErrDuplicateEmail) {
+		t.Fatalf("expected ErrDuplicateEmail, got %v", err)
+	}
+	_, err = store.CreateEmployee(EmployeeInput{Name: "Ana C", NationalID: "20123456789"})
+	if !errors.Is(err, ErrDuplicateNationalID) {
+		t.Fatalf("expected ErrDuplicateNationalID, got %v", err)
+	}
+	// Employees without email or national ID must not collide with each other.
+	mustCreateEmployee(t, store, "No Email 1")
+	mustCreateEmployee(t, store, "No Email 2")
+}
+
+func TestStoreUpdateEmployeePartial(t *testing.T) {
+	store := newMemoryStore(t)
+	defer store.Close()
+
+	emp, err := store.CreateEmployee(EmployeeInput{Name: "Ana", Email: "ana@example.com", Department: "Finance"})
+	if err != nil {
+		t.Fatalf("create employee: %v", err)
+	}
+	got, err := store.UpdateEmployee(emp.ID, EmployeeUpdate{JobTitle: strPtr("Analyst"), Email: strPtr("")})
+	if err != nil {
+		t.Fatalf("update employee: %v", err)
+	}
+	if got.Name != "Ana" || got.Department != "Finance" || got.JobTitle != "Analyst" || got.Email != "" {
+		t.Fatalf("unexpected employee after partial update: %+v", got)
+	}
+}
+
+func TestValidateEmployeeInput(t *testing.T) {
+	valid := normalizeEmployeeInput(EmployeeInput{
+		Name:       "Ana",
+		Email:      "ana@example.com",
+		HireDate:   "2024-03-01",
+		NationalID: "20.123.456",
+	})
+	if err := validateEmployeeInput(valid); err != nil {
+		t.Fatalf("expected valid input, got %v", err)
+	}
+	if valid.Status != EmploymentStatusActive || valid.NationalID != "20123456" {
+		t.Fatalf("unexpected normalization: %+v", valid)
+	}
+
+	cases := []struct {
+		name  string
+		input EmployeeInput
+	}{
+		{"missing name", EmployeeInput{}},
+		{"invalid email", EmployeeInput{Name: "A", Email: "not-an-email"}},
+		{"invalid hire date", EmployeeInput{Name: "A", HireDate: "01/03/2024"}},
+		{"invalid status", EmployeeInput{Name: "A", Status: "retired"}},
+		{"short national id", EmployeeInput{Name: "A", NationalID: "123"}},
+		{"invalid national id", EmployeeInput{Name: "A", NationalID: "12345#"}},
+	}
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			if err := validateEmployeeInput(normalizeEmployeeInput(tc.input)); err == nil {
+				t.Fatalf("expected error for %s", tc.name)
+			}
+		})
+	}
+}
+
+func TestStoreCorrectPayrollRecord(t *testing.T) {
+	store := newMemoryStore(t)
+	defer store.Close()
+
+	emp := mustCreateEmployee(t, store, "Bob")
+	original, err := store.CreatePayrollRecord(PayrollRecordInput{EmployeeID: emp.ID, Period: "2024-11", BaseSalary: 1000000})
+	if err != nil {
+		t.Fatalf("create payroll: %v", err)
+	}
+	corrected, err := store.CorrectPayrollRecord(original.ID, PayrollRecordInput{EmployeeID: emp.ID, Period: "2024-11", BaseSalary: 100000}, "extra zero in base salary")
+	if err != nil {
+		t.Fatalf("correct payroll: %v", err)
+	}
+	if corrected.CorrectsID != original.ID || corrected.Status != PayrollStatusActive || corrected.NetPay != 100000 {
+		t.Fatalf("unexpected correction: %+v", corrected)
+	}
+
+	voided, err := store.GetPayrollRecord(original.ID)
+	if err != nil {
+		t.Fatalf("get original: %v", err)
+	}
+	if voided.Status != PayrollStatusVoided || voided.VoidReason != "extra zero in base salary" || voided.VoidedAt == "" || voided.CorrectedByID != corrected.ID {
+		t.Fatalf("unexpected voided original: %+v", voided)
+	}
+
+	if _, err := store.CorrectPayrollRecord(original.ID, PayrollRecordInput{EmployeeID: emp.ID, Period: "2024-11", BaseSalary: 1}, "again"); !errors.Is(err, ErrPayrollVoided) {
+		t.Fatalf("expected ErrPayrollVoided correcting a voided record, got %v", err)
+	}
+	if _, err := store.VoidPayrollRecord(999, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if _, err := store.VoidPayrollRecord(corrected.ID, " "); err == nil {
+		t.Fatalf("expected error for blank reason")
+	}
+
+	_, total, err := store.PayrollTotals(PayrollFilter{IncludeVoided: true})
+	if err != nil {
+		t.Fatalf("totals: %v", err)
+	}
+	if total != 100000 {
+		t.Fatalf("expected voided record netted out of totals, got %s", total)
+	}
+}
+
+func TestStoreForeignKeysEnforcedOnEveryConnection(t *testing.T) {
+	store := newMemoryStore(t)
+	defer store.Close()
+
+	ctx := context.Background()
+	conns := make([]*sql.Conn, 0, 3)
+	defer func() {
+		for _, c := range conns {
+			c.Close()
+		}
+	}()
+	// Holding connections open forces the pool to dial new ones.
+	for i := 0; i < 3; i++ {
+		conn, err := store.db.Conn(ctx)
+		if err != nil {
+			t.Fatalf("conn: %v", err)
+		}
+		conns = append(conns, conn)
+		var enabled int
+		if err := conn.QueryRowContext(ctx, "PRAGMA foreign_keys").Scan(&enabled); err != nil {
+			t.Fatalf("pragma: %v", err)
+		}
+		if enabled != 1 {
+			t.Fatalf("expected foreign keys enabled on connection %d", i)
+		}
+	}
+}
+
+func TestStoreRejectsUnknownEmployee(t *testing.T) {
+	store := newMemoryStore(t)
+	defer store.Close()
+
+	_, err := store.CreatePerformanceReview(PerformanceReviewInput{EmployeeID: 404, Period: "2024-Q4", Reviewer: "Boss", Rating: 3})
+	if !errors.Is(err, ErrEmployeeNotFound) {
+		t.Fatalf("expected ErrEmployeeNotFound for review, got %v", err)
+	}
+	_, err = store.CreatePayrollRecord(PayrollRecordInput{EmployeeID: 404, Period: "2024-11", BaseSalary: 100})
+	if !errors.Is(err, ErrEmployeeNotFound) {
+		t.Fatalf("expected ErrEmployeeNotFound for payroll, got %v", err)
+	}
+}
+
+func TestWithConnectionOptions(t *testing.T) {
+	const opts = "_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_txlock=immediate"
+	if got := withConnectionOptions("./employees.db"); got != "./employees.db?"+opts {
+		t.Fatalf("unexpected dsn %s", got)
+	}
+	if got := withConnectionOptions("file:x.db?mode=ro"); got != "file:x.db?mode=ro&"+opts {
+		t.Fatalf("unexpected dsn %s", got)
+	}
+}
+
+func TestStoreSearchEmployees(t *testing.T) {
+	store := newMemoryStore(t)
+	defer store.Close()
+	jose := mustCreateEmployee(t, store, "José Pérez")
+	mustCreateEmployee(t, store, "Josefina Gómez")
+	mustCreateEmployee(t, store, "Ana María Núñez")
+
+	search := func(text string) []string {
+		t.Helper()
+		list, err := store.ListEmployees(EmployeeFilter{Search: text, IncludeArchived: true})
+		if err != nil {
+			t.Fatalf("search %q: %v", text, err)
+		}
+		names := make([]string, 0, len(list))
+		for _, e := range list {
+			names = append(names, e.Name)
+		}
+		return names
+	}
+	cases := map[string]int{
+		"jose":         2,
+		"JOSÉ pe":      1,
+		"gomez":        1,
+		"nunez ana":    1,
+		"maria":        1,
+		"osé":          0,
+		`"jos" OR ana`: 0,
+	}
+	for text, want := range cases {
+		if got := search(text); len(got) != want {
+			t.Errorf("search %q: expected %d matches, got %v", text, want, got)
+		}
+	}
+
+	// The index follows renames and deletes.
+	if _, err := store.UpdateEmployee(jose.ID, EmployeeUpdate{Name: strPtr("Pepe Pérez")}); err != nil {
+		t.Fatalf("rename: %v", err)
+	}
+	if got := search("pepe"); len(got) != 1 {
+		t.Fatalf("expected the new name to be found, got %v", got)
+	}
+	if got := search("jose"); len(got) != 1 {
+		t.Fatalf("expected the old name to be gone, got %v", got)
+	}
+	if _, err := store.OffboardEmployee(jose.ID, ""); err != nil {
+		t.Fatalf("offboard: %v", err)
+	}
+	if err := store.PurgeEmployee(jose.ID); err != nil {
+		t.Fatalf("purge: %v", err)
+	}
+	if got := search("pepe"); len(got) != 0 {
+		t.Fatalf("expected the purged employee to be gone, got %v", got)
 	}
 }
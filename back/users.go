@@ -0,0 +1,312 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+type User struct {
+	ID    int64  `json:"id"`
+	Email string `json:"email"`
+	Name  string `json:"name"`
+	Role  string `json:"role"`
+	// EmployeeID links the account to the employee it belongs to.
+	EmployeeID int64  `json:"employeeId,omitempty"`
+	CreatedAt  string `json:"createdAt"`
+}
+
+type UserInput struct {
+	Email      string
+	Name       string
+	Password   string
+	Role       string
+	EmployeeID int64
+}
+
+// APIToken describes a personal token. The secret itself is only returned once,
+// at creation time; the database keeps a SHA-256 hash of it.
+type APIToken struct {
+	ID         int64  `json:"id"`
+	UserID     int64  `json:"userId"`
+	Name       string `json:"name"`
+	CreatedAt  string `json:"createdAt"`
+	LastUsedAt string `json:"lastUsedAt"`
+	RevokedAt  string `json:"revokedAt"`
+}
+
+var ErrDuplicateUserEmail = errors.New("user email already in use")
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// apiTokenPrefix lets the auth middleware tell personal tokens from signed login tokens.
+const apiTokenPrefix = "tp07_"
+
+const minPasswordLength = 8
+
+const (
+	RoleAdmin    = "admin"
+	RoleHR       = "hr"
+	RoleManager  = "manager"
+	RoleEmployee = "employee"
+	RoleFinance  = "finance"
+)
+
+var roles = []string{RoleAdmin, RoleHR, RoleManager, RoleEmployee, RoleFinance}
+
+const userColumns = "u.id, u.email, u.name, u.role, COALESCE(u.employee_id, 0), u.created_at"
+
+func scanUser(row rowScanner) (User, error) {
+	var u User
+	err := row.Scan(&u.ID, &u.Email, &u.Name, &u.Role, &u.EmployeeID, &u.CreatedAt)
+	return u, err
+}
+
+func (s *Store) CreateUser(input UserInput) (User, error) {
+	input.Email = normalizeEmail(input.Email)
+	input.Name = strings.TrimSpace(input.Name)
+	input.Role = normalizeRole(input.Role)
+	if err := validateUserInput(input); err != nil {
+		return User{}, err
+	}
+	hash, err := hashPassword(input.Password)
+	if err != nil {
+		return User{}, err
+	}
+	var created User
+	err = s.inTx(func(tx *sql.Tx) error {
+		res, err := tx.Exec(`INSERT INTO users(email, name, password_hash, role, employee_id, created_at) VALUES(?, ?, ?, ?, ?, ?)`,
+			input.Email, input.Name, hash, input.Role, nullIfZero(input.EmployeeID), time.Now().UTC().Format(time.RFC3339))
+		if err != nil {
+			if strings.Contains(err.Error(), "UNIQUE constraint failed: users.email") {
+				return ErrDuplicateUserEmail
+			}
+			return mapForeignKeyError(err)
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+		if created, err = getUserByID(tx, id); err != nil {
+			return err
+		}
+		return s.audit(tx, AuditEntityUser, id, AuditActionCreate, nil, created)
+	})
+	if err != nil {
+		return User{}, err
+	}
+	return created, nil
+}
+
+func normalizeRole(role string) string {
+	return strings.ToLower(strings.TrimSpace(role))
+}
+
+func validateUserInput(input UserInput) error {
+	var v ValidationError
+	if input.Email == "" {
+		v.add("email", FieldRequired, "email is required")
+	}
+	validateEmployeeProfile(&v, input.Email, "", EmploymentStatusActive, "")
+	if input.Name == "" {
+		v.add("name", FieldRequired, "name is required")
+	}
+	if len(input.Password) < minPasswordLength {
+		v.add("password", FieldTooShort, fmt.Sprintf("password must be at least %d characters", minPasswordLength))
+	}
+	if !slices.Contains(roles, input.Role) {
+		v.add("role", FieldInvalidChoice, fmt.Sprintf("role must be one of %s", strings.Join(roles, ", ")))
+	}
+	if input.EmployeeID < 0 {
+		v.add("employeeId", FieldOutOfRange, "employeeId is invalid")
+	} else if (input.Role == RoleManager || input.Role == RoleEmployee) && input.EmployeeID == 0 {
+		// Managers and employees only see data relative to their own employee record.
+		v.add("employeeId", FieldRequired, fmt.Sprintf("employeeId is required for the %s role", input.Role))
+	}
+	return v.orNil()
+}
+
+func (s *Store) GetUser(id int64) (User, error) {
+	return getUserByID(s.db, id)
+}
+
+func getUserByID(db dbtx, id int64) (User, error) {
+	u, err := scanUser(db.QueryRow("SELECT "+userColumns+" FROM users u WHERE u.id = ?", id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return User{}, ErrNotFound
+		}
+		return User{}, err
+	}
+	return u, nil
+}
+
+func (s *Store) ListUsers() ([]User, error) {
+	rows, err := s.db.Query("SELECT " + userColumns + " FROM users u ORDER BY u.id ASC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]User, 0)
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, u)
+	}
+	return result, rows.Err()
+}
+
+func (s *Store) CountUsers() (int, error) {
+	var count int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM users").Scan(&count)
+	return count, err
+}
+
+// AuthenticateUser checks an email and password pair. Unknown emails and wrong
+// passwords both return ErrInvalidCredentials so callers cannot tell them apart.
+func (s *Store) AuthenticateUser(email, password string) (User, error) {
+	var (
+		u    User
+		hash string
+	)
+	err := s.db.QueryRow("SELECT "+userColumns+", u.password_hash FROM users u WHERE u.email = ?", normalizeEmail(email)).
+		Scan(&u.ID, &u.Email, &u.Name, &u.Role, &u.EmployeeID, &u.CreatedAt, &hash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// Spend the same time as a real check so response times do not leak which emails exist.
+			verifyPassword(dummyPasswordHash, password)
+			return User{}, ErrInvalidCredentials
+		}
+		return User{}, err
+	}
+	if !verifyPassword(hash, password) {
+		return User{}, ErrInvalidCredentials
+	}
+	return u, nil
+}
+
+// CreateAPIToken issues a personal token and returns its secret alongside the metadata.
+func (s *Store) CreateAPIToken(userID int64, name string) (APIToken, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return APIToken{}, "", fmt.Errorf("name is required")
+	}
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return APIToken{}, "", err
+	}
+	secret := apiTokenPrefix + base64.RawURLEncoding.EncodeToString(raw)
+	var token APIToken
+	err := s.inTx(func(tx *sql.Tx) error {
+		res, err := tx.Exec(`INSERT INTO api_tokens(user_id, name, token_hash, created_at) VALUES(?, ?, ?, ?)`,
+			userID, name, hashAPIToken(secret), time.Now().UTC().Format(time.RFC3339))
+		if err != nil {
+			return mapForeignKeyError(err)
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+		if token, err = getAPIToken(tx, userID, id); err != nil {
+			return err
+		}
+		// Only the token metadata is logged; the secret never leaves this function.
+		return s.audit(tx, AuditEntityAPIToken, id, AuditActionCreate, nil, token)
+	})
+	if err != nil {
+		return APIToken{}, "", err
+	}
+	return token, secret, nil
+}
+
+const apiTokenColumns = "id, user_id, name, created_at, last_used_at, revoked_at"
+
+func scanAPIToken(row rowScanner) (APIToken, error) {
+	var t APIToken
+	err := row.Scan(&t.ID, &t.UserID, &t.Name, &t.CreatedAt, &t.LastUsedAt, &t.RevokedAt)
+	return t, err
+}
+
+func getAPIToken(db dbtx, userID, id int64) (APIToken, error) {
+	t, err := scanAPIToken(db.QueryRow("SELECT "+apiTokenColumns+" FROM api_tokens WHERE id = ? AND user_id = ?", id, userID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return APIToken{}, ErrNotFound
+		}
+		return APIToken{}, err
+	}
+	return t, nil
+}
+
+func (s *Store) ListAPITokens(userID int64) ([]APIToken, error) {
+	rows, err := s.db.Query("SELECT "+apiTokenColumns+" FROM api_tokens WHERE user_id = ? ORDER BY id ASC", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]APIToken, 0)
+	for rows.Next() {
+		t, err := scanAPIToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, t)
+	}
+	return result, rows.Err()
+}
+
+// RevokeAPIToken disables one of the user's tokens. Revoking twice is a no-op.
+func (s *Store) RevokeAPIToken(userID, id int64) error {
+	return s.inTx(func(tx *sql.Tx) error {
+		before, err := getAPIToken(tx, userID, id)
+		if err != nil || before.RevokedAt != "" {
+			return err
+		}
+		if _, err := tx.Exec(`UPDATE api_tokens SET revoked_at = ? WHERE id = ?`, time.Now().UTC().Format(time.RFC3339), id); err != nil {
+			return err
+		}
+		after, err := getAPIToken(tx, userID, id)
+		if err != nil {
+			return err
+		}
+		return s.audit(tx, AuditEntityAPIToken, id, AuditActionRevoke, before, after)
+	})
+}
+
+// UserForAPIToken resolves an active personal token to its owner and records its use.
+func (s *Store) UserForAPIToken(secret string) (User, int64, error) {
+	var tokenID int64
+	var u User
+	err := s.db.QueryRow(`SELECT t.id, `+userColumns+`
+		FROM api_tokens t
+		JOIN users u ON u.id = t.user_id
+		WHERE t.token_hash = ? AND t.revoked_at = ''`, hashAPIToken(secret)).
+		Scan(&tokenID, &u.ID, &u.Email, &u.Name, &u.Role, &u.EmployeeID, &u.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return User{}, 0, ErrInvalidCredentials
+		}
+		return User{}, 0, err
+	}
+	if _, err := s.db.Exec("UPDATE api_tokens SET last_used_at = ? WHERE id = ?", time.Now().UTC().Format(time.RFC3339), tokenID); err != nil {
+		return User{}, 0, err
+	}
+	return u, tokenID, nil
+}
+
+// hashAPIToken uses a plain SHA-256: the secrets are 256 random bits, so a slow
+// password hash would add latency to every request without adding safety.
+func hashAPIToken(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
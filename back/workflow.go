@@ -0,0 +1,203 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+)
+
+var ErrTransitionReasonRequired = errors.New("a reason is required for this transition")
+var ErrReviewLocked = errors.New("review can no longer be edited")
+var ErrReviewCommentsOnly = errors.New("only strengths and opportunities can be edited")
+
+const (
+	ReviewStateRejected = "rejected"
+	ReviewStateReturned = "returned"
+)
+
+// WorkflowTransition is one allowed edge of the review workflow.
+type WorkflowTransition struct {
+	From           string `json:"from"`
+	To             string `json:"to"`
+	RequiresReason bool   `json:"requiresReason"`
+}
+
+// EditScope is what may still be changed on a review in a given state.
+type EditScope string
+
+const (
+	// EditAll allows every field.
+	EditAll EditScope = "all"
+	// EditComments allows the written feedback, strengths and opportunities, only.
+	EditComments EditScope = "comments"
+	// EditNone locks the review.
+	EditNone EditScope = "none"
+)
+
+// NextState is a state a review may move to from where it is now.
+type NextState struct {
+	State          string `json:"state"`
+	RequiresReason bool   `json:"requiresReason"`
+}
+
+// ReviewWorkflow holds the states a review goes through and the transitions
+// between them. Reviews are created in the initial state; states without
+// outgoing transitions are final. Each state also sets how much of the review
+// can still be edited.
+type ReviewWorkflow struct {
+	initial string
+	next    map[string][]NextState
+	editing map[string]EditScope
+}
+
+// reviewWorkflowConfig is the JSON form read from REVIEW_WORKFLOW_FILE.
+type reviewWorkflowConfig struct {
+	Initial     string               `json:"initial"`
+	Transitions []WorkflowTransition `json:"transitions"`
+	Editing     map[string]EditScope `json:"editing"`
+}
+
+// DefaultReviewWorkflow lets a submitted review be approved, rejected for good,
+// or returned to its author, who resubmits it once fixed. Drafts and returned
+// reviews are editable, submitted ones only take comments, and decided ones
+// are locked.
+func DefaultReviewWorkflow() *ReviewWorkflow {
+	w, err := NewReviewWorkflow(ReviewStateDraft, []WorkflowTransition{
+		{From: ReviewStateDraft, To: ReviewStateSubmitted},
+		{From: ReviewStateSubmitted, To: ReviewStateApproved},
+		{From: ReviewStateSubmitted, To: ReviewStateRejected, RequiresReason: true},
+		{From: ReviewStateSubmitted, To: ReviewStateReturned, RequiresReason: true},
+		{From: ReviewStateReturned, To: ReviewStateSubmitted},
+	}, map[string]EditScope{
+		ReviewStateDraft:     EditAll,
+		ReviewStateReturned:  EditAll,
+		ReviewStateSubmitted: EditComments,
+	})
+	if err != nil {
+		panic(err)
+	}
+	return w
+}
+
+// NewReviewWorkflow validates the transitions: states must be non-empty, edges
+// unique, and the initial state must lead somewhere. States missing from
+// editing are locked, except the initial state, which defaults to EditAll.
+func NewReviewWorkflow(initial string, transitions []WorkflowTransition, editing map[string]EditScope) (*ReviewWorkflow, error) {
+	initial = strings.TrimSpace(initial)
+	if initial == "" {
+		return nil, fmt.Errorf("initial state is required")
+	}
+	w := &ReviewWorkflow{initial: initial, next: map[string][]NextState{}, editing: map[string]EditScope{initial: EditAll}}
+	for state, scope := range editing {
+		switch scope {
+		case EditAll, EditComments, EditNone:
+			w.editing[state] = scope
+		default:
+			return nil, fmt.Errorf("state %q: editing must be all, comments or none", state)
+		}
+	}
+	for _, t := range transitions {
+		from, to := strings.TrimSpace(t.From), strings.TrimSpace(t.To)
+		if from == "" || to == "" {
+			return nil, fmt.Errorf("transition %q -> %q: states are required", t.From, t.To)
+		}
+		if from == to {
+			return nil, fmt.Errorf("transition %q -> %q: a state cannot transition to itself", from, to)
+		}
+		if _, ok := w.lookup(from, to); ok {
+			return nil, fmt.Errorf("transition %q -> %q is defined twice", from, to)
+		}
+		w.next[from] = append(w.next[from], NextState{State: to, RequiresReason: t.RequiresReason})
+	}
+	if len(w.next[initial]) == 0 {
+		return nil, fmt.Errorf("initial state %q has no transitions", initial)
+	}
+	return w, nil
+}
+
+// ParseReviewWorkflow reads a workflow from its JSON configuration.
+func ParseReviewWorkflow(r io.Reader) (*ReviewWorkflow, error) {
+	var cfg reviewWorkflowConfig
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("invalid review workflow: %w", err)
+	}
+	return NewReviewWorkflow(cfg.Initial, cfg.Transitions, cfg.Editing)
+}
+
+// LoadReviewWorkflow reads a workflow configuration file.
+func LoadReviewWorkflow(path string) (*ReviewWorkflow, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseReviewWorkflow(f)
+}
+
+func (w *ReviewWorkflow) Initial() string {
+	return w.initial
+}
+
+// Next lists the states reachable from state, in configuration order.
+func (w *ReviewWorkflow) Next(state string) []NextState {
+	next := make([]NextState, len(w.next[state]))
+	copy(next, w.next[state])
+	return next
+}
+
+// Editing returns how much of a review in state may be changed.
+func (w *ReviewWorkflow) Editing(state string) EditScope {
+	if scope, ok := w.editing[state]; ok {
+		return scope
+	}
+	return EditNone
+}
+
+// StatesAllowing lists, sorted, the states in which an edit of the given scope
+// is permitted.
+func (w *ReviewWorkflow) StatesAllowing(scope EditScope) []string {
+	states := make([]string, 0)
+	for state, allowed := range w.editing {
+		if allowed == EditAll || allowed == scope {
+			states = append(states, state)
+		}
+	}
+	slices.Sort(states)
+	return states
+}
+
+// editError explains why an edit of the given scope is refused in state.
+func (w *ReviewWorkflow) editError(state string, scope EditScope) error {
+	if w.Editing(state) == EditComments && scope == EditAll {
+		return fmt.Errorf("%w while the review is %s", ErrReviewCommentsOnly, state)
+	}
+	return fmt.Errorf("%w once it is %s", ErrReviewLocked, state)
+}
+
+// Check reports whether moving from one state to another is allowed with the
+// given reason.
+func (w *ReviewWorkflow) Check(from, to, reason string) error {
+	t, ok := w.lookup(from, to)
+	if !ok {
+		return ErrInvalidTransition
+	}
+	if t.RequiresReason && strings.TrimSpace(reason) == "" {
+		return ErrTransitionReasonRequired
+	}
+	return nil
+}
+
+func (w *ReviewWorkflow) lookup(from, to string) (NextState, bool) {
+	for _, t := range w.next[from] {
+		if t.State == to {
+			return t, true
+		}
+	}
+	return NextState{}, false
+}
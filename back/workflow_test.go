@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDefaultReviewWorkflow(t *testing.T) {
+	w := DefaultReviewWorkflow()
+	if w.Initial() != ReviewStateDraft {
+		t.Fatalf("expected draft as initial state, got %q", w.Initial())
+	}
+
+	cases := []struct {
+		from, to, reason string
+		want             error
+	}{
+		{ReviewStateDraft, ReviewStateSubmitted, "", nil},
+		{ReviewStateDraft, ReviewStateApproved, "", ErrInvalidTransition},
+		{ReviewStateSubmitted, ReviewStateApproved, "", nil},
+		{ReviewStateSubmitted, ReviewStateRejected, "", ErrTransitionReasonRequired},
+		{ReviewStateSubmitted, ReviewStateRejected, "   ", ErrTransitionReasonRequired},
+		{ReviewStateSubmitted, ReviewStateRejected, "goals were not discussed", nil},
+		{ReviewStateSubmitted, ReviewStateReturned, "missing rating rationale", nil},
+		{ReviewStateReturned, ReviewStateSubmitted, "", nil},
+		{ReviewStateApproved, ReviewStateDraft, "", ErrInvalidTransition},
+		{ReviewStateRejected, ReviewStateSubmitted, "", ErrInvalidTransition},
+		{"unknown", ReviewStateSubmitted, "", ErrInvalidTransition},
+	}
+	for _, tc := range cases {
+		if err := w.Check(tc.from, tc.to, tc.reason); !errors.Is(err, tc.want) {
+			t.Errorf("%s -> %s (%q): expected %v, got %v", tc.from, tc.to, tc.reason, tc.want, err)
+		}
+	}
+
+	next := w.Next(ReviewStateSubmitted)
+	want := []NextState{{ReviewStateApproved, false}, {ReviewStateRejected, true}, {ReviewStateReturned, true}}
+	if len(next) != len(want) {
+		t.Fatalf("expected %v, got %v", want, next)
+	}
+	for i := range want {
+		if next[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, next)
+		}
+	}
+	if got := w.Next(ReviewStateApproved); len(got) != 0 {
+		t.Fatalf("expected approved to be final, got %v", got)
+	}
+
+	if got := w.StatesAllowing(EditAll); !slices.Equal(got, []string{ReviewStateDraft, ReviewStateReturned}) {
+		t.Fatalf("unexpected fully editable states %v", got)
+	}
+	if got := w.StatesAllowing(EditComments); !slices.Equal(got, []string{ReviewStateDraft, ReviewStateReturned, ReviewStateSubmitted}) {
+		t.Fatalf("unexpected comment-editable states %v", got)
+	}
+}
+
+func TestParseReviewWorkflow(t *testing.T) {
+	w, err := ParseReviewWorkflow(strings.NewReader(`{
+		"initial": "open",
+		"transitions": [
+			{"from": "open", "to": "closed"},
+			{"from": "closed", "to": "open", "requiresReason": true}
+		],
+		"editing": {"closed": "comments"}
+	}`))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if w.Initial() != "open" {
+		t.Fatalf("expected open, got %q", w.Initial())
+	}
+	if err := w.Check("closed", "open", ""); !errors.Is(err, ErrTransitionReasonRequired) {
+		t.Fatalf("expected reason to be required, got %v", err)
+	}
+	if w.Editing("open") != EditAll || w.Editing("closed") != EditComments || w.Editing("archived") != EditNone {
+		t.Fatalf("unexpected editing scopes")
+	}
+
+	invalid := map[string]string{
+		"malformed":             `{`,
+		"unknown field":         `{"initial": "open", "states": []}`,
+		"missing initial":       `{"transitions": [{"from": "open", "to": "closed"}]}`,
+		"blank state":           `{"initial": "open", "transitions": [{"from": "open", "to": " "}]}`,
+		"self transition":       `{"initial": "open", "transitions": [{"from": "open", "to": "open"}]}`,
+		"duplicate":             `{"initial": "open", "transitions": [{"from": "open", "to": "closed"}, {"from": "open", "to": "closed"}]}`,
+		"unknown editing scope": `{"initial": "open", "transitions": [{"from": "open", "to": "closed"}], "editing": {"open": "some"}}`,
+		"initial is a dead end": `{"initial": "open", "transitions": [{"from": "closed", "to": "open"}]}`,
+	}
+	for name, cfg := range invalid {
+		if _, err := ParseReviewWorkflow(strings.NewReader(cfg)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
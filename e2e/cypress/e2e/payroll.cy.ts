@@ -1,9 +1,7 @@
-const API_URL = Cypress.env('API_URL') || 'http://localhost:8080';
-
 describe('Payroll flow', () => {
   it('registers a payroll record and shows totals', () => {
     const employeeName = `Payroll User ${Date.now()}`;
-    cy.request('POST', `${API_URL}/employees`, { name: employeeName });
+    cy.apiRequest('POST', '/employees', { name: employeeName });
 
     cy.visit('/payroll');
 
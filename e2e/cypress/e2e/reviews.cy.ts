@@ -1,9 +1,7 @@
-const API_URL = Cypress.env('API_URL') || 'http://localhost:8080';
-
 describe('Performance reviews flow', () => {
   it('creates and approves a review with visible summaries', () => {
     const employeeName = `Eval User ${Date.now()}`;
-    cy.request('POST', `${API_URL}/employees`, { name: employeeName });
+    cy.apiRequest('POST', '/employees', { name: employeeName });
 
     cy.visit('/reviews');
 
@@ -1,3 +1,53 @@
-// You can add custom commands here if needed
+// The API requires a bearer token. Each test signs in as an HR user, created
+// once through the bootstrap admin, and the token is put where the frontend
+// looks for it before any page loads.
 
+const API_URL = Cypress.env('API_URL') || 'http://localhost:8080';
+const ADMIN_EMAIL = Cypress.env('ADMIN_EMAIL') || 'admin@e2e.local';
+const ADMIN_PASSWORD = Cypress.env('ADMIN_PASSWORD') || 'e2e-admin-password';
+const HR_EMAIL = 'hr@e2e.local';
+const HR_PASSWORD = 'e2e-hr-password';
 
+let token = '';
+
+function login(email: string, password: string) {
+  return cy
+    .request('POST', `${API_URL}/auth/login`, { email, password })
+    .its('body.token');
+}
+
+beforeEach(() => {
+  if (token) return;
+  login(ADMIN_EMAIL, ADMIN_PASSWORD).then((adminToken) => {
+    // A 409 means an earlier spec already created the user.
+    cy.request({
+      method: 'POST',
+      url: `${API_URL}/users`,
+      headers: { Authorization: `Bearer ${adminToken}` },
+      body: { email: HR_EMAIL, name: 'E2E HR', password: HR_PASSWORD, role: 'hr' },
+      failOnStatusCode: false,
+    });
+    login(HR_EMAIL, HR_PASSWORD).then((hrToken) => {
+      token = hrToken;
+    });
+  });
+});
+
+Cypress.on('window:before:load', (win) => {
+  if (token) win.localStorage.setItem('tp07.token', token);
+});
+
+declare global {
+  namespace Cypress {
+    interface Chainable {
+      /** Calls the API directly as the signed-in HR user. */
+      apiRequest(method: string, path: string, body?: object): Chainable<Response<any>>;
+    }
+  }
+}
+
+Cypress.Commands.add('apiRequest', (method: string, path: string, body?: object) =>
+  cy.request({ method, url: `${API_URL}${path}`, headers: { Authorization: `Bearer ${token}` }, body })
+);
+
+export {};
@@ -9,6 +9,8 @@ import {
   transitionReview,
   getPayroll,
   createPayroll,
+  login,
+  clearToken,
 } from "../src/lib/api";
 
 const OLD_ENV = process.env;
@@ -18,6 +20,7 @@ beforeEach(() => {
   process.env = { ...OLD_ENV, NEXT_PUBLIC_API_URL: "http://api" };
   // @ts-ignore
   global.fetch = jest.fn();
+  clearToken();
 });
 
 afterAll(() => {
@@ -203,3 +206,20 @@ test("createPayroll normalizes optional numbers", async () => {
   const [, init] = (global.fetch as jest.Mock).mock.calls[0];
   expect((init as RequestInit).body).toContain('"overtimeHours":0');
 });
+
+test("login stores the token and later calls send it", async () => {
+  (global.fetch as jest.Mock)
+    .mockResolvedValueOnce(
+      mockResponse({ token: "t0k", expiresAt: "", user: { id: 1, email: "hr@example.com", name: "HR", role: "hr" } })
+    )
+    .mockResolvedValueOnce(mockResponse([]));
+  await login(" hr@example.com ", "secret123");
+  const [, loginInit] = (global.fetch as jest.Mock).mock.calls[0];
+  expect((loginInit as RequestInit).body).toContain('"email":"hr@example.com"');
+
+  await getEmployees();
+  expect(global.fetch).toHaveBeenLastCalledWith("http://api/employees", {
+    cache: "no-store",
+    headers: { Authorization: "Bearer t0k" },
+  });
+});
@@ -0,0 +1,28 @@
+import { fireEvent, screen, waitFor } from '@testing-library/react';
+import LoginPage from '../src/app/login/page';
+import { renderWithProviders } from '../test/test-utils';
+import * as api from '../src/lib/api';
+
+jest.mock('../src/lib/api', () => ({
+  login: jest.fn(),
+}));
+
+const mockLogin = api.login as jest.MockedFunction<typeof api.login>;
+
+describe('LoginPage', () => {
+  beforeEach(() => {
+    jest.clearAllMocks();
+  });
+
+  test('sends the credentials and shows a failed login', async () => {
+    mockLogin.mockRejectedValue(new Error('invalid email or password'));
+    renderWithProviders(<LoginPage />);
+
+    fireEvent.change(screen.getByLabelText('login-email'), { target: { value: 'hr@example.com' } });
+    fireEvent.change(screen.getByLabelText('login-password'), { target: { value: 'wrong-password' } });
+    fireEvent.submit(screen.getByRole('form', { name: 'login-form' }));
+
+    await waitFor(() => expect(mockLogin).toHaveBeenCalledWith('hr@example.com', 'wrong-password'));
+    expect(await screen.findByText('invalid email or password')).toBeInTheDocument();
+  });
+});
@@ -0,0 +1,82 @@
+"use client";
+
+import { FormEvent, useState } from "react";
+import {
+  Alert,
+  AlertIcon,
+  Box,
+  Button,
+  Card,
+  CardBody,
+  Container,
+  FormControl,
+  FormLabel,
+  Heading,
+  Input,
+  Stack,
+} from "@chakra-ui/react";
+import { login } from "../../lib/api";
+
+export default function LoginPage() {
+  const [email, setEmail] = useState("");
+  const [password, setPassword] = useState("");
+  const [error, setError] = useState<string | null>(null);
+  const [submitting, setSubmitting] = useState(false);
+
+  async function handleSubmit(e: FormEvent) {
+    e.preventDefault();
+    setError(null);
+    setSubmitting(true);
+    try {
+      await login(email, password);
+      window.location.assign("/");
+    } catch (err: any) {
+      setError(err?.message || "No se pudo iniciar sesión");
+    } finally {
+      setSubmitting(false);
+    }
+  }
+
+  return (
+    <Box bg="gray.50" minH="100vh" py={10}>
+      <Container maxW="md">
+        <Card bg="white" shadow="md" borderRadius="lg">
+          <CardBody>
+            <Stack as="form" spacing={4} aria-label="login-form" onSubmit={handleSubmit}>
+              <Heading size="lg" color="brand.700">
+                Iniciar sesión
+              </Heading>
+              {error && (
+                <Alert status="error">
+                  <AlertIcon />
+                  {error}
+                </Alert>
+              )}
+              <FormControl isRequired>
+                <FormLabel>Email</FormLabel>
+                <Input
+                  type="email"
+                  aria-label="login-email"
+                  value={email}
+                  onChange={(e) => setEmail(e.target.value)}
+                />
+              </FormControl>
+              <FormControl isRequired>
+                <FormLabel>Contraseña</FormLabel>
+                <Input
+                  type="password"
+                  aria-label="login-password"
+                  value={password}
+                  onChange={(e) => setPassword(e.target.value)}
+                />
+              </FormControl>
+              <Button type="submit" colorScheme="blue" isLoading={submitting}>
+                Ingresar
+              </Button>
+            </Stack>
+          </CardBody>
+        </Card>
+      </Container>
+    </Box>
+  );
+}
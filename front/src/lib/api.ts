@@ -73,7 +73,43 @@ function apiUrl(path: string): string {
   return `${base}${path}`;
 }
 
+const TOKEN_KEY = "tp07.token";
+
+export function getToken(): string | null {
+  if (typeof window === "undefined") return null;
+  return window.localStorage.getItem(TOKEN_KEY);
+}
+
+export function setToken(token: string): void {
+  window.localStorage.setItem(TOKEN_KEY, token);
+}
+
+export function clearToken(): void {
+  if (typeof window === "undefined") return;
+  window.localStorage.removeItem(TOKEN_KEY);
+}
+
+// withAuth adds the bearer token, when there is one, to a fetch init.
+function withAuth(init: RequestInit): RequestInit {
+  const token = getToken();
+  if (!token) return init;
+  return {
+    ...init,
+    headers: { ...(init.headers as Record<string, string>), Authorization: `Bearer ${token}` },
+  };
+}
+
+// An expired or missing session sends the user back to the login page.
+function handleUnauthorized(res: Response): void {
+  if (res.status !== 401) return;
+  clearToken();
+  if (typeof window !== "undefined" && window.location.pathname !== "/login") {
+    window.location.assign("/login");
+  }
+}
+
 async function handleJson<T>(res: Response): Promise<T> {
+  handleUnauthorized(res);
   const text = await res.text();
   const data = text ? JSON.parse(text) : undefined;
   if (!res.ok) {
@@ -84,16 +120,16 @@ async function handleJson<T>(res: Response): Promise<T> {
 }
 
 export async function getEmployees(): Promise<Employee[]> {
-  const res = await fetch(apiUrl("/employees"), { cache: "no-store" });
+  const res = await fetch(apiUrl("/employees"), withAuth({ cache: "no-store" }));
   return handleJson<Employee[]>(res);
 }
 
 export async function createEmployee(name: string): Promise<Employee> {
-  const res = await fetch(apiUrl("/employees"), {
+  const res = await fetch(apiUrl("/employees"), withAuth({
     method: "POST",
     headers: { "Content-Type": "application/json" },
     body: JSON.stringify({ name: name.trim() }),
-  });
+  }));
   return handleJson<Employee>(res);
 }
 
@@ -101,16 +137,17 @@ export async function updateEmployee(
   id: number,
   name: string
 ): Promise<Employee> {
-  const res = await fetch(apiUrl(`/employees/${id}`), {
+  const res = await fetch(apiUrl(`/employees/${id}`), withAuth({
     method: "PUT",
     headers: { "Content-Type": "application/json" },
     body: JSON.stringify({ name: name.trim() }),
-  });
+  }));
   return handleJson<Employee>(res);
 }
 
 export async function deleteEmployee(id: number): Promise<void> {
-  const res = await fetch(apiUrl(`/employees/${id}`), { method: "DELETE" });
+  const res = await fetch(apiUrl(`/employees/${id}`), withAuth({ method: "DELETE" }));
+  handleUnauthorized(res);
   if (!res.ok) {
     const text = await res.text();
     const data = text ? JSON.parse(text) : undefined;
@@ -126,9 +163,9 @@ export async function getReviews(
   if (params.period) query.set("period", params.period);
   if (params.state) query.set("state", params.state);
   const qs = query.toString();
-  const res = await fetch(apiUrl(`/reviews${qs ? `?${qs}` : ""}`), {
+  const res = await fetch(apiUrl(`/reviews${qs ? `?${qs}` : ""}`), withAuth({
     cache: "no-store",
-  });
+  }));
   return handleJson<ReviewListResponse>(res);
 }
 
@@ -140,7 +177,7 @@ export async function createReview(payload: {
   strengths?: string;
   opportunities?: string;
 }): Promise<PerformanceReview> {
-  const res = await fetch(apiUrl("/reviews"), {
+  const res = await fetch(apiUrl("/reviews"), withAuth({
     method: "POST",
     headers: { "Content-Type": "application/json" },
     body: JSON.stringify({
@@ -150,7 +187,7 @@ export async function createReview(payload: {
       strengths: payload.strengths?.trim() ?? "",
       opportunities: payload.opportunities?.trim() ?? "",
     }),
-  });
+  }));
   return handleJson<PerformanceReview>(res);
 }
 
@@ -163,7 +200,7 @@ export async function updateReview(
     opportunities?: string;
   }
 ): Promise<PerformanceReview> {
-  const res = await fetch(apiUrl(`/reviews/${id}`), {
+  const res = await fetch(apiUrl(`/reviews/${id}`), withAuth({
     method: "PUT",
     headers: { "Content-Type": "application/json" },
     body: JSON.stringify({
@@ -172,7 +209,7 @@ export async function updateReview(
       strengths: payload.strengths?.trim(),
       opportunities: payload.opportunities?.trim(),
     }),
-  });
+  }));
   return handleJson<PerformanceReview>(res);
 }
 
@@ -180,11 +217,11 @@ export async function transitionReview(
   id: number,
   state: string
 ): Promise<PerformanceReview> {
-  const res = await fetch(apiUrl(`/reviews/${id}/status`), {
+  const res = await fetch(apiUrl(`/reviews/${id}/status`), withAuth({
     method: "PUT",
     headers: { "Content-Type": "application/json" },
     body: JSON.stringify({ state: state.trim() }),
-  });
+  }));
   return handleJson<PerformanceReview>(res);
 }
 
@@ -195,9 +232,9 @@ export async function getPayroll(
   if (params.employeeId) query.set("employeeId", String(params.employeeId));
   if (params.period) query.set("period", params.period);
   const qs = query.toString();
-  const res = await fetch(apiUrl(`/payroll${qs ? `?${qs}` : ""}`), {
+  const res = await fetch(apiUrl(`/payroll${qs ? `?${qs}` : ""}`), withAuth({
     cache: "no-store",
-  });
+  }));
   return handleJson<PayrollListResponse>(res);
 }
 
@@ -210,7 +247,7 @@ export async function createPayroll(payload: {
   bonuses?: number;
   deductions?: number;
 }): Promise<PayrollRecord> {
-  const res = await fetch(apiUrl("/payroll"), {
+  const res = await fetch(apiUrl("/payroll"), withAuth({
     method: "POST",
     headers: { "Content-Type": "application/json" },
     body: JSON.stringify({
@@ -221,6 +258,23 @@ export async function createPayroll(payload: {
       bonuses: payload.bonuses ?? 0,
       deductions: payload.deductions ?? 0,
     }),
-  });
+  }));
   return handleJson<PayrollRecord>(res);
 }
+
+export type LoginResponse = {
+  token: string;
+  expiresAt: string;
+  user: { id: number; email: string; name: string; role: string };
+};
+
+export async function login(email: string, password: string): Promise<LoginResponse> {
+  const res = await fetch(apiUrl("/auth/login"), {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify({ email: email.trim(), password }),
+  });
+  const data = await handleJson<LoginResponse>(res);
+  setToken(data.token);
+  return data;
+}